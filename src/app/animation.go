@@ -0,0 +1,347 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	bar "github.com/schollz/progressbar/v3"
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
+
+// AnimationFormat is the file format written by AnimateAppearances.
+type AnimationFormat string
+
+const (
+	AnimationGIF  AnimationFormat = "gif"
+	AnimationAPNG AnimationFormat = "apng"
+)
+
+// AnimateOptions tunes AnimateAppearances.
+type AnimateOptions struct {
+	// Format defaults to GIF.
+	Format AnimationFormat
+	// IDs selects appearances by ID; empty animates every appearance.
+	IDs IDRanges
+	// CacheSize is the number of decoded sheets kept in memory; values
+	// lower than 1 use the Client default.
+	CacheSize int
+}
+
+// animation is a rendered frame group, ready to encode.
+type animation struct {
+	frames []*image.NRGBA
+	delays []int // milliseconds
+	plays  int   // 0 loops forever
+}
+
+// AnimateAppearances writes one animated GIF or APNG per animated frame group
+// of the client in assetsPath. Sprites are read straight from the client
+// assets. Static frame groups are skipped, frame groups whose sprites are all
+// missing are counted as missing, and other failures are collected in the
+// Result. The error is set when the client or its appearances cannot be read.
+func AnimateAppearances(assetsPath, outputDir string, opts AnimateOptions) (Result, error) {
+	var res Result
+
+	ext, err := opts.Format.extension()
+	if err != nil {
+		return res, err
+	}
+	client, err := sprites.OpenClient(assetsPath)
+	if err != nil {
+		return res, err
+	}
+	if opts.CacheSize > 0 {
+		client.SetCacheSize(opts.CacheSize)
+	}
+	apps, err := client.Appearances()
+	if err != nil {
+		log.Err(err).Msg("failed to read appearances")
+		return res, fmt.Errorf("read appearances: %w", err)
+	}
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		log.Err(err).Str("outputDir", outputDir).Msg("failed to create output directory")
+		return res, fmt.Errorf("create %s: %w", outputDir, err)
+	}
+
+	total := 0
+	for _, c := range sprites.AppearanceCategories {
+		total += len(apps.List(c))
+	}
+	progress := bar.NewOptions(
+		total,
+		bar.OptionSetDescription("Animating appearances"),
+		bar.OptionShowCount(),
+		bar.OptionShowIts(),
+		bar.OptionSetItsString("appearances"),
+		bar.OptionThrottle(100),
+		bar.OptionClearOnFinish(),
+	)
+	for _, c := range sprites.AppearanceCategories {
+		for _, a := range apps.List(c) {
+			_ = progress.Add(1)
+			if !opts.IDs.Contains(a.ID) {
+				continue
+			}
+			for i, g := range a.FrameGroups {
+				name := animationBaseName(c, a, i) + ext
+				if g.SpriteInfo.Phases() < 2 {
+					res.Skipped++
+					continue
+				}
+
+				anim, err := buildAnimation(client, g.SpriteInfo, animationLayers(c, g.SpriteInfo))
+				if errors.Is(err, errNoTiles) {
+					log.Error().Str("file", name).Err(err).Msg("failed to animate")
+					res.miss(name, err)
+					continue
+				}
+				if err == nil {
+					err = writeAnimation(filepath.Join(outputDir, name), anim, opts.Format)
+				}
+				if err != nil {
+					log.Error().Str("file", name).Err(err).Msg("failed to animate")
+					res.fail(name, err)
+					continue
+				}
+				res.Processed++
+			}
+		}
+	}
+	_ = progress.Finish()
+
+	log.Info().
+		Int("animated", res.Processed).
+		Int("static", res.Skipped).
+		Int("missing", res.Missing).
+		Int("failed", res.Failed).
+		Str("outputDir", outputDir).
+		Msg("Animating appearances finished")
+
+	return res, nil
+}
+
+func (f AnimationFormat) extension() (string, error) {
+	switch f {
+	case "", AnimationGIF:
+		return ".gif", nil
+	case AnimationAPNG:
+		return ".png", nil
+	}
+	return "", fmt.Errorf("unknown animation format %q", string(f))
+}
+
+// animationBaseName is "<category>_<id>", with the kind of frame group i
+// appended for appearances that have several, e.g. "outfit_128_moving". When
+// several frame groups share that kind, the index of group i follows, e.g.
+// "object_2060_initial_1".
+func animationBaseName(c sprites.AppearanceCategory, a sprites.Appearance, i int) string {
+	name := c.String() + "_" + strconv.Itoa(a.ID)
+	if len(a.FrameGroups) < 2 {
+		return name
+	}
+	kind := a.FrameGroups[i].FixedFrameGroup
+	name += "_" + kind.String()
+	for j, g := range a.FrameGroups {
+		if j != i && g.FixedFrameGroup == kind {
+			return name + "_" + strconv.Itoa(i)
+		}
+	}
+	return name
+}
+
+// animationLayers is the number of layers drawn on top of each other. The
+// second layer of an outfit is the color template, not part of the picture.
+func animationLayers(c sprites.AppearanceCategory, info sprites.SpriteInfo) int {
+	if c == sprites.CategoryOutfit {
+		return 1
+	}
+	return info.LayerCount()
+}
+
+// buildAnimation renders every animation phase of info as one frame. Each
+// frame is a grid of the x patterns (columns) by the y and z patterns (rows),
+// with the first drawLayers layers drawn over each other in every cell.
+func buildAnimation(src spriteSource, info sprites.SpriteInfo, drawLayers int) (animation, error) {
+	if want := info.SpriteCount(); len(info.SpriteIDs) < want {
+		return animation{}, fmt.Errorf("sprite info lists %d sprites, want %d", len(info.SpriteIDs), want)
+	}
+
+	// Load every sprite first: the cell size is the largest sprite.
+	tiles := make(map[int]image.Image)
+	var cell image.Point
+	for phase := 0; phase < info.Phases(); phase++ {
+		for i := 0; i < info.FrameSprites(); i++ {
+			if i%info.LayerCount() >= drawLayers {
+				continue
+			}
+			id := info.SpriteIDs[phase*info.FrameSprites()+i]
+			if _, ok := tiles[id]; ok {
+				continue
+			}
+			img, err := src.Sprite(id)
+			if err != nil {
+				log.Error().Int("sprite", id).Err(err).Msg("tile error")
+				tiles[id] = nil
+				continue
+			}
+			tiles[id] = img
+			cell.X = max(cell.X, img.Bounds().Dx())
+			cell.Y = max(cell.Y, img.Bounds().Dy())
+		}
+	}
+	if cell.X == 0 {
+		return animation{}, errNoTiles
+	}
+
+	px, py, pz := info.PatternsX(), info.PatternsY(), info.PatternsZ()
+	frames := make([]*image.NRGBA, info.Phases())
+	for phase := range frames {
+		dst := image.NewNRGBA(image.Rect(0, 0, cell.X*px, cell.Y*py*pz))
+		for z := 0; z < pz; z++ {
+			for y := 0; y < py; y++ {
+				for x := 0; x < px; x++ {
+					// Sprites larger than a cell's neighbours grow up and to
+					// the left, as the client draws them.
+					corner := image.Pt((x+1)*cell.X, (z*py+y+1)*cell.Y)
+					for layer := 0; layer < drawLayers; layer++ {
+						tile := tiles[info.SpriteIDs[info.SpriteIndex(phase, z, y, x, layer)]]
+						if tile == nil {
+							continue
+						}
+						b := tile.Bounds()
+						r := image.Rectangle{Min: corner.Sub(b.Size()), Max: corner}
+						draw.Draw(dst, r, tile, b.Min, draw.Over)
+					}
+				}
+			}
+		}
+		frames[phase] = dst
+	}
+
+	return sequenceAnimation(frames, info.Animation), nil
+}
+
+// sequenceAnimation orders frames and their delays as the animation plays.
+// Each phase lasts the midpoint of its duration range; ping-pong animations
+// play forward and then backward.
+func sequenceAnimation(frames []*image.NRGBA, anim *sprites.SpriteAnimation) animation {
+	if anim == nil || len(anim.Phases) != len(frames) {
+		return animation{frames: frames, delays: make([]int, len(frames))}
+	}
+
+	order := make([]int, 0, 2*len(frames))
+	for i := range frames {
+		order = append(order, i)
+	}
+	if anim.LoopType == sprites.AnimationLoopPingPong {
+		for i := len(frames) - 2; i > 0; i-- {
+			order = append(order, i)
+		}
+	}
+
+	out := animation{}
+	for _, i := range order {
+		p := anim.Phases[i]
+		out.frames = append(out.frames, frames[i])
+		out.delays = append(out.delays, min((p.DurationMin+p.DurationMax)/2, 0xFFFF))
+	}
+	if anim.LoopType == sprites.AnimationLoopCounted {
+		out.plays = max(anim.LoopCount, 1)
+	}
+	return out
+}
+
+func writeAnimation(path string, anim animation, format AnimationFormat) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if format == AnimationAPNG {
+		err = encodeAPNG(f, anim.frames, anim.delays, anim.plays)
+	} else {
+		err = gif.EncodeAll(f, animationGIF(anim))
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func animationGIF(anim animation) *gif.GIF {
+	out := &gif.GIF{}
+	for i, frame := range anim.frames {
+		out.Image = append(out.Image, toPaletted(frame))
+		// GIF delays are in hundredths of a second; most viewers treat
+		// anything below 2 as 10.
+		out.Delay = append(out.Delay, max(anim.delays[i]/10, 2))
+		out.Disposal = append(out.Disposal, gif.DisposalBackground)
+	}
+	// LoopCount counts repeats after the first play; -1 plays once.
+	switch {
+	case anim.plays == 0:
+		out.LoopCount = 0
+	case anim.plays == 1:
+		out.LoopCount = -1
+	default:
+		out.LoopCount = anim.plays - 1
+	}
+	return out
+}
+
+// toPaletted converts a frame for GIF. Index 0 is transparent and pixels at
+// least half opaque become opaque. Frames with at most 255 colors keep them
+// exactly; others are mapped to the nearest Plan 9 palette color.
+func toPaletted(img *image.NRGBA) *image.Paletted {
+	b := img.Bounds()
+	pal := color.Palette{color.NRGBA{}}
+	index := make(map[color.NRGBA]uint8)
+	exact := true
+	for y := b.Min.Y; y < b.Max.Y && exact; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			if c.A < 0x80 {
+				continue
+			}
+			c.A = 0xFF
+			if _, ok := index[c]; ok {
+				continue
+			}
+			if len(pal) == 256 {
+				exact = false
+				break
+			}
+			index[c] = uint8(len(pal))
+			pal = append(pal, c)
+		}
+	}
+	if !exact {
+		pal = append(color.Palette{color.NRGBA{}}, palette.Plan9[:255]...)
+	}
+
+	out := image.NewPaletted(b, pal)
+	opaque := pal[1:]
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			if c.A < 0x80 {
+				continue
+			}
+			c.A = 0xFF
+			if exact {
+				out.SetColorIndex(x, y, index[c])
+			} else {
+				out.SetColorIndex(x, y, uint8(opaque.Index(c)+1))
+			}
+		}
+	}
+	return out
+}
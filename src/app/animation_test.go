@@ -0,0 +1,263 @@
+package app
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
+
+// spriteMap is an in-memory spriteSource.
+type spriteMap map[int]image.Image
+
+func (m spriteMap) Sprite(id int) (image.Image, error) {
+	img, ok := m[id]
+	if !ok {
+		return nil, fmt.Errorf("sprite %d: %w", id, sprites.ErrSpriteNotFound)
+	}
+	return img, nil
+}
+
+func solidImage(w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func TestBuildAnimationLaysOutPatternsAndLayersPerPhase(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	green := color.NRGBA{G: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+	half := solidImage(32, 32, color.NRGBA{})
+	for y := 16; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			half.SetNRGBA(x, y, blue)
+		}
+	}
+	src := spriteMap{
+		// Phase 0: x pattern 0 is red with a blue lower half on top, x
+		// pattern 1 is green.
+		1: solidImage(32, 32, red), 2: half,
+		3: solidImage(32, 32, green), 4: solidImage(32, 32, color.NRGBA{}),
+		// Phase 1 swaps the colors.
+		5: solidImage(32, 32, green), 6: solidImage(32, 32, color.NRGBA{}),
+		7: solidImage(32, 32, red), 8: solidImage(32, 32, color.NRGBA{}),
+	}
+	info := sprites.SpriteInfo{
+		PatternWidth: 2, PatternHeight: 1, PatternDepth: 1, Layers: 2,
+		SpriteIDs: []int{1, 2, 3, 4, 5, 6, 7, 8},
+		Animation: &sprites.SpriteAnimation{
+			LoopType: sprites.AnimationLoopInfinite,
+			Phases:   []sprites.SpritePhase{{DurationMin: 100, DurationMax: 300}, {DurationMin: 50, DurationMax: 50}},
+		},
+	}
+
+	anim, err := buildAnimation(src, info, 2)
+	if err != nil {
+		t.Fatalf("buildAnimation error: %v", err)
+	}
+	if len(anim.frames) != 2 || anim.delays[0] != 200 || anim.delays[1] != 50 || anim.plays != 0 {
+		t.Fatalf("animation = %d frames, delays %v, plays %d", len(anim.frames), anim.delays, anim.plays)
+	}
+	if b := anim.frames[0].Bounds(); b.Dx() != 64 || b.Dy() != 32 {
+		t.Fatalf("frame bounds = %v, want 64x32", b)
+	}
+	for _, c := range []struct {
+		frame, x, y int
+		want        color.NRGBA
+	}{
+		{0, 5, 5, red}, {0, 5, 20, blue}, {0, 40, 5, green},
+		{1, 5, 5, green}, {1, 40, 20, red},
+	} {
+		if got := anim.frames[c.frame].NRGBAAt(c.x, c.y); got != c.want {
+			t.Fatalf("frame %d at (%d,%d) = %v, want %v", c.frame, c.x, c.y, got, c.want)
+		}
+	}
+
+	// Only drawing the first layer leaves out the blue half.
+	anim, err = buildAnimation(src, info, 1)
+	if err != nil {
+		t.Fatalf("buildAnimation error: %v", err)
+	}
+	if got := anim.frames[0].NRGBAAt(5, 20); got != red {
+		t.Fatalf("first layer only: pixel = %v, want red", got)
+	}
+}
+
+func TestBuildAnimationAnchorsLargeSpritesBottomRight(t *testing.T) {
+	src := spriteMap{1: solidImage(64, 64, color.NRGBA{R: 255, A: 255}), 2: solidImage(32, 32, color.NRGBA{G: 255, A: 255})}
+	info := sprites.SpriteInfo{PatternWidth: 1, PatternHeight: 1, PatternDepth: 1, Layers: 1, SpriteIDs: []int{1, 2},
+		Animation: &sprites.SpriteAnimation{Phases: []sprites.SpritePhase{{}, {}}}}
+
+	anim, err := buildAnimation(src, info, 1)
+	if err != nil {
+		t.Fatalf("buildAnimation error: %v", err)
+	}
+	frame := anim.frames[1]
+	if frame.Bounds().Dx() != 64 || frame.NRGBAAt(40, 40).G != 255 || frame.NRGBAAt(10, 10).A != 0 {
+		t.Fatalf("small sprite not drawn in the bottom right of a 64x64 cell")
+	}
+
+	if _, err := buildAnimation(spriteMap{}, info, 1); err != errNoTiles {
+		t.Fatalf("error = %v, want errNoTiles", err)
+	}
+	info.SpriteIDs = info.SpriteIDs[:1]
+	if _, err := buildAnimation(src, info, 1); err == nil {
+		t.Fatalf("expected error when sprite IDs are missing")
+	}
+}
+
+func TestSequenceAnimationLoops(t *testing.T) {
+	frames := make([]*image.NRGBA, 4)
+	for i := range frames {
+		frames[i] = solidImage(1, 1, color.NRGBA{R: uint8(i), A: 255})
+	}
+	phases := []sprites.SpritePhase{
+		{DurationMin: 10, DurationMax: 10},
+		{DurationMin: 20, DurationMax: 20},
+		{DurationMin: 30, DurationMax: 30},
+		{DurationMin: 40, DurationMax: 40},
+	}
+
+	pingPong := sequenceAnimation(frames, &sprites.SpriteAnimation{LoopType: sprites.AnimationLoopPingPong, Phases: phases})
+	if fmt.Sprint(pingPong.delays) != "[10 20 30 40 30 20]" || pingPong.plays != 0 {
+		t.Fatalf("ping-pong delays = %v plays = %d", pingPong.delays, pingPong.plays)
+	}
+
+	counted := sequenceAnimation(frames, &sprites.SpriteAnimation{LoopType: sprites.AnimationLoopCounted, LoopCount: 3, Phases: phases})
+	if len(counted.frames) != 4 || counted.plays != 3 {
+		t.Fatalf("counted = %d frames, %d plays", len(counted.frames), counted.plays)
+	}
+	if g := animationGIF(counted); g.LoopCount != 2 {
+		t.Fatalf("GIF LoopCount = %d, want 2 repeats", g.LoopCount)
+	}
+}
+
+func TestEncodeAPNGWritesAllFrames(t *testing.T) {
+	first := solidImage(4, 2, color.NRGBA{R: 255, A: 255})
+	second := solidImage(4, 2, color.NRGBA{G: 255, A: 128})
+
+	var buf bytes.Buffer
+	if err := encodeAPNG(&buf, []*image.NRGBA{first, second}, []int{100, 250}, 0); err != nil {
+		t.Fatalf("encodeAPNG error: %v", err)
+	}
+
+	// Decoders without APNG support show the first frame.
+	img, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("png.Decode error: %v", err)
+	}
+	compareImages(t, img, first)
+
+	var chunks []string
+	data := buf.Bytes()[len(pngSignature):]
+	for len(data) >= 12 {
+		n := binary.BigEndian.Uint32(data)
+		kind := string(data[4:8])
+		chunks = append(chunks, kind)
+		if kind == "fcTL" {
+			if delay := binary.BigEndian.Uint16(data[8+20:]); delay != 100 && delay != 250 {
+				t.Fatalf("fcTL delay = %d", delay)
+			}
+		}
+		data = data[12+n:]
+	}
+	if got := fmt.Sprint(chunks); got != "[IHDR acTL fcTL IDAT fcTL fdAT IEND]" {
+		t.Fatalf("chunks = %s", got)
+	}
+}
+
+func TestAnimateAppearancesWritesAnimatedGroups(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	assets := writeTestClient(t, map[string]image.Image{"a.bin": newTestImage(384, 384)}, `[
+                {"type":"appearances","file":"appearances.dat"},
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":10,"area":0}
+        ]`)
+	animated := buildSpriteInfo(1, 1, 1, 1, 1, 2).message(6, protoMessage{}.
+		varint(4, int(sprites.AnimationLoopInfinite)).
+		message(6, protoMessage{}.varint(1, 100).varint(2, 100)).
+		message(6, protoMessage{}.varint(1, 200).varint(2, 200)))
+	dat := protoMessage{}.
+		message(1, buildAppearance(100, animated)).
+		message(1, buildAppearance(101, buildSpriteInfo(1, 1, 1, 1, 3))).
+		message(3, buildAppearance(7, animated))
+	if err := os.WriteFile(filepath.Join(assets, "appearances.dat"), dat, 0o644); err != nil {
+		t.Fatalf("write appearances: %v", err)
+	}
+
+	out := t.TempDir()
+	res, err := AnimateAppearances(assets, out, AnimateOptions{IDs: IDRanges{{First: 100, Last: 101}}})
+	if err != nil {
+		t.Fatalf("AnimateAppearances error: %v", err)
+	}
+	if res.Processed != 1 || res.Skipped != 1 || res.Failed != 0 {
+		t.Fatalf("result = %+v, want 1 animated and 1 static", res)
+	}
+
+	f, err := os.Open(filepath.Join(out, "object_100.gif"))
+	if err != nil {
+		t.Fatalf("open gif: %v", err)
+	}
+	defer f.Close()
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatalf("decode gif: %v", err)
+	}
+	if len(g.Image) != 2 || g.Delay[0] != 10 || g.Delay[1] != 20 || g.LoopCount != 0 {
+		t.Fatalf("gif = %d frames, delays %v, loop %d", len(g.Image), g.Delay, g.LoopCount)
+	}
+
+	res, err = AnimateAppearances(assets, out, AnimateOptions{Format: AnimationAPNG})
+	if err != nil {
+		t.Fatalf("AnimateAppearances error: %v", err)
+	}
+	if res.Processed != 2 {
+		t.Fatalf("result = %+v, want 2 animated", res)
+	}
+	if _, err := os.Stat(filepath.Join(out, "effect_7.png")); err != nil {
+		t.Fatalf("expected effect APNG: %v", err)
+	}
+
+	if _, err := AnimateAppearances(assets, out, AnimateOptions{Format: "webm"}); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
+
+func TestAnimationBaseNameTellsFrameGroupsOfTheSameKindApart(t *testing.T) {
+	outfit := sprites.Appearance{ID: 128, FrameGroups: []sprites.FrameGroup{
+		{FixedFrameGroup: sprites.FrameGroupOutfitIdle},
+		{FixedFrameGroup: sprites.FrameGroupOutfitMoving},
+	}}
+	object := sprites.Appearance{ID: 2060, FrameGroups: []sprites.FrameGroup{
+		{FixedFrameGroup: sprites.FrameGroupObjectInitial},
+		{FixedFrameGroup: sprites.FrameGroupObjectInitial},
+	}}
+	tests := []struct {
+		c    sprites.AppearanceCategory
+		a    sprites.Appearance
+		i    int
+		want string
+	}{
+		{sprites.CategoryObject, sprites.Appearance{ID: 1, FrameGroups: object.FrameGroups[:1]}, 0, "object_1"},
+		{sprites.CategoryOutfit, outfit, 1, "outfit_128_moving"},
+		{sprites.CategoryObject, object, 0, "object_2060_initial_0"},
+		{sprites.CategoryObject, object, 1, "object_2060_initial_1"},
+	}
+	for _, tt := range tests {
+		if got := animationBaseName(tt.c, tt.a, tt.i); got != tt.want {
+			t.Errorf("animationBaseName(%s, %d, %d) = %q, want %q", tt.c, tt.a.ID, tt.i, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"io"
+)
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+
+// encodeAPNG writes frames as an animated PNG. Every frame covers the whole
+// canvas and replaces the previous one. delays are in milliseconds; plays is
+// the number of times the animation runs, 0 meaning forever.
+//
+// image/png picks the color type per image, so frames are written as 8-bit
+// RGBA here to keep them consistent with the header.
+func encodeAPNG(w io.Writer, frames []*image.NRGBA, delays []int, plays int) error {
+	if len(frames) == 0 {
+		return errors.New("apng: no frames")
+	}
+	size := frames[0].Bounds().Size()
+
+	if _, err := w.Write(pngSignature); err != nil {
+		return err
+	}
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(size.X))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(size.Y))
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 6 // color type: truecolor with alpha
+	if err := writePNGChunk(w, "IHDR", ihdr); err != nil {
+		return err
+	}
+
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:], uint32(len(frames)))
+	binary.BigEndian.PutUint32(actl[4:], uint32(plays))
+	if err := writePNGChunk(w, "acTL", actl); err != nil {
+		return err
+	}
+
+	seq := uint32(0)
+	for i, frame := range frames {
+		if frame.Bounds().Size() != size {
+			return errors.New("apng: frames differ in size")
+		}
+
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(size.X))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(size.Y))
+		// x and y offsets stay zero.
+		binary.BigEndian.PutUint16(fctl[20:], uint16(delays[i]))
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		fctl[24] = 0 // dispose_op: none
+		fctl[25] = 0 // blend_op: source
+		if err := writePNGChunk(w, "fcTL", fctl); err != nil {
+			return err
+		}
+		seq++
+
+		data, err := compressRGBA(frame)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			err = writePNGChunk(w, "IDAT", data)
+		} else {
+			fdat := make([]byte, 4, 4+len(data))
+			binary.BigEndian.PutUint32(fdat, seq)
+			err = writePNGChunk(w, "fdAT", append(fdat, data...))
+			seq++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return writePNGChunk(w, "IEND", nil)
+}
+
+// compressRGBA returns the zlib stream of img's scanlines, each prefixed with
+// filter type 0.
+func compressRGBA(img *image.NRGBA) ([]byte, error) {
+	b := img.Bounds()
+	var buf bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y):img.PixOffset(b.Max.X, y)]
+		if _, err := zw.Write([]byte{0}); err != nil {
+			return nil, err
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writePNGChunk(w io.Writer, kind string, data []byte) error {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], kind)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	for _, part := range [][]byte{header[:], data, binary.BigEndian.AppendUint32(nil, crc.Sum32())} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+	"go.yaml.in/yaml/v3"
+)
+
+// DumpFormat selects the encoding of an appearances dump.
+type DumpFormat string
+
+const (
+	DumpJSON DumpFormat = "json"
+	DumpYAML DumpFormat = "yaml"
+)
+
+// DumpFormats lists the formats accepted by AppearancesDump.Encode.
+var DumpFormats = []DumpFormat{DumpJSON, DumpYAML}
+
+// DumpFormatOf picks the format of a dump file from its extension: .yaml and
+// .yml are YAML, anything else JSON.
+func DumpFormatOf(path string) DumpFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return DumpYAML
+	}
+	return DumpJSON
+}
+
+// check reports an error for formats other than DumpFormats.
+func (f DumpFormat) check() error {
+	names := make([]string, len(DumpFormats))
+	for i, known := range DumpFormats {
+		if f == known {
+			return nil
+		}
+		names[i] = string(known)
+	}
+	return fmt.Errorf("unknown dump format %q (want %s)", string(f), strings.Join(names, ", "))
+}
+
+// AppearancesDump is the appearances database together with the files split
+// wrote its sprites to.
+type AppearancesDump struct {
+	Objects  []AppearanceDump `json:"objects" yaml:"objects"`
+	Outfits  []AppearanceDump `json:"outfits" yaml:"outfits"`
+	Effects  []AppearanceDump `json:"effects" yaml:"effects"`
+	Missiles []AppearanceDump `json:"missiles" yaml:"missiles"`
+}
+
+// AppearanceDump is one appearance of an AppearancesDump.
+type AppearanceDump struct {
+	sprites.Appearance `yaml:",inline"`
+	// SpriteFiles maps every sprite ID of the appearance to its file in the
+	// split output.
+	SpriteFiles map[int]string `json:"spriteFiles,omitempty" yaml:"spriteFiles,omitempty"`
+}
+
+// NewAppearancesDump pairs apps with the sprite files written by split into
+// splitDir, under any layout and with json dedup. Sprites that are not in
+// splitDir are counted as missing. When splitDir holds no sprites at all,
+// SpriteFiles are left out.
+func NewAppearancesDump(apps *sprites.Appearances, splitDir string) (*AppearancesDump, Result) {
+	var res Result
+	files, err := splitSpriteFiles(splitDir)
+	if err != nil {
+		log.Debug().Err(err).Str("splitDir", splitDir).Msg("failed to list split sprites")
+	}
+	for id, first := range duplicateSprites(splitDir) {
+		if _, ok := files[id]; !ok {
+			if files == nil {
+				files = make(map[int]string)
+			}
+			files[id] = first
+		}
+	}
+	if len(files) == 0 {
+		log.Warn().
+			Str("splitDir", splitDir).
+			Msg("No split sprites found; sprite files are left out. Did you run the split command?")
+	}
+
+	missing := make(map[int]bool)
+	dump := &AppearancesDump{}
+	lists := map[sprites.AppearanceCategory]*[]AppearanceDump{
+		sprites.CategoryObject:  &dump.Objects,
+		sprites.CategoryOutfit:  &dump.Outfits,
+		sprites.CategoryEffect:  &dump.Effects,
+		sprites.CategoryMissile: &dump.Missiles,
+	}
+	for _, c := range sprites.AppearanceCategories {
+		list := make([]AppearanceDump, 0, len(apps.List(c)))
+		for _, a := range apps.List(c) {
+			d := AppearanceDump{Appearance: a}
+			if len(files) > 0 {
+				d.SpriteFiles = make(map[int]string)
+				for _, g := range a.FrameGroups {
+					for _, id := range g.SpriteInfo.SpriteIDs {
+						if name, ok := files[id]; ok {
+							d.SpriteFiles[id] = filepath.Join(splitDir, filepath.FromSlash(name))
+						} else {
+							missing[id] = true
+						}
+					}
+				}
+			}
+			list = append(list, d)
+			res.Processed++
+		}
+		*lists[c] = list
+	}
+
+	ids := make([]int, 0, len(missing))
+	for id := range missing {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		res.miss(fmt.Sprintf("sprite %d", id), fmt.Errorf("not in %s", splitDir))
+	}
+	return dump, res
+}
+
+// Encode writes the dump to w in format.
+func (d *AppearancesDump) Encode(w io.Writer, format DumpFormat) error {
+	if err := format.check(); err != nil {
+		return err
+	}
+	if format == DumpYAML {
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(d)
+}
+
+// DumpAppearances decodes the appearances file and writes every appearance,
+// with the files of its sprites in splitDir, to dumpPath. The Result counts
+// the appearances written and the sprites missing from splitDir. The error
+// is set when the appearances cannot be read or the dump cannot be written.
+func DumpAppearances(catalogDir, appearancesFileName, splitDir, dumpPath string, format DumpFormat) (Result, error) {
+	if err := format.check(); err != nil {
+		return Result{}, err
+	}
+	apps, err := sprites.LoadAppearances(filepath.Join(catalogDir, appearancesFileName))
+	if err != nil {
+		return Result{}, fmt.Errorf("read appearances: %w", err)
+	}
+	dump, res := NewAppearancesDump(apps, splitDir)
+
+	if err := os.MkdirAll(filepath.Dir(dumpPath), 0o755); err != nil {
+		return res, fmt.Errorf("create %s: %w", filepath.Dir(dumpPath), err)
+	}
+	f, err := os.Create(dumpPath)
+	if err != nil {
+		return res, err
+	}
+	w := bufio.NewWriter(f)
+	err = dump.Encode(w, format)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dumpPath)
+		return res, fmt.Errorf("write %s: %w", dumpPath, err)
+	}
+
+	log.Info().
+		Str("file", dumpPath).
+		Str("format", string(format)).
+		Int("appearances", res.Processed).
+		Int("missingSprites", res.Missing).
+		Msg("Appearances dumped")
+	return res, nil
+}
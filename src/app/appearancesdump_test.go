@@ -0,0 +1,153 @@
+package app
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+	"go.yaml.in/yaml/v3"
+)
+
+// protoMessage builds protobuf wire bytes for tests that need an
+// appearances file.
+type protoMessage []byte
+
+func (m protoMessage) varint(field, v int) protoMessage {
+	m = append(m, encodeVarint(field<<3)...) // wire type 0
+	return append(m, encodeVarint(v)...)
+}
+
+func (m protoMessage) message(field int, sub protoMessage) protoMessage {
+	m = append(m, encodeVarint(field<<3|2)...) // wire type 2
+	m = append(m, encodeVarint(len(sub))...)
+	return append(m, sub...)
+}
+
+func (m protoMessage) str(field int, s string) protoMessage {
+	return m.message(field, protoMessage(s))
+}
+
+func buildSpriteInfo(pw, ph, pd, layers int, ids ...int) protoMessage {
+	info := protoMessage{}.varint(1, pw).varint(2, ph).varint(3, pd).varint(4, layers)
+	for _, id := range ids {
+		info = info.varint(5, id)
+	}
+	return info
+}
+
+func buildAppearance(id int, infos ...protoMessage) protoMessage {
+	a := protoMessage{}.varint(1, id)
+	for i, info := range infos {
+		a = a.message(2, protoMessage{}.varint(1, int(sprites.FrameGroupObjectInitial)).varint(2, i).message(3, info))
+	}
+	return a
+}
+
+func encodeVarint(v int) []byte {
+	var buf [10]byte
+	n := binary.PutUvarint(buf[:], uint64(v))
+	return buf[:n]
+}
+
+func writeDumpAppearances(t *testing.T, dir string) {
+	t.Helper()
+	flags := protoMessage{}.
+		varint(6, 1).
+		message(36, protoMessage{}.varint(1, 17).varint(2, 3031).varint(3, 3031).varint(5, 1).varint(5, 2).varint(6, 20)).
+		message(40, protoMessage{}.str(1, "Rashid").str(2, "Svargrond").varint(3, 50).varint(4, 100))
+	object := buildAppearance(3031, buildSpriteInfo(1, 1, 1, 1, 5, 6)).
+		message(3, flags).
+		str(4, "gold coin")
+	data := protoMessage{}.
+		message(1, object).
+		message(2, buildAppearance(128, buildSpriteInfo(1, 1, 1, 1, 7)))
+	if err := os.WriteFile(filepath.Join(dir, "appearances.dat"), data, 0o644); err != nil {
+		t.Fatalf("write appearances: %v", err)
+	}
+}
+
+func TestDumpAppearancesWritesJSONAndYAML(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	catalog := t.TempDir()
+	writeDumpAppearances(t, catalog)
+	split := t.TempDir()
+	writeTestPNG(t, filepath.Join(split, "5.png"), newTestImage(32, 32))
+	writeTestPNG(t, filepath.Join(split, "6.png"), newTestImage(32, 32))
+
+	out := t.TempDir()
+	res, err := DumpAppearances(catalog, "appearances.dat", split, filepath.Join(out, "appearances.json"), DumpJSON)
+	if err != nil {
+		t.Fatalf("DumpAppearances: %v", err)
+	}
+	if res.Processed != 2 || res.Missing != 1 || res.Errors[0].Item != "sprite 7" {
+		t.Fatalf("result = %+v, want 2 appearances and sprite 7 missing", res)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "appearances.json"))
+	if err != nil {
+		t.Fatalf("read dump: %v", err)
+	}
+	for _, want := range []string{`"name": "gold coin"`, `"cumulative": true`, `"tradeAsObjectId": 3031`, `"spriteIds": [`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("JSON dump lacks %s:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), `"clip"`) {
+		t.Fatalf("JSON dump lists unset flags")
+	}
+	var fromJSON AppearancesDump
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatalf("decode JSON dump: %v", err)
+	}
+	obj := fromJSON.Objects[0]
+	if obj.ID != 3031 || obj.Flags.Market == nil || obj.Flags.Market.MinimumLevel != 20 || len(obj.Flags.NPCSaleData) != 1 {
+		t.Fatalf("object = %+v", obj)
+	}
+	if obj.SpriteFiles[6] != filepath.Join(split, "6.png") || len(fromJSON.Outfits[0].SpriteFiles) != 0 {
+		t.Fatalf("sprite files = %v / %v", obj.SpriteFiles, fromJSON.Outfits[0].SpriteFiles)
+	}
+
+	if _, err := DumpAppearances(catalog, "appearances.dat", split, filepath.Join(out, "appearances.yaml"), DumpYAML); err != nil {
+		t.Fatalf("DumpAppearances yaml: %v", err)
+	}
+	data, err = os.ReadFile(filepath.Join(out, "appearances.yaml"))
+	if err != nil {
+		t.Fatalf("read dump: %v", err)
+	}
+	if !strings.Contains(string(data), "spriteIds: [5, 6]") {
+		t.Fatalf("YAML dump lacks flow sprite IDs:\n%s", data)
+	}
+	var fromYAML AppearancesDump
+	if err := yaml.Unmarshal(data, &fromYAML); err != nil {
+		t.Fatalf("decode YAML dump: %v", err)
+	}
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Fatalf("YAML dump differs from JSON dump:\n%+v\n%+v", fromYAML, fromJSON)
+	}
+}
+
+func TestNewAppearancesDumpWithoutSplitSprites(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	apps := &sprites.Appearances{Objects: []sprites.Appearance{{ID: 1, FrameGroups: []sprites.FrameGroup{{SpriteInfo: sprites.SpriteInfo{SpriteIDs: []int{5}}}}}}}
+	dump, res := NewAppearancesDump(apps, filepath.Join(t.TempDir(), "missing"))
+	if res.Processed != 1 || res.Missing != 0 || dump.Objects[0].SpriteFiles != nil {
+		t.Fatalf("dump = %+v, result = %+v", dump, res)
+	}
+}
+
+func TestDumpAppearancesRejectsUnknownFormat(t *testing.T) {
+	if _, err := DumpAppearances(t.TempDir(), "appearances.dat", "", filepath.Join(t.TempDir(), "a.xml"), DumpFormat("xml")); err == nil || !strings.Contains(err.Error(), "unknown dump format") {
+		t.Fatalf("error = %v, want unknown dump format", err)
+	}
+	if DumpFormatOf("a.YML") != DumpYAML || DumpFormatOf("a.json") != DumpJSON || DumpFormatOf("-") != DumpJSON {
+		t.Fatalf("DumpFormatOf picked the wrong formats")
+	}
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutputSink receives the files the batch entry points would otherwise write
+// into their output directory. Names are slash-separated and relative to that
+// directory. Implementations must be safe for concurrent use.
+type OutputSink interface {
+	WriteFile(name string, data []byte) error
+}
+
+// Archive is an OutputSink that streams every file into a zip or tar.gz
+// archive. Close must be called to finish the archive.
+type Archive struct {
+	mu   sync.Mutex
+	f    *os.File
+	zw   *zip.Writer
+	gz   *gzip.Writer
+	tw   *tar.Writer
+	now  time.Time
+	seen map[string]bool
+}
+
+// ArchiveFormats lists the file extensions accepted by CreateArchive.
+var ArchiveFormats = []string{".zip", ".tar.gz", ".tgz"}
+
+// CreateArchive creates the archive at path, picking zip or tar.gz from its
+// extension. An existing file is replaced.
+func CreateArchive(path string) (*Archive, error) {
+	lower := strings.ToLower(path)
+	isZip := strings.HasSuffix(lower, ".zip")
+	if !isZip && !strings.HasSuffix(lower, ".tar.gz") && !strings.HasSuffix(lower, ".tgz") {
+		return nil, fmt.Errorf("unknown archive format %q (want %s)", path, strings.Join(ArchiveFormats, ", "))
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{f: f, now: time.Now(), seen: make(map[string]bool)}
+	if isZip {
+		a.zw = zip.NewWriter(f)
+	} else {
+		a.gz = gzip.NewWriter(f)
+		a.tw = tar.NewWriter(a.gz)
+	}
+	return a, nil
+}
+
+// WriteFile adds one entry to the archive. Entries are written one at a time,
+// in the order the calls arrive; a name can only be written once.
+func (a *Archive) WriteFile(name string, data []byte) error {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return fmt.Errorf("write %s: archive is closed", name)
+	}
+	if a.seen[name] {
+		return fmt.Errorf("write %s: already in the archive", name)
+	}
+	a.seen[name] = true
+
+	if a.zw != nil {
+		w, err := a.zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: a.now,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	err := a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(len(data)),
+		Mode:     0o644,
+		ModTime:  a.now,
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = a.tw.Write(data)
+	return err
+}
+
+// Close writes the archive index or trailer and closes the file.
+func (a *Archive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return nil
+	}
+
+	var err error
+	if a.zw != nil {
+		err = a.zw.Close()
+	} else {
+		err = a.tw.Close()
+		if gzErr := a.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	if cerr := a.f.Close(); err == nil {
+		err = cerr
+	}
+	a.f = nil
+	return err
+}
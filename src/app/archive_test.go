@@ -0,0 +1,189 @@
+package app
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// readArchive returns the entries of a zip or tar.gz archive by name.
+func readArchive(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+
+	out := make(map[string][]byte)
+	if filepath.Ext(path) == ".zip" {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatalf("open zip: %v", err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("open %s: %v", f.Name, err)
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatalf("read %s: %v", f.Name, err)
+			}
+			out[f.Name] = data
+		}
+		return out
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("read %s: %v", h.Name, err)
+		}
+		out[h.Name] = data
+	}
+	return out
+}
+
+func TestArchiveWritesZipAndTarGz(t *testing.T) {
+	for _, name := range []string{"out.zip", "out.tar.gz", "out.tgz"} {
+		path := filepath.Join(t.TempDir(), name)
+		a, err := CreateArchive(path)
+		if err != nil {
+			t.Fatalf("CreateArchive(%s): %v", name, err)
+		}
+		if err := a.WriteFile("1.png", []byte("one")); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if err := a.WriteFile("sub/2.png", []byte("two")); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if err := a.WriteFile("1.png", []byte("again")); err == nil {
+			t.Fatalf("expected error for duplicate entry")
+		}
+		if err := a.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if err := a.WriteFile("3.png", nil); err == nil {
+			t.Fatalf("expected error after Close")
+		}
+
+		got := readArchive(t, path)
+		if len(got) != 2 || string(got["1.png"]) != "one" || string(got["sub/2.png"]) != "two" {
+			t.Fatalf("%s entries = %q", name, got)
+		}
+	}
+}
+
+func TestCreateArchiveRejectsUnknownExtension(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.rar")
+	if _, err := CreateArchive(path); err == nil {
+		t.Fatalf("expected error for .rar")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("archive file should not be created, stat error = %v", err)
+	}
+}
+
+func TestSplitSpritesIntoArchive(t *testing.T) {
+	extracted := t.TempDir()
+	split := filepath.Join(t.TempDir(), "split")
+	writeTestPNG(t, filepath.Join(extracted, "Sprites-100-101-64x64.png"), newTestImage(128, 64))
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	path := filepath.Join(t.TempDir(), "split.zip")
+	a, err := CreateArchive(path)
+	if err != nil {
+		t.Fatalf("CreateArchive: %v", err)
+	}
+	res, err := SplitSprites(extracted, split, Output{Sink: a})
+	if err != nil {
+		t.Fatalf("SplitSprites: %v", err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if res.Processed != 1 {
+		t.Fatalf("result = %+v, want 1 processed", res)
+	}
+	if _, err := os.Stat(split); !os.IsNotExist(err) {
+		t.Fatalf("split directory should not be created, stat error = %v", err)
+	}
+
+	entries := readArchive(t, path)
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "100.png" || names[1] != "101.png" {
+		t.Fatalf("archive entries = %v, want [100.png 101.png]", names)
+	}
+	if img, err := png.Decode(bytes.NewReader(entries["101.png"])); err != nil || img.Bounds().Dx() != 64 {
+		t.Fatalf("101.png decode = %v, %v", img, err)
+	}
+}
+
+func TestExtractIntoArchiveWritesManifest(t *testing.T) {
+	assetsDir := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "extracted")
+	writeCIPFile(t, assetsDir, "sheet.bmp.lzma", makeCIPAssetFromImage(t, newTestImage(384, 384)))
+	catalog := filepath.Join(assetsDir, "catalog-content.json")
+	if err := os.WriteFile(catalog, []byte(`[{"type":"sprite","file":"sheet.bmp.lzma","spritetype":0,"firstspriteid":1,"lastspriteid":144}]`), 0o644); err != nil {
+		t.Fatalf("write catalog: %v", err)
+	}
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	path := filepath.Join(t.TempDir(), "extracted.tar.gz")
+	a, err := CreateArchive(path)
+	if err != nil {
+		t.Fatalf("CreateArchive: %v", err)
+	}
+	res, err := ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, ExtractOptions{Output: Output{Sink: a}})
+	if err != nil {
+		t.Fatalf("ConvertAssetsFromCatalogContent: %v", err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if res.Processed != 1 {
+		t.Fatalf("result = %+v, want 1 processed", res)
+	}
+
+	entries := readArchive(t, path)
+	if _, ok := entries["Sprites-1-144-32x32.png"]; !ok {
+		t.Fatalf("archive entries = %v, want the sheet", entries)
+	}
+	if _, ok := entries[manifestFileName]; !ok {
+		t.Fatalf("archive has no %s", manifestFileName)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Fatalf("output directory should not be created, stat error = %v", err)
+	}
+}
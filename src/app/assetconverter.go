@@ -1,23 +1,62 @@
 package app
 
 import (
-	"bufio"
-	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/draw"
-	"image/png"
-	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 
 	"github.com/rs/zerolog/log"
 	bar "github.com/schollz/progressbar/v3"
-	"github.com/ulikunitz/xz/lzma"
-	"golang.org/x/image/bmp"
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
 )
 
-func ConvertAssetsFromCatalogContent(assetsPath, contentJsonFullPath, outputPath string) {
+// ExtractOptions tunes ConvertAssetsFromCatalogContent.
+type ExtractOptions struct {
+	// Workers is the number of sheets decoded in parallel; values lower
+	// than 1 fall back to a single worker.
+	Workers int
+	// Force re-extracts every sheet, ignoring the manifest of the previous run.
+	Force bool
+	// Output selects the image format of the sheets. With a Sink, every
+	// sheet is converted and the manifest records no output hashes, since
+	// nothing is read back from the archive.
+	Output Output
+}
+
+// ConvertAssetsFromCatalogContent converts every sprite sheet referenced by the
+// catalog into a PNG. Sheets are decoded by a bounded pool of workers. A
+// manifest in outputPath records what was extracted, so later runs skip
+// unchanged sheets and remove sheets whose catalog entries are gone.
+//
+// Per-sheet failures are collected in the Result; the error is only set when
+// the catalog could not be read or the manifest could not be written.
+func ConvertAssetsFromCatalogContent(assetsPath, contentJsonFullPath, outputPath string, opts ExtractOptions) (Result, error) {
+	if opts.Output.Dedup != nil {
+		return Result{}, errors.New("extract does not support deduplication")
+	}
+	if err := opts.Output.Layout.check(SheetNames); err != nil {
+		return Result{}, err
+	}
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	prev, err := loadExtractManifest(outputPath)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to read extract manifest; extracting everything")
+	}
+	if opts.Force || opts.Output.Sink != nil {
+		prev = newExtractManifest()
+	}
+	cur := newExtractManifest()
+	var res syncResult
+
 	total, err := CountSpriteEntries(contentJsonFullPath)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to count sprites; progress bar may be inaccurate")
@@ -35,7 +74,51 @@ func ConvertAssetsFromCatalogContent(assetsPath, contentJsonFullPath, outputPath
 		)
 	}
 
+	jobs := make(chan sprites.CatalogElem)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range jobs {
+				entry, outcome, err := extractSheet(assetsPath, outputPath, e, prev, opts.Output)
+				if err != nil {
+					log.Err(err).Str("file", e.File).Msg("failed to convert asset")
+				}
+				switch outcome {
+				case sheetConverted:
+					cur.set(e.File, entry)
+				case sheetUnchanged:
+					log.Debug().Str("file", e.File).Msg("skipping: unchanged since last extract")
+					cur.set(e.File, entry)
+				default:
+					// The entry is still in the catalog, so its last good
+					// sheet is kept rather than removed as stale.
+					if last, ok := prev.get(e.File); ok {
+						cur.set(e.File, last)
+					}
+				}
+				res.update(func(r *Result) {
+					switch outcome {
+					case sheetFailed:
+						r.fail(e.File, err)
+					case sheetMissing:
+						r.Missing++
+					case sheetUnchanged:
+						r.Skipped++
+					case sheetConverted:
+						r.Processed++
+					}
+				})
+				if progress != nil {
+					_ = progress.Add(1)
+				}
+			}
+		}()
+	}
+
 	elems, errs := StreamCatalogContent(contentJsonFullPath)
+	var streamErr error
 
 	for {
 		select {
@@ -47,19 +130,7 @@ func ConvertAssetsFromCatalogContent(assetsPath, contentJsonFullPath, outputPath
 				switch e.Type {
 				case "sprite":
 					log.Debug().Msgf("sprite range %d..%d file=%s", e.FirstSpriteId, e.LastSpriteId, e.File)
-					err := convertAsset(
-						assetsPath,
-						outputPath,
-						e.File,
-						e.FirstSpriteId,
-						e.LastSpriteId,
-					)
-					if err != nil {
-						log.Err(err).Msg("failed to convert asset")
-					}
-					if progress != nil {
-						_ = progress.Add(1)
-					}
+					jobs <- e
 				default:
 					log.Debug().Msgf("skip type=%s file=%s", e.Type, e.File)
 				}
@@ -67,6 +138,7 @@ func ConvertAssetsFromCatalogContent(assetsPath, contentJsonFullPath, outputPath
 		case err, ok := <-errs:
 			if ok && err != nil {
 				log.Err(err).Msg("stream error")
+				streamErr = err
 			}
 			errs = nil
 		}
@@ -74,9 +146,106 @@ func ConvertAssetsFromCatalogContent(assetsPath, contentJsonFullPath, outputPath
 			break
 		}
 	}
+
+	close(jobs)
+	wg.Wait()
+
 	if progress != nil {
 		_ = progress.Finish()
 	}
+
+	result := res.res
+	removed := 0
+	if streamErr != nil {
+		// The catalog was only read partially; keep what we cannot vouch for.
+		for file, e := range prev.Files {
+			if _, ok := cur.Files[file]; !ok {
+				cur.Files[file] = e
+			}
+		}
+	} else {
+		for _, name := range staleOutputs(prev, cur) {
+			err := os.Remove(filepath.Join(outputPath, filepath.FromSlash(name)))
+			if err != nil && !os.IsNotExist(err) {
+				log.Err(err).Str("output", name).Msg("failed to remove stale sheet")
+				result.fail(name, err)
+				continue
+			}
+			log.Debug().Str("output", name).Msg("removed stale sheet")
+			removed++
+		}
+	}
+	saveErr := cur.save(outputPath, opts.Output)
+	if saveErr == nil {
+		saveErr = opts.Output.Layout.save(outputPath, opts.Output)
+	}
+	if saveErr != nil {
+		log.Err(saveErr).Msg("failed to write extract manifest")
+	}
+
+	log.Info().
+		Int("converted", result.Processed).
+		Int("unchanged", result.Skipped).
+		Int("missing", result.Missing).
+		Int("failed", result.Failed).
+		Int("removed", removed).
+		Str("outputPath", outputPath).
+		Msg("Extracting sprites finished")
+
+	if streamErr != nil {
+		return result, fmt.Errorf("read catalog: %w", streamErr)
+	}
+	if saveErr != nil {
+		return result, fmt.Errorf("write manifest: %w", saveErr)
+	}
+	return result, nil
+}
+
+// sheetOutcome tells what extractSheet did with a catalog entry.
+type sheetOutcome int
+
+const (
+	sheetFailed sheetOutcome = iota
+	sheetMissing
+	sheetUnchanged
+	sheetConverted
+)
+
+// extractSheet converts one catalog entry unless the previous manifest shows
+// the same source already produced the same output. The returned entry is
+// what the new manifest should record for converted and unchanged sheets.
+func extractSheet(assetsPath, outputPath string, e sprites.CatalogElem, prev *ExtractManifest, out Output) (ManifestEntry, sheetOutcome, error) {
+	sourceHash, err := hashFile(filepath.Join(assetsPath, e.File))
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Debug().Str("file", e.File).Msg("skipping: file does not exist")
+			return ManifestEntry{}, sheetMissing, nil
+		}
+		return ManifestEntry{}, sheetFailed, fmt.Errorf("hash %q: %w", e.File, err)
+	}
+
+	entry := ManifestEntry{
+		SourceHash:    sourceHash,
+		FirstSpriteId: e.FirstSpriteId,
+		LastSpriteId:  e.LastSpriteId,
+		SpriteType:    e.SpriteType,
+		Output:        sheetOutputName(e.FirstSpriteId, e.LastSpriteId, e.SpriteType, out),
+		Encoder:       encoderSettings(out.encoder()),
+	}
+	if prev.upToDate(outputPath, e.File, entry) {
+		return prev.Files[e.File], sheetUnchanged, nil
+	}
+
+	if err := convertAsset(assetsPath, outputPath, e.File, e.FirstSpriteId, e.LastSpriteId, e.SpriteType, out); err != nil {
+		return ManifestEntry{}, sheetFailed, err
+	}
+	if out.Sink != nil {
+		return entry, sheetConverted, nil
+	}
+	if entry.OutputHash, err = hashFile(filepath.Join(outputPath, filepath.FromSlash(entry.Output))); err != nil {
+		return ManifestEntry{}, sheetFailed, fmt.Errorf("hash %q: %w", entry.Output, err)
+	}
+	return entry, sheetConverted, nil
 }
 
 // convertAsset:
@@ -84,8 +253,9 @@ func ConvertAssetsFromCatalogContent(assetsPath, contentJsonFullPath, outputPath
 //  2. skip CIP header (leading 0x00s, 4-byte constant, 7-bit length)
 //  3. repair LZMA "alone" header (props + unknown size) and decode
 //  4. decode BMP
-//  5. write PNG as "Sprites-<firstID>-<lastID>.png" into outputPath
-func convertAsset(assetsPath, outputPath, compressedFilename string, firstID, lastID int) error {
+//  5. write the sheet as "Sprites-<firstID>-<lastID>-<W>x<H>.<ext>", or as
+//     out.Layout names it, into outputPath, in the format of out
+func convertAsset(assetsPath, outputPath, compressedFilename string, firstID, lastID int, spriteType sprites.SpriteType, out Output) error {
 	inPath := filepath.Join(assetsPath, compressedFilename)
 
 	f, err := os.Open(inPath)
@@ -98,117 +268,45 @@ func convertAsset(assetsPath, outputPath, compressedFilename string, firstID, la
 	}
 	defer f.Close()
 
+	name := sheetOutputName(firstID, lastID, spriteType, out)
 	log.Debug().
 		Str("input", compressedFilename).
-		Str("output", fmt.Sprintf("Sprites-%d-%d.png", firstID, lastID)).
+		Str("output", name).
 		Msg("converting")
 
-	br := bufio.NewReaderSize(f, 1<<20) // 1MB buffer for fewer syscalls
-
-	// 1) Skip CIP header
-	if err := skipCIPHeader(br); err != nil {
-		return fmt.Errorf("skip CIP header: %w", err)
-	}
-
-	// 2) Build an LZMA reader from the remaining stream (repair header)
-	lzReader, err := newLZMAReader(br)
+	img, err := sprites.DecodeAsset(f)
 	if err != nil {
-		return fmt.Errorf("lzma reader: %w", err)
-	}
-
-	// 3) LZMA→BMP bytes
-	var bmpBuf bytes.Buffer
-	if _, err := io.Copy(&bmpBuf, lzReader); err != nil {
-		return fmt.Errorf("lzma decode: %w", err)
-	}
-
-	// 4) BMP→image.Image
-	img, err := bmp.Decode(bytes.NewReader(bmpBuf.Bytes()))
-	if err != nil {
-		return fmt.Errorf("bmp decode: %w", err)
+		return err
 	}
 
-	// 5) Write PNG
-	outName := fmt.Sprintf("Sprites-%d-%d.png", firstID, lastID)
-	outPath := filepath.Join(outputPath, outName)
-	if err := writePNG(outPath, img); err != nil {
-		return fmt.Errorf("write png %q: %w", outPath, err)
+	// 5) Write the sheet
+	if err := out.writeImage(outputPath, name, img); err != nil {
+		return fmt.Errorf("write %q: %w", name, err)
 	}
 
 	return nil
 }
 
-// skipCIPHeader consumes:
-//   - all leading 0x00 bytes
-//   - then 4 bytes (constant marker)
-//   - then a 7-bit length (continue while MSB=1)
-func skipCIPHeader(r *bufio.Reader) error {
-	// Skip leading zeros; consume first non-zero byte.
-	for {
-		b, err := r.ReadByte()
-		if err != nil {
-			return err
-		}
-		if b != 0x00 {
-			break // consumed first non-zero byte (part of constant)
-		}
-	}
-	// Skip remaining 4 bytes of the constant marker.
-	if _, err := io.CopyN(io.Discard, r, 4); err != nil {
-		return err
-	}
-	// Skip 7-bit length (bytes with MSB=1 mean "more").
-	for {
-		b, err := r.ReadByte()
-		if err != nil {
-			return err
-		}
-		if (b & 0x80) == 0 {
-			break
-		}
-	}
-	return nil
+// sheetFileName records the sprite range and the sprite dimensions so split
+// can cut the sheet without guessing.
+func sheetFileName(firstID, lastID int, spriteType sprites.SpriteType, ext string) string {
+	return fmt.Sprintf("Sprites-%d-%d-%s%s", firstID, lastID, spriteType, ext)
 }
 
-// newLZMAReader reads the 5-byte props + bogus 8-byte size,
-// replaces size with 0xFF..FF (unknown), and returns a decoder for the rest.
-func newLZMAReader(r *bufio.Reader) (io.Reader, error) {
-	props := make([]byte, 5)
-	if _, err := io.ReadFull(r, props); err != nil {
-		return nil, fmt.Errorf("read props: %w", err)
+// sheetOutputName is the path of a sheet inside the extract output.
+func sheetOutputName(firstID, lastID int, spriteType sprites.SpriteType, out Output) string {
+	var vars nameVars
+	if out.Layout.custom() {
+		vars = nameVars{"first": firstID, "last": lastID, "type": spriteType.String()}
 	}
-	// Discard bogus size (CIP writes compressed size).
-	if _, err := io.CopyN(io.Discard, r, 8); err != nil {
-		return nil, fmt.Errorf("discard bogus size: %w", err)
-	}
-
-	// Build corrected "LZMA alone" header: props + unknown size (all 0xFF).
-	var header bytes.Buffer
-	header.Write(props)
-	header.Write(bytes.Repeat([]byte{0xFF}, 8))
-
-	stream := io.MultiReader(&header, r)
-	rd, err := lzma.NewReader(stream)
-	if err != nil {
-		return nil, err
-	}
-	return rd, nil
+	return out.Layout.file(sheetFileName(firstID, lastID, spriteType, ""), vars, out.Ext())
 }
 
-func writePNG(path string, img image.Image) error {
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
-	}
-	out, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	return png.Encode(out, img)
-}
-
-func SplitSpriteSheet(img image.Image, firstID, lastID int, outputDir string) error {
+// SplitSpriteSheet cuts a sheet into sprites of the size given by spriteType,
+// row by row, and writes them as "<id>.<ext>", or as out.Layout names them,
+// into outputDir in the format of out. Fully transparent sprites are handled
+// by out.EmptyTiles when set, and sprites are enlarged by out.Scaler.
+func SplitSpriteSheet(img image.Image, firstID, lastID int, spriteType sprites.SpriteType, outputDir string, out Output) error {
 	count := lastID - firstID + 1
 	if count <= 0 {
 		return nil
@@ -221,37 +319,38 @@ func SplitSpriteSheet(img image.Image, firstID, lastID int, outputDir string) er
 		log.Debug().Int("w", width).Int("h", height).Msg("unexpected sheet size; proceeding to split")
 	}
 
-	tile := 32
-	if count <= 36 {
-		tile = 64
-	}
-
-	cols := width / tile
-	rows := height / tile
-	maxTiles := cols * rows
+	tileW, tileH := spriteType.Size()
+	maxTiles := sprites.SheetCapacity(b, spriteType)
 	if count > maxTiles {
 		log.Warn().Int("count", count).Int("capacity", maxTiles).Msg("sprite count exceeds sheet capacity; truncating")
 		count = maxTiles
 	}
 
-	id := firstID
-	idx := 0
-	for r := 0; r < rows && idx < count; r++ {
-		for c := 0; c < cols && idx < count; c++ {
-			// Source rect in the sheet
-			sr := image.Rect(b.Min.X+c*tile, b.Min.Y+r*tile, b.Min.X+(c+1)*tile, b.Min.Y+(r+1)*tile)
-			// Copy into a new RGBA tile
-			dst := image.NewRGBA(image.Rect(0, 0, tile, tile))
-			draw.Draw(dst, dst.Bounds(), img, sr.Min, draw.Src)
-
-			outPath := filepath.Join(outputDir, fmt.Sprintf("%d.png", id))
-			if err := writePNG(outPath, dst); err != nil {
-				return fmt.Errorf("write sprite %d: %w", id, err)
-			}
+	for idx := 0; idx < count; idx++ {
+		id := firstID + idx
+		// Copy into a new RGBA tile
+		dst := image.NewRGBA(image.Rect(0, 0, tileW, tileH))
+		draw.Draw(dst, dst.Bounds(), img, sprites.SpriteRect(b, spriteType, idx).Min, draw.Src)
+		if out.EmptyTiles != nil && !out.EmptyTiles.keep(id, dst) {
+			continue
+		}
 
-			id++
-			idx++
+		var vars nameVars
+		if out.Layout.custom() {
+			vars = nameVars{"id": id, "first": firstID, "last": lastID, "type": spriteType.String()}
+		}
+		if err := out.writeImage(outputDir, out.Layout.file(strconv.Itoa(id), vars, out.Ext()), out.scale(dst)); err != nil {
+			return fmt.Errorf("write sprite %d: %w", id, err)
 		}
 	}
 	return nil
 }
+
+// guessSpriteType picks the tile size for sheets extracted without a sprite
+// type in their name: small ranges hold 64x64 sprites, everything else 32x32.
+func guessSpriteType(count int) sprites.SpriteType {
+	if count <= 36 {
+		return sprites.SpriteType64x64
+	}
+	return sprites.SpriteType32x32
+}
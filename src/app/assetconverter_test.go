@@ -1,17 +1,17 @@
 package app
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/png"
-	"io"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
 	"github.com/ulikunitz/xz/lzma"
 	"golang.org/x/image/bmp"
 )
@@ -124,6 +124,21 @@ func writeCIPFile(t *testing.T, dir, name string, data []byte) {
 	}
 }
 
+// writeTestClient writes sheets as compressed assets next to catalog and
+// returns the directory, ready for sprites.OpenClient.
+func writeTestClient(t *testing.T, sheets map[string]image.Image, catalog string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, img := range sheets {
+		writeCIPFile(t, dir, name, makeCIPAssetFromImage(t, img))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "catalog-content.json"), []byte(catalog), 0o644); err != nil {
+		t.Fatalf("write catalog: %v", err)
+	}
+	return dir
+}
+
 func TestConvertAssetCreatesPNGFromCompressedBMP(t *testing.T) {
 	assetsDir := t.TempDir()
 	outputDir := t.TempDir()
@@ -132,13 +147,13 @@ func TestConvertAssetCreatesPNGFromCompressedBMP(t *testing.T) {
 		filename   = "sprite.bin"
 		firstID    = 10
 		lastID     = 12
-		outputName = "Sprites-10-12.png"
+		outputName = "Sprites-10-12-32x64.png"
 	)
 
 	srcImg := newTestImage(4, 3)
 	writeCIPFile(t, assetsDir, filename, makeCIPAssetFromImage(t, srcImg))
 
-	if err := convertAsset(assetsDir, outputDir, filename, firstID, lastID); err != nil {
+	if err := convertAsset(assetsDir, outputDir, filename, firstID, lastID, sprites.SpriteType32x64, Output{}); err != nil {
 		t.Fatalf("convertAsset returned error: %v", err)
 	}
 
@@ -150,11 +165,11 @@ func TestConvertAssetReturnsNilForMissingFile(t *testing.T) {
 	assetsDir := t.TempDir()
 	outputDir := t.TempDir()
 
-	if err := convertAsset(assetsDir, outputDir, "missing.bin", 1, 1); err != nil {
+	if err := convertAsset(assetsDir, outputDir, "missing.bin", 1, 1, sprites.SpriteType32x32, Output{}); err != nil {
 		t.Fatalf("expected nil error for missing file, got %v", err)
 	}
 
-	if _, err := os.Stat(filepath.Join(outputDir, "Sprites-1-1.png")); !os.IsNotExist(err) {
+	if _, err := os.Stat(filepath.Join(outputDir, "Sprites-1-1-32x32.png")); !os.IsNotExist(err) {
 		t.Fatalf("expected no output file, stat err=%v", err)
 	}
 }
@@ -166,84 +181,117 @@ func TestConvertAssetReturnsErrorForInvalidBMP(t *testing.T) {
 	const filename = "corrupt.bin"
 	writeCIPFile(t, assetsDir, filename, makeCIPAssetFromBytes(t, []byte("not a bmp")))
 
-	if err := convertAsset(assetsDir, outputDir, filename, 5, 6); err == nil {
+	if err := convertAsset(assetsDir, outputDir, filename, 5, 6, sprites.SpriteType32x32, Output{}); err == nil {
 		t.Fatalf("expected error for invalid BMP data")
 	}
 }
 
-func TestSkipCIPHeaderSkipsZerosConstantAndVarint(t *testing.T) {
-	payload := []byte{0x00, 0x00, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE}
-	payload = append(payload, 0x81, 0x01) // two-byte varint
-	payload = append(payload, 0xFF)
+func TestConvertAssetsFromCatalogContent(t *testing.T) {
+	assetsDir := t.TempDir()
+	outputDir := t.TempDir()
+	tempDir := t.TempDir()
+
+	imgA := newTestImage(4, 4)
+	imgB := newTestImage(2, 3)
+	writeCIPFile(t, assetsDir, "spriteA.bin", makeCIPAssetFromImage(t, imgA))
+	writeCIPFile(t, assetsDir, "spriteB.bin", makeCIPAssetFromImage(t, imgB))
 
-	r := bufio.NewReader(bytes.NewReader(payload))
-	if err := skipCIPHeader(r); err != nil {
-		t.Fatalf("skipCIPHeader returned error: %v", err)
+	catalog := `[
+                {"type":"sprite","file":"spriteA.bin","spritetype":0,"firstspriteid":1,"lastspriteid":2,"area":0},
+                {"type":"effect","file":"ignore.bin","spritetype":0,"firstspriteid":3,"lastspriteid":3,"area":0},
+                {"type":"sprite","file":"spriteB.bin","spritetype":3,"firstspriteid":5,"lastspriteid":5,"area":0}
+        ]`
+	catalogPath := filepath.Join(tempDir, "content.json")
+	if err := os.WriteFile(catalogPath, []byte(catalog), 0o644); err != nil {
+		t.Fatalf("write catalog: %v", err)
 	}
-	b, err := r.ReadByte()
+
+	res, err := ConvertAssetsFromCatalogContent(assetsDir, catalogPath, outputDir, ExtractOptions{Workers: 1})
 	if err != nil {
-		t.Fatalf("expected remaining byte: %v", err)
+		t.Fatalf("ConvertAssetsFromCatalogContent error: %v", err)
 	}
-	if b != 0xFF {
-		t.Fatalf("unexpected byte after header: got 0x%X want 0xFF", b)
+	if res.Processed != 2 || res.Failed != 0 || res.Missing != 0 {
+		t.Fatalf("result = %+v, want 2 processed", res)
 	}
-}
 
-func TestSkipCIPHeaderErrorsOnUnexpectedEOF(t *testing.T) {
-	r := bufio.NewReader(bytes.NewReader([]byte{0x00, 0x00}))
-	if err := skipCIPHeader(r); err == nil {
-		t.Fatalf("expected error for truncated header")
-	}
+	gotA := decodePNG(t, filepath.Join(outputDir, "Sprites-1-2-32x32.png"))
+	compareImages(t, gotA, imgA)
+
+	gotB := decodePNG(t, filepath.Join(outputDir, "Sprites-5-5-64x64.png"))
+	compareImages(t, gotB, imgB)
 }
 
-func TestNewLZMAReaderDecodesStream(t *testing.T) {
-	stream := encodeLZMA(t, []byte("hello world"))
-	r, err := newLZMAReader(bufio.NewReader(bytes.NewReader(stream)))
+func TestConvertAssetsFromCatalogContentReportsFailuresAndMissing(t *testing.T) {
+	assetsDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeCIPFile(t, assetsDir, "good.bin", makeCIPAssetFromImage(t, newTestImage(4, 4)))
+	writeCIPFile(t, assetsDir, "corrupt.bin", makeCIPAssetFromBytes(t, []byte("not a bmp")))
+	catalog := writeTempFile(t, assetsDir, "catalog-content.json", `[
+                {"type":"sprite","file":"good.bin","spritetype":0,"firstspriteid":1,"lastspriteid":1},
+                {"type":"sprite","file":"corrupt.bin","spritetype":0,"firstspriteid":2,"lastspriteid":2},
+                {"type":"sprite","file":"missing.bin","spritetype":0,"firstspriteid":3,"lastspriteid":3}
+        ]`)
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	res, err := ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, ExtractOptions{Workers: 2})
 	if err != nil {
-		t.Fatalf("newLZMAReader error: %v", err)
+		t.Fatalf("ConvertAssetsFromCatalogContent error: %v", err)
 	}
-	data, err := io.ReadAll(r)
-	if err != nil {
-		t.Fatalf("ReadAll error: %v", err)
+	if res.Processed != 1 || res.Failed != 1 || res.Missing != 1 {
+		t.Fatalf("result = %+v, want 1 processed, 1 failed, 1 missing", res)
 	}
-	if string(data) != "hello world" {
-		t.Fatalf("unexpected decompressed data: %q", string(data))
+	if len(res.Errors) != 1 || res.Errors[0].Item != "corrupt.bin" {
+		t.Fatalf("errors = %v, want one for corrupt.bin", res.Errors)
 	}
-}
 
-func TestNewLZMAReaderErrorsOnShortHeader(t *testing.T) {
-	if _, err := newLZMAReader(bufio.NewReader(bytes.NewReader([]byte{0x01, 0x02, 0x03}))); err == nil {
-		t.Fatalf("expected error for short header")
+	if _, err := ConvertAssetsFromCatalogContent(assetsDir, filepath.Join(assetsDir, "missing.json"), outputDir, ExtractOptions{}); err == nil {
+		t.Fatalf("expected error for unreadable catalog")
 	}
 }
 
-func TestConvertAssetsFromCatalogContent(t *testing.T) {
+func TestConvertAssetsFromCatalogContentParallelMatchesSequential(t *testing.T) {
 	assetsDir := t.TempDir()
-	outputDir := t.TempDir()
 	tempDir := t.TempDir()
 
-	imgA := newTestImage(4, 4)
-	imgB := newTestImage(2, 3)
-	writeCIPFile(t, assetsDir, "spriteA.bin", makeCIPAssetFromImage(t, imgA))
-	writeCIPFile(t, assetsDir, "spriteB.bin", makeCIPAssetFromImage(t, imgB))
-
-	catalog := `[
-                {"type":"sprite","file":"spriteA.bin","spritetype":0,"firstspriteid":1,"lastspriteid":2,"area":0},
-                {"type":"effect","file":"ignore.bin","spritetype":0,"firstspriteid":3,"lastspriteid":3,"area":0},
-                {"type":"sprite","file":"spriteB.bin","spritetype":0,"firstspriteid":5,"lastspriteid":5,"area":0}
-        ]`
+	var catalog bytes.Buffer
+	catalog.WriteString("[")
+	const sheets = 12
+	for i := 0; i < sheets; i++ {
+		name := fmt.Sprintf("sprite%d.bin", i)
+		writeCIPFile(t, assetsDir, name, makeCIPAssetFromImage(t, newTestImage(8+i, 8)))
+		if i > 0 {
+			catalog.WriteString(",")
+		}
+		fmt.Fprintf(&catalog, `{"type":"sprite","file":%q,"spritetype":0,"firstspriteid":%d,"lastspriteid":%d,"area":0}`, name, i*10, i*10+9)
+	}
+	catalog.WriteString("]")
 	catalogPath := filepath.Join(tempDir, "content.json")
-	if err := os.WriteFile(catalogPath, []byte(catalog), 0o644); err != nil {
+	if err := os.WriteFile(catalogPath, catalog.Bytes(), 0o644); err != nil {
 		t.Fatalf("write catalog: %v", err)
 	}
 
-	ConvertAssetsFromCatalogContent(assetsDir, catalogPath, outputDir)
-
-	gotA := decodePNG(t, filepath.Join(outputDir, "Sprites-1-2.png"))
-	compareImages(t, gotA, imgA)
+	sequentialDir := t.TempDir()
+	parallelDir := t.TempDir()
+	ConvertAssetsFromCatalogContent(assetsDir, catalogPath, sequentialDir, ExtractOptions{Workers: 1})
+	ConvertAssetsFromCatalogContent(assetsDir, catalogPath, parallelDir, ExtractOptions{Workers: 4})
 
-	gotB := decodePNG(t, filepath.Join(outputDir, "Sprites-5-5.png"))
-	compareImages(t, gotB, imgB)
+	for i := 0; i < sheets; i++ {
+		name := fmt.Sprintf("Sprites-%d-%d-32x32.png", i*10, i*10+9)
+		want, err := os.ReadFile(filepath.Join(sequentialDir, name))
+		if err != nil {
+			t.Fatalf("read sequential %s: %v", name, err)
+		}
+		got, err := os.ReadFile(filepath.Join(parallelDir, name))
+		if err != nil {
+			t.Fatalf("read parallel %s: %v", name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s differs between sequential and parallel runs", name)
+		}
+	}
 }
 
 func readSpriteBounds(t *testing.T, dir string, id int) image.Rectangle {
@@ -272,7 +320,7 @@ func TestSplitSpriteSheetUses64PixelTilesForSmallSheets(t *testing.T) {
 		lastID  = 103
 	)
 
-	if err := SplitSpriteSheet(img, firstID, lastID, outputDir); err != nil {
+	if err := SplitSpriteSheet(img, firstID, lastID, sprites.SpriteType64x64, outputDir, Output{}); err != nil {
 		t.Fatalf("SplitSpriteSheet returned error: %v", err)
 	}
 
@@ -304,7 +352,7 @@ func TestSplitSpriteSheetUses32PixelTilesForLargeSheets(t *testing.T) {
 		lastID  = 239
 	)
 
-	if err := SplitSpriteSheet(img, firstID, lastID, outputDir); err != nil {
+	if err := SplitSpriteSheet(img, firstID, lastID, sprites.SpriteType32x32, outputDir, Output{}); err != nil {
 		t.Fatalf("SplitSpriteSheet returned error: %v", err)
 	}
 
@@ -336,7 +384,7 @@ func TestSplitSpriteSheetTruncatesWhenCountExceedsCapacity(t *testing.T) {
 		lastID  = 360
 	)
 
-	if err := SplitSpriteSheet(img, firstID, lastID, outputDir); err != nil {
+	if err := SplitSpriteSheet(img, firstID, lastID, sprites.SpriteType32x32, outputDir, Output{}); err != nil {
 		t.Fatalf("SplitSpriteSheet returned error: %v", err)
 	}
 
@@ -365,3 +413,44 @@ func TestSplitSpriteSheetTruncatesWhenCountExceedsCapacity(t *testing.T) {
 		t.Fatalf("unexpected sprite %d generated", firstID+expected)
 	}
 }
+
+func TestSplitSpriteSheetCutsNonSquareTiles(t *testing.T) {
+	img := newTestImage(384, 384)
+
+	cases := []struct {
+		spriteType sprites.SpriteType
+		width      int
+		height     int
+		second     image.Point
+	}{
+		{sprites.SpriteType32x64, 32, 64, image.Pt(32, 0)},
+		{sprites.SpriteType64x32, 64, 32, image.Pt(64, 0)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.spriteType.String(), func(t *testing.T) {
+			outputDir := t.TempDir()
+			if err := SplitSpriteSheet(img, 1, 72, tc.spriteType, outputDir, Output{}); err != nil {
+				t.Fatalf("SplitSpriteSheet returned error: %v", err)
+			}
+
+			entries, err := os.ReadDir(outputDir)
+			if err != nil {
+				t.Fatalf("ReadDir: %v", err)
+			}
+			if len(entries) != 72 {
+				t.Fatalf("expected 72 sprites, got %d", len(entries))
+			}
+
+			bounds := readSpriteBounds(t, outputDir, 2)
+			if bounds.Dx() != tc.width || bounds.Dy() != tc.height {
+				t.Fatalf("sprite bounds = %v, want %dx%d", bounds, tc.width, tc.height)
+			}
+
+			got := decodePNG(t, filepath.Join(outputDir, "2.png"))
+			want := image.NewRGBA(image.Rect(0, 0, tc.width, tc.height))
+			draw.Draw(want, want.Bounds(), img, tc.second, draw.Src)
+			compareImages(t, got, want)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package app
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	bar "github.com/schollz/progressbar/v3"
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+	"github.com/ulikunitz/xz/lzma"
+	"golang.org/x/image/bmp"
+)
+
+// cipMarker is the constant that follows the zero padding of a CIP header.
+var cipMarker = []byte{0x70, 0x0A, 0xFA, 0x80, 0x24}
+
+// cipHeaderSize is the size of padding, marker and 7-bit length combined.
+const cipHeaderSize = 32
+
+// PackSprites is the reverse of extract: it turns the sheets written by
+// extract back into compressed client assets plus a matching
+// "catalog-content.json" in outputDir.
+func PackSprites(sheetsDir, outputDir string) (Result, error) {
+	var res Result
+
+	sheets, err := scanLayout(sheetsDir, spriteFilePattern, sheetNamePattern)
+	if err != nil {
+		log.Err(err).
+			Str("sheetsDir", sheetsDir).
+			Msg("Failed to read directory. Did you run the extract command?")
+		return res, fmt.Errorf("read %s: %w", sheetsDir, err)
+	}
+
+	total := len(sheets)
+	if total == 0 {
+		log.Warn().
+			Str("sheetsDir", sheetsDir).
+			Msg("No sprites found to pack. Did you run the extract command?")
+		return res, fmt.Errorf("%w in %s", ErrNoSprites, sheetsDir)
+	}
+
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		log.Err(err).Str("outputDir", outputDir).Msg("failed to create output directory")
+		return res, fmt.Errorf("create %s: %w", outputDir, err)
+	}
+
+	progress := bar.NewOptions(
+		total,
+		bar.OptionSetDescription("Packing sprites"),
+		bar.OptionShowCount(),
+		bar.OptionShowIts(),
+		bar.OptionSetItsString("files"),
+		bar.OptionThrottle(100),
+		bar.OptionClearOnFinish(),
+	)
+
+	catalog := make([]sprites.CatalogElem, 0, total)
+	for _, sheet := range sheets {
+		first, err1 := strconv.Atoi(sheet.vars["first"])
+		last, err2 := strconv.Atoi(sheet.vars["last"])
+		if err1 != nil || err2 != nil {
+			log.Error().Str("file", sheet.name).Msg("invalid numeric part in filename")
+			res.fail(sheet.name, errors.Join(err1, err2))
+			_ = progress.Add(1)
+			continue
+		}
+		spriteType, ok := sheetSpriteType(sheet.vars["type"])
+		if !ok {
+			spriteType = guessSpriteType(last - first + 1)
+		}
+
+		elem := sprites.CatalogElem{
+			Type:          "sprite",
+			File:          packedFileName(first, last),
+			SpriteType:    spriteType,
+			FirstSpriteId: first,
+			LastSpriteId:  last,
+		}
+		if err := packAsset(filepath.Join(sheetsDir, filepath.FromSlash(sheet.name)), filepath.Join(outputDir, elem.File)); err != nil {
+			log.Error().Str("file", sheet.name).Err(err).Msg("failed to pack")
+			res.fail(sheet.name, err)
+			_ = progress.Add(1)
+			continue
+		}
+		catalog = append(catalog, elem)
+		res.Processed++
+		_ = progress.Add(1)
+	}
+	_ = progress.Finish()
+
+	sort.Slice(catalog, func(i, j int) bool {
+		return catalog[i].FirstSpriteId < catalog[j].FirstSpriteId
+	})
+	if err := writeCatalogContent(filepath.Join(outputDir, "catalog-content.json"), catalog); err != nil {
+		log.Err(err).Msg("failed to write catalog-content.json")
+		return res, fmt.Errorf("write catalog: %w", err)
+	}
+
+	log.Info().
+		Int("packed", res.Processed).
+		Int("failed", res.Failed).
+		Str("outputDir", outputDir).
+		Msg("Packing sprites finished")
+
+	return res, nil
+}
+
+func packedFileName(firstID, lastID int) string {
+	return fmt.Sprintf("sprites-%d-%d.bmp.lzma", firstID, lastID)
+}
+
+func packAsset(inPath, outPath string) error {
+	img, err := loadImage(inPath)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := encodeAsset(&buf, img); err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, buf.Bytes(), 0o644)
+}
+
+// encodeAsset is the inverse of sprites.DecodeAsset:
+//  1. encode the sheet as BMP
+//  2. compress it with LZMA
+//  3. write the CIP header: zero padding, marker, 7-bit length
+//  4. write the LZMA props and the compressed size in place of the
+//     uncompressed one, as the client does
+//  5. write the compressed stream
+func encodeAsset(w io.Writer, img image.Image) error {
+	var bmpBuf bytes.Buffer
+	if err := bmp.Encode(&bmpBuf, img); err != nil {
+		return fmt.Errorf("bmp encode: %w", err)
+	}
+
+	// The writer emits an "LZMA alone" stream: 5 bytes props, 8 bytes size
+	// (unknown), then the data terminated by an end-of-stream marker.
+	var lzBuf bytes.Buffer
+	lz, err := lzma.NewWriter(&lzBuf)
+	if err != nil {
+		return fmt.Errorf("lzma writer: %w", err)
+	}
+	if _, err := lz.Write(bmpBuf.Bytes()); err != nil {
+		return fmt.Errorf("lzma encode: %w", err)
+	}
+	if err := lz.Close(); err != nil {
+		return fmt.Errorf("lzma encode: %w", err)
+	}
+	props, stream := lzBuf.Bytes()[:5], lzBuf.Bytes()[13:]
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(stream)))
+
+	length := encode7BitLength(len(props) + len(size) + len(stream))
+	padding := cipHeaderSize - len(cipMarker) - len(length)
+
+	for _, part := range [][]byte{make([]byte, padding), cipMarker, length, props, size[:], stream} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encode7BitLength writes v 7 bits at a time, least significant group first,
+// with the MSB set on every byte but the last.
+func encode7BitLength(v int) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7F)
+		v >>= 7
+		if v != 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if v == 0 {
+			return out
+		}
+	}
+}
+
+func writeCatalogContent(path string, elems []sprites.CatalogElem) error {
+	data, err := json.MarshalIndent(elems, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
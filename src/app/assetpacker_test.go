@@ -0,0 +1,122 @@
+package app
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
+
+func TestEncodeAssetRoundTripsThroughDecodeAsset(t *testing.T) {
+	src := newTestImage(64, 32)
+
+	var buf bytes.Buffer
+	if err := encodeAsset(&buf, src); err != nil {
+		t.Fatalf("encodeAsset error: %v", err)
+	}
+
+	data := buf.Bytes()
+	if !bytes.Equal(data[cipHeaderSize-len(cipMarker)-2:cipHeaderSize-2], cipMarker) {
+		t.Fatalf("CIP marker not found where expected: % x", data[:cipHeaderSize])
+	}
+
+	got, err := sprites.DecodeAsset(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DecodeAsset error: %v", err)
+	}
+	compareImages(t, got, src)
+}
+
+func TestEncodeAssetHeaderLengthCoversTheRest(t *testing.T) {
+	var buf bytes.Buffer
+	if err := encodeAsset(&buf, newTestImage(4, 4)); err != nil {
+		t.Fatalf("encodeAsset error: %v", err)
+	}
+
+	data := buf.Bytes()
+	header := append(append([]byte(nil), cipMarker...), encode7BitLength(len(data)-cipHeaderSize)...)
+	padding := cipHeaderSize - len(header)
+	if !bytes.Equal(data[:padding], make([]byte, padding)) || !bytes.Equal(data[padding:cipHeaderSize], header) {
+		t.Fatalf("header = % x, want %d zeros then % x", data[:cipHeaderSize], padding, header)
+	}
+}
+
+func TestEncode7BitLength(t *testing.T) {
+	for _, v := range []int{0, 1, 127, 128, 300, 1 << 20} {
+		if got, want := encode7BitLength(v), encodeVarint7bit(v); !bytes.Equal(got, want) {
+			t.Fatalf("encode7BitLength(%d) = % x, want % x", v, got, want)
+		}
+	}
+}
+
+func TestPackSpritesRoundTripsThroughExtract(t *testing.T) {
+	assetsDir := t.TempDir()
+	firstExtract := t.TempDir()
+	packedDir := t.TempDir()
+	secondExtract := t.TempDir()
+
+	transparent := image.NewNRGBA(image.Rect(0, 0, 384, 384))
+	for y := 0; y < 384; y++ {
+		for x := 0; x < 384; x++ {
+			transparent.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 7, A: uint8((x + y) % 256)})
+		}
+	}
+	writeCIPFile(t, assetsDir, "a.bin", makeCIPAssetFromImage(t, newTestImage(384, 384)))
+	writeCIPFile(t, assetsDir, "b.bin", makeCIPAssetFromImage(t, transparent))
+	catalog := writeTempFile(t, assetsDir, "catalog-content.json", `[
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":144,"area":0},
+                {"type":"sprite","file":"b.bin","spritetype":2,"firstspriteid":145,"lastspriteid":216,"area":0}
+        ]`)
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	if res, err := ConvertAssetsFromCatalogContent(assetsDir, catalog, firstExtract, ExtractOptions{Workers: 1}); err != nil || res.Failed != 0 || res.Processed != 2 {
+		t.Fatalf("first extract = %+v, %v, want 2 sheets", res, err)
+	}
+	if res, err := PackSprites(firstExtract, packedDir); err != nil || res.Failed != 0 || res.Processed != 2 {
+		t.Fatalf("PackSprites = %+v, %v, want 2 sheets", res, err)
+	}
+	if res, err := ConvertAssetsFromCatalogContent(packedDir, filepath.Join(packedDir, "catalog-content.json"), secondExtract, ExtractOptions{Workers: 1}); err != nil || res.Failed != 0 || res.Processed != 2 {
+		t.Fatalf("second extract = %+v, %v, want 2 sheets", res, err)
+	}
+
+	for _, name := range []string{"Sprites-1-144-32x32.png", "Sprites-145-216-64x32.png"} {
+		want, err := os.ReadFile(filepath.Join(firstExtract, name))
+		if err != nil {
+			t.Fatalf("read first extract %s: %v", name, err)
+		}
+		got, err := os.ReadFile(filepath.Join(secondExtract, name))
+		if err != nil {
+			t.Fatalf("read second extract %s: %v", name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s is not byte-identical after pack and extract", name)
+		}
+	}
+
+	out, errs := StreamCatalogContent(filepath.Join(packedDir, "catalog-content.json"))
+	var elems []sprites.CatalogElem
+	for e := range out {
+		elems = append(elems, e)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("read packed catalog: %v", err)
+	}
+	want := []sprites.CatalogElem{
+		{Type: "sprite", File: "sprites-1-144.bmp.lzma", SpriteType: sprites.SpriteType32x32, FirstSpriteId: 1, LastSpriteId: 144},
+		{Type: "sprite", File: "sprites-145-216.bmp.lzma", SpriteType: sprites.SpriteType64x32, FirstSpriteId: 145, LastSpriteId: 216},
+	}
+	if len(elems) != len(want) {
+		t.Fatalf("packed catalog = %+v, want %+v", elems, want)
+	}
+	for i := range want {
+		if elems[i] != want[i] {
+			t.Fatalf("packed catalog[%d] = %+v, want %+v", i, elems[i], want[i])
+		}
+	}
+}
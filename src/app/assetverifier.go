@@ -0,0 +1,92 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	bar "github.com/schollz/progressbar/v3"
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
+
+// AssetReport is the outcome of verifying one catalog sprite entry.
+type AssetReport struct {
+	Elem     sprites.CatalogElem
+	Problems []string
+}
+
+func (r AssetReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// VerifyAssets checks every sprite entry of the catalog without writing
+// anything. The returned error is only set when the catalog itself cannot be
+// read; per-file problems are recorded in the reports.
+func VerifyAssets(assetsPath, contentJsonFullPath string) ([]AssetReport, error) {
+	total, err := CountSpriteEntries(contentJsonFullPath)
+	if err != nil {
+		return nil, err
+	}
+	progress := bar.NewOptions(
+		total,
+		bar.OptionSetDescription("Verifying sprites"),
+		bar.OptionShowCount(),
+		bar.OptionShowIts(),
+		bar.OptionSetItsString("sprites"),
+		bar.OptionThrottle(100),
+		bar.OptionClearOnFinish(),
+	)
+
+	reports := make([]AssetReport, 0, total)
+	elems, errs := StreamCatalogContent(contentJsonFullPath)
+	for e := range elems {
+		if e.Type != "sprite" {
+			log.Debug().Msgf("skip type=%s file=%s", e.Type, e.File)
+			continue
+		}
+		reports = append(reports, verifyAsset(assetsPath, e))
+		_ = progress.Add(1)
+	}
+	_ = progress.Finish()
+
+	if err := <-errs; err != nil {
+		return reports, fmt.Errorf("read catalog: %w", err)
+	}
+	return reports, nil
+}
+
+// verifyAsset decodes the asset like extract does, so the report names the
+// failed stage through its AssetDecodeError, then checks the sheet size and
+// that the sprite range fits it.
+func verifyAsset(assetsPath string, e sprites.CatalogElem) AssetReport {
+	report := AssetReport{Elem: e}
+	fail := func(format string, args ...any) AssetReport {
+		report.Problems = append(report.Problems, fmt.Sprintf(format, args...))
+		return report
+	}
+
+	f, err := os.Open(filepath.Join(assetsPath, e.File))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fail("file does not exist")
+		}
+		return fail("open: %v", err)
+	}
+	defer f.Close()
+
+	img, err := sprites.DecodeAsset(f)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	b := img.Bounds()
+	if b.Dx() != sprites.SheetSize || b.Dy() != sprites.SheetSize {
+		fail("BMP is %dx%d, want %dx%d", b.Dx(), b.Dy(), sprites.SheetSize, sprites.SheetSize)
+	}
+	count := e.LastSpriteId - e.FirstSpriteId + 1
+	if capacity := sprites.SheetCapacity(b, e.SpriteType); count < 1 || count > capacity {
+		fail("%d sprites do not fit a %s sheet holding %d", count, e.SpriteType, capacity)
+	}
+	return report
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyAssetsReportsProblemsPerFile(t *testing.T) {
+	assetsDir := t.TempDir()
+
+	writeCIPFile(t, assetsDir, "good.bin", makeCIPAssetFromImage(t, newTestImage(384, 384)))
+	writeCIPFile(t, assetsDir, "small.bin", makeCIPAssetFromImage(t, newTestImage(64, 64)))
+	writeCIPFile(t, assetsDir, "notbmp.bin", makeCIPAssetFromBytes(t, []byte("not a bmp")))
+	writeCIPFile(t, assetsDir, "header.bin", []byte{0x00, 0x00})
+	truncated := makeCIPAssetFromImage(t, newTestImage(384, 384))
+	writeCIPFile(t, assetsDir, "truncated.bin", truncated[:len(truncated)/2])
+	writeCIPFile(t, assetsDir, "overfull.bin", makeCIPAssetFromImage(t, newTestImage(384, 384)))
+
+	catalog := writeTempFile(t, assetsDir, "catalog-content.json", `[
+                {"type":"appearances","file":"appearances.dat"},
+                {"type":"sprite","file":"good.bin","spritetype":3,"firstspriteid":1,"lastspriteid":36},
+                {"type":"sprite","file":"missing.bin","spritetype":0,"firstspriteid":37,"lastspriteid":40},
+                {"type":"sprite","file":"small.bin","spritetype":0,"firstspriteid":41,"lastspriteid":42},
+                {"type":"sprite","file":"notbmp.bin","spritetype":0,"firstspriteid":43,"lastspriteid":44},
+                {"type":"sprite","file":"header.bin","spritetype":0,"firstspriteid":45,"lastspriteid":46},
+                {"type":"sprite","file":"truncated.bin","spritetype":0,"firstspriteid":47,"lastspriteid":48},
+                {"type":"sprite","file":"overfull.bin","spritetype":3,"firstspriteid":49,"lastspriteid":85}
+        ]`)
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	reports, err := VerifyAssets(assetsDir, catalog)
+	if err != nil {
+		t.Fatalf("VerifyAssets error: %v", err)
+	}
+
+	want := map[string]string{
+		"good.bin":      "",
+		"missing.bin":   "file does not exist",
+		"small.bin":     "BMP is 64x64",
+		"notbmp.bin":    "BMP:",
+		"header.bin":    "CIP header",
+		"truncated.bin": "LZMA stream",
+		"overfull.bin":  "37 sprites do not fit a 64x64 sheet holding 36",
+	}
+	if len(reports) != len(want) {
+		t.Fatalf("got %d reports, want %d", len(reports), len(want))
+	}
+	for _, r := range reports {
+		substr, ok := want[r.Elem.File]
+		if !ok {
+			t.Fatalf("unexpected report for %s", r.Elem.File)
+		}
+		if substr == "" {
+			if !r.OK() {
+				t.Fatalf("%s: unexpected problems %v", r.Elem.File, r.Problems)
+			}
+			continue
+		}
+		if r.OK() || !strings.Contains(strings.Join(r.Problems, "; "), substr) {
+			t.Fatalf("%s: problems %v, want one containing %q", r.Elem.File, r.Problems, substr)
+		}
+	}
+}
+
+func TestVerifyAssetsErrorsWhenCatalogMissing(t *testing.T) {
+	if _, err := VerifyAssets(t.TempDir(), "/nonexistent/catalog-content.json"); err == nil {
+		t.Fatalf("expected error for missing catalog")
+	}
+}
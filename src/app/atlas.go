@@ -0,0 +1,374 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	bar "github.com/schollz/progressbar/v3"
+)
+
+// ErrNoSplitSprites is returned when a directory holds no split sprites.
+var ErrNoSplitSprites = errors.New("no split sprites found")
+
+// splitFilePattern matches the per-sprite images written by split.
+var splitFilePattern = regexp.MustCompile(`^` + splitNamePattern + imageExtPattern + `$`)
+
+// splitNamePattern matches the built-in sprite name inside a custom layout.
+const splitNamePattern = `(?P<id>\d+)`
+
+const defaultAtlasMaxSize = 2048
+
+// AtlasOptions tunes BuildAtlas.
+type AtlasOptions struct {
+	// MaxSize is the largest page width and height. It must be a power of
+	// two; zero means 2048.
+	MaxSize int
+	// Padding is the number of transparent pixels around every frame.
+	Padding int
+	// Trim drops the transparent border of every sprite. The frame metadata
+	// records where the trimmed frame sits in the original sprite.
+	Trim bool
+	// IDs selects the sprites to pack; empty packs every sprite.
+	IDs IDRanges
+}
+
+// atlasFrame is one sprite on its way into an atlas page.
+type atlasFrame struct {
+	id     int
+	file   string
+	source image.Point     // size of the sprite PNG
+	trim   image.Rectangle // part of the sprite that is packed
+	page   int
+	at     image.Point // position of trim in the page
+}
+
+// BuildAtlas packs the sprites written by split into power-of-two atlas pages
+// "atlas-<n>.png", each with frame metadata "atlas-<n>.json" in the
+// TexturePacker JSON hash format keyed by sprite ID. Per-sprite failures are
+// collected in the Result; the error is set when the options are invalid or
+// there is nothing to pack.
+func BuildAtlas(splitDir, outputDir string, opts AtlasOptions) (Result, error) {
+	var res Result
+
+	if opts.MaxSize == 0 {
+		opts.MaxSize = defaultAtlasMaxSize
+	}
+	if opts.MaxSize < 1 || opts.MaxSize&(opts.MaxSize-1) != 0 {
+		return res, fmt.Errorf("atlas size %d is not a power of two", opts.MaxSize)
+	}
+	if opts.Padding < 0 || 2*opts.Padding >= opts.MaxSize {
+		return res, fmt.Errorf("padding %d does not fit a %d atlas", opts.Padding, opts.MaxSize)
+	}
+
+	sprites, err := splitSpriteFiles(splitDir)
+	if err != nil {
+		log.Err(err).
+			Str("splitDir", splitDir).
+			Msg("Failed to read directory. Did you run the split command?")
+		return res, fmt.Errorf("read %s: %w", splitDir, err)
+	}
+	var files []atlasFrame
+	found := make(map[int]bool)
+	listed := make(map[string]bool)
+	for id, name := range sprites {
+		if !opts.IDs.Contains(id) {
+			continue
+		}
+		files = append(files, atlasFrame{id: id, file: name})
+		found[id] = true
+		listed[name] = true
+	}
+	// Sprites a json dedup run of split skipped reuse the frame of the file
+	// holding their pixels instead of being packed again.
+	var aliases []atlasFrame
+	for id, first := range duplicateSprites(splitDir) {
+		switch {
+		case found[id] || !opts.IDs.Contains(id):
+		case listed[first]:
+			aliases = append(aliases, atlasFrame{id: id, file: first})
+		default:
+			files = append(files, atlasFrame{id: id, file: first})
+			listed[first] = true
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].id < files[j].id })
+	if len(files) == 0 {
+		log.Warn().
+			Str("splitDir", splitDir).
+			Msg("No sprites found to pack. Did you run the split command?")
+		return res, fmt.Errorf("%w in %s", ErrNoSplitSprites, splitDir)
+	}
+
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		log.Err(err).Str("outputDir", outputDir).Msg("failed to create output directory")
+		return res, fmt.Errorf("create %s: %w", outputDir, err)
+	}
+
+	frames := measureAtlasFrames(splitDir, files, opts.Trim, &res)
+	pages := packAtlasFrames(frames, opts, &res)
+	aliasAtlasFrames(pages, aliases)
+
+	total := 0
+	for _, pageFrames := range pages {
+		total += len(pageFrames)
+	}
+	progress := bar.NewOptions(
+		total,
+		bar.OptionSetDescription("Writing atlas"),
+		bar.OptionShowCount(),
+		bar.OptionShowIts(),
+		bar.OptionSetItsString("sprites"),
+		bar.OptionThrottle(100),
+		bar.OptionClearOnFinish(),
+	)
+	for page, pageFrames := range pages {
+		if err := writeAtlasPage(splitDir, outputDir, page, pageFrames, opts.Padding, &res, progress); err != nil {
+			log.Err(err).Int("page", page).Msg("failed to write atlas page")
+			res.fail(atlasPageName(page), err)
+		}
+	}
+	_ = progress.Finish()
+
+	log.Info().
+		Int("packed", res.Processed).
+		Int("pages", len(pages)).
+		Int("failed", res.Failed).
+		Str("outputDir", outputDir).
+		Msg("Building atlas finished")
+
+	return res, nil
+}
+
+// measureAtlasFrames reads the size of every sprite and, when trimming, the
+// bounds of its opaque pixels.
+func measureAtlasFrames(splitDir string, files []atlasFrame, trim bool, res *Result) []atlasFrame {
+	frames := make([]atlasFrame, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(splitDir, filepath.FromSlash(f.file))
+		if trim {
+			img, err := loadImage(path)
+			if err != nil {
+				log.Error().Str("file", f.file).Err(err).Msg("failed to read sprite")
+				res.fail(f.file, err)
+				continue
+			}
+			b := img.Bounds()
+			f.source = b.Size()
+			f.trim = opaqueBounds(img).Sub(b.Min)
+		} else {
+			cfg, err := decodePNGConfig(path)
+			if err != nil {
+				log.Error().Str("file", f.file).Err(err).Msg("failed to read sprite")
+				res.fail(f.file, err)
+				continue
+			}
+			f.source = image.Pt(cfg.Width, cfg.Height)
+			f.trim = image.Rect(0, 0, cfg.Width, cfg.Height)
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+// aliasAtlasFrames adds every alias to the page of the packed frame read
+// from the same file, at the same place.
+func aliasAtlasFrames(pages [][]atlasFrame, aliases []atlasFrame) {
+	packed := make(map[string]atlasFrame)
+	for _, frames := range pages {
+		for _, f := range frames {
+			packed[f.file] = f
+		}
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].id < aliases[j].id })
+	for _, a := range aliases {
+		f, ok := packed[a.file]
+		if !ok {
+			continue
+		}
+		f.id = a.id
+		pages[f.page] = append(pages[f.page], f)
+	}
+}
+
+// packAtlasFrames assigns every frame a page and a position, largest frames
+// first, and returns the frames of each page.
+func packAtlasFrames(frames []atlasFrame, opts AtlasOptions, res *Result) [][]atlasFrame {
+	sort.SliceStable(frames, func(i, j int) bool {
+		a, b := frames[i].trim.Size(), frames[j].trim.Size()
+		if a.Y != b.Y {
+			return a.Y > b.Y
+		}
+		if a.X != b.X {
+			return a.X > b.X
+		}
+		return frames[i].id < frames[j].id
+	})
+
+	// Every frame reserves its padding on the right and bottom; the bin is
+	// shifted by the padding so the top and left edges are padded too.
+	binSize := opts.MaxSize - opts.Padding
+	var pages [][]atlasFrame
+	var bin *maxRectsBin
+	for _, f := range frames {
+		w, h := f.trim.Dx()+opts.Padding, f.trim.Dy()+opts.Padding
+		if w > binSize || h > binSize {
+			err := fmt.Errorf("%dx%d sprite does not fit a %d atlas", f.trim.Dx(), f.trim.Dy(), opts.MaxSize)
+			log.Error().Str("file", f.file).Err(err).Msg("failed to pack sprite")
+			res.fail(f.file, err)
+			continue
+		}
+		at, ok := image.Point{}, false
+		if bin != nil {
+			at, ok = bin.insert(w, h)
+		}
+		if !ok {
+			bin = newMaxRectsBin(binSize, binSize)
+			pages = append(pages, nil)
+			at, _ = bin.insert(w, h)
+		}
+		f.page = len(pages) - 1
+		f.at = at.Add(image.Pt(opts.Padding, opts.Padding))
+		pages[f.page] = append(pages[f.page], f)
+	}
+	return pages
+}
+
+func writeAtlasPage(splitDir, outputDir string, page int, frames []atlasFrame, padding int, res *Result, progress *bar.ProgressBar) error {
+	var used image.Point
+	for _, f := range frames {
+		used.X = max(used.X, f.at.X+f.trim.Dx()+padding)
+		used.Y = max(used.Y, f.at.Y+f.trim.Dy()+padding)
+	}
+	size := image.Pt(nextPowerOfTwo(used.X), nextPowerOfTwo(used.Y))
+	dst := image.NewNRGBA(image.Rectangle{Max: size})
+
+	name := atlasPageName(page)
+	meta := atlasJSON{
+		Frames: make(map[string]atlasFrameJSON, len(frames)),
+		Meta: atlasMeta{
+			App:    "https://github.com/tilaven/tibia-sprites-exporter",
+			Image:  name + ".png",
+			Format: "RGBA8888",
+			Size:   atlasSize{W: size.X, H: size.Y},
+			Scale:  "1",
+		},
+	}
+	for _, f := range frames {
+		img, err := loadImage(filepath.Join(splitDir, filepath.FromSlash(f.file)))
+		if err != nil {
+			log.Error().Str("file", f.file).Err(err).Msg("failed to read sprite")
+			res.fail(f.file, err)
+			_ = progress.Add(1)
+			continue
+		}
+		target := image.Rectangle{Min: f.at, Max: f.at.Add(f.trim.Size())}
+		draw.Draw(dst, target, img, img.Bounds().Min.Add(f.trim.Min), draw.Src)
+
+		meta.Frames[strconv.Itoa(f.id)] = atlasFrameJSON{
+			Frame:            atlasRect{X: f.at.X, Y: f.at.Y, W: f.trim.Dx(), H: f.trim.Dy()},
+			Trimmed:          f.trim.Size() != f.source,
+			SpriteSourceSize: atlasRect{X: f.trim.Min.X, Y: f.trim.Min.Y, W: f.trim.Dx(), H: f.trim.Dy()},
+			SourceSize:       atlasSize{W: f.source.X, H: f.source.Y},
+		}
+		res.Processed++
+		_ = progress.Add(1)
+	}
+
+	if err := (Output{}).writeImage(outputDir, name+".png", dst); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(meta, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outputDir, name+".json"), data, 0o644)
+}
+
+func atlasPageName(page int) string {
+	return fmt.Sprintf("atlas-%d", page)
+}
+
+// atlasJSON is the TexturePacker "JSON hash" format read by Phaser, PixiJS
+// and most other engines.
+type atlasJSON struct {
+	Frames map[string]atlasFrameJSON `json:"frames"`
+	Meta   atlasMeta                 `json:"meta"`
+}
+
+type atlasFrameJSON struct {
+	Frame            atlasRect `json:"frame"`
+	Rotated          bool      `json:"rotated"`
+	Trimmed          bool      `json:"trimmed"`
+	SpriteSourceSize atlasRect `json:"spriteSourceSize"`
+	SourceSize       atlasSize `json:"sourceSize"`
+}
+
+type atlasMeta struct {
+	App    string    `json:"app"`
+	Image  string    `json:"image"`
+	Format string    `json:"format"`
+	Size   atlasSize `json:"size"`
+	Scale  string    `json:"scale"`
+}
+
+type atlasRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type atlasSize struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// opaqueBounds is the smallest rectangle holding every non-transparent pixel
+// of img. Fully transparent images keep their top-left pixel, so every frame
+// has a size.
+func opaqueBounds(img image.Image) image.Rectangle {
+	b := img.Bounds()
+	out := image.Rectangle{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				out = out.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if out.Empty() {
+		return image.Rectangle{Min: b.Min, Max: b.Min.Add(image.Pt(1, 1))}
+	}
+	return out
+}
+
+func nextPowerOfTwo(v int) int {
+	p := 1
+	for p < v {
+		p <<= 1
+	}
+	return p
+}
+
+func decodePNGConfig(path string) (image.Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return image.Config{}, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return cfg, nil
+}
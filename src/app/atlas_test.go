@@ -0,0 +1,150 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readAtlasJSON(t *testing.T, path string) atlasJSON {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	var meta atlasJSON
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return meta
+}
+
+func TestBuildAtlasTrimsAndPadsFrames(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	splitDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeSolidTile(t, splitDir, 1, color.NRGBA{R: 255, A: 255}, 32)
+	writeSolidTile(t, splitDir, 2, color.NRGBA{G: 255, A: 255}, 64)
+	// Sprite 3 only has a 4x2 opaque block at (10, 20).
+	sparse := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	for y := 20; y < 22; y++ {
+		for x := 10; x < 14; x++ {
+			sparse.SetNRGBA(x, y, color.NRGBA{B: 255, A: 255})
+		}
+	}
+	writeTestPNG(t, filepath.Join(splitDir, "3.png"), sparse)
+
+	res, err := BuildAtlas(splitDir, outputDir, AtlasOptions{MaxSize: 256, Padding: 2, Trim: true})
+	if err != nil {
+		t.Fatalf("BuildAtlas error: %v", err)
+	}
+	if res.Processed != 3 || res.Failed != 0 {
+		t.Fatalf("result = %+v, want 3 processed", res)
+	}
+
+	meta := readAtlasJSON(t, filepath.Join(outputDir, "atlas-0.json"))
+	if meta.Meta.Image != "atlas-0.png" || meta.Meta.Size != (atlasSize{W: 128, H: 128}) {
+		t.Fatalf("meta = %+v, want atlas-0.png of 128x128", meta.Meta)
+	}
+	if len(meta.Frames) != 3 {
+		t.Fatalf("frames = %+v, want 3", meta.Frames)
+	}
+
+	trimmed := meta.Frames["3"]
+	if !trimmed.Trimmed || trimmed.SpriteSourceSize != (atlasRect{X: 10, Y: 20, W: 4, H: 2}) || trimmed.SourceSize != (atlasSize{W: 32, H: 32}) {
+		t.Fatalf("frame 3 = %+v, want trimmed to 4x2 at (10,20)", trimmed)
+	}
+	if meta.Frames["1"].Trimmed || meta.Frames["1"].Frame.W != 32 {
+		t.Fatalf("frame 1 = %+v, want untrimmed 32x32", meta.Frames["1"])
+	}
+
+	page := decodePNG(t, filepath.Join(outputDir, "atlas-0.png"))
+	wantColors := map[string]color.NRGBA{
+		"1": {R: 255, A: 255},
+		"2": {G: 255, A: 255},
+		"3": {B: 255, A: 255},
+	}
+	var rects []image.Rectangle
+	for id, f := range meta.Frames {
+		r := image.Rect(f.Frame.X, f.Frame.Y, f.Frame.X+f.Frame.W, f.Frame.Y+f.Frame.H)
+		if r.Min.X < 2 || r.Min.Y < 2 {
+			t.Fatalf("frame %s at %v ignores the padding", id, r)
+		}
+		for _, other := range rects {
+			if r.Inset(-2).Overlaps(other) {
+				t.Fatalf("frame %s at %v is closer than the padding to %v", id, r, other)
+			}
+		}
+		rects = append(rects, r)
+		got := color.NRGBAModel.Convert(page.At(r.Min.X, r.Min.Y)).(color.NRGBA)
+		if got != wantColors[id] {
+			t.Fatalf("frame %s pixel = %v, want %v", id, got, wantColors[id])
+		}
+	}
+}
+
+func TestBuildAtlasSpillsToNewPagesAndFiltersIDs(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	splitDir := t.TempDir()
+	outputDir := t.TempDir()
+	for id := 1; id <= 6; id++ {
+		writeSolidTile(t, splitDir, id, color.NRGBA{R: uint8(id), A: 255}, 32)
+	}
+	writeSolidTile(t, splitDir, 100, color.NRGBA{A: 255}, 32)
+
+	ids, err := ParseIDRanges("1-5")
+	if err != nil {
+		t.Fatalf("ParseIDRanges error: %v", err)
+	}
+	res, err := BuildAtlas(splitDir, outputDir, AtlasOptions{MaxSize: 64, IDs: ids})
+	if err != nil {
+		t.Fatalf("BuildAtlas error: %v", err)
+	}
+	if res.Processed != 5 {
+		t.Fatalf("result = %+v, want 5 processed", res)
+	}
+
+	first := readAtlasJSON(t, filepath.Join(outputDir, "atlas-0.json"))
+	second := readAtlasJSON(t, filepath.Join(outputDir, "atlas-1.json"))
+	if len(first.Frames) != 4 || len(second.Frames) != 1 {
+		t.Fatalf("pages hold %d and %d frames, want 4 and 1", len(first.Frames), len(second.Frames))
+	}
+	if second.Meta.Size != (atlasSize{W: 32, H: 32}) {
+		t.Fatalf("second page size = %+v, want 32x32", second.Meta.Size)
+	}
+	if _, ok := second.Frames["5"]; !ok {
+		t.Fatalf("second page frames = %+v, want sprite 5", second.Frames)
+	}
+}
+
+func TestBuildAtlasValidatesOptions(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	splitDir := t.TempDir()
+	if _, err := BuildAtlas(splitDir, t.TempDir(), AtlasOptions{MaxSize: 1000}); err == nil {
+		t.Fatalf("expected error for a size that is not a power of two")
+	}
+	if _, err := BuildAtlas(splitDir, t.TempDir(), AtlasOptions{}); !errors.Is(err, ErrNoSplitSprites) {
+		t.Fatalf("error = %v, want ErrNoSplitSprites", err)
+	}
+
+	writeSolidTile(t, splitDir, 1, color.NRGBA{A: 255}, 64)
+	res, err := BuildAtlas(splitDir, t.TempDir(), AtlasOptions{MaxSize: 32})
+	if err != nil {
+		t.Fatalf("BuildAtlas error: %v", err)
+	}
+	if res.Failed != 1 {
+		t.Fatalf("result = %+v, want the oversized sprite to fail", res)
+	}
+}
@@ -0,0 +1,99 @@
+package app
+
+import "image"
+
+// maxRectsBin packs rectangles into a fixed size bin with the MaxRects
+// algorithm, placing each one by the best short side fit. It keeps the list
+// of maximal free rectangles; placing a rectangle splits every free rectangle
+// it overlaps and then drops free rectangles contained in others.
+type maxRectsBin struct {
+	free []image.Rectangle
+}
+
+func newMaxRectsBin(width, height int) *maxRectsBin {
+	return &maxRectsBin{free: []image.Rectangle{image.Rect(0, 0, width, height)}}
+}
+
+// insert places a w x h rectangle and returns its position, or false when it
+// does not fit anywhere.
+func (b *maxRectsBin) insert(w, h int) (image.Point, bool) {
+	best := -1
+	bestShort, bestLong := 0, 0
+	for i, f := range b.free {
+		dw, dh := f.Dx()-w, f.Dy()-h
+		if dw < 0 || dh < 0 {
+			continue
+		}
+		short, long := min(dw, dh), max(dw, dh)
+		if best < 0 || short < bestShort || (short == bestShort && long < bestLong) {
+			best, bestShort, bestLong = i, short, long
+		}
+	}
+	if best < 0 {
+		return image.Point{}, false
+	}
+
+	at := b.free[best].Min
+	b.place(image.Rectangle{Min: at, Max: at.Add(image.Pt(w, h))})
+	return at, true
+}
+
+func (b *maxRectsBin) place(used image.Rectangle) {
+	var kept, split []image.Rectangle
+	for _, f := range b.free {
+		if !f.Overlaps(used) {
+			kept = append(kept, f)
+			continue
+		}
+		// Keep the parts of f on each side of used.
+		if used.Min.X > f.Min.X {
+			split = append(split, image.Rect(f.Min.X, f.Min.Y, used.Min.X, f.Max.Y))
+		}
+		if used.Max.X < f.Max.X {
+			split = append(split, image.Rect(used.Max.X, f.Min.Y, f.Max.X, f.Max.Y))
+		}
+		if used.Min.Y > f.Min.Y {
+			split = append(split, image.Rect(f.Min.X, f.Min.Y, f.Max.X, used.Min.Y))
+		}
+		if used.Max.Y < f.Max.Y {
+			split = append(split, image.Rect(f.Min.X, used.Max.Y, f.Max.X, f.Max.Y))
+		}
+	}
+
+	// Drop free rectangles contained in another one. The untouched ones were
+	// already maximal among themselves, so only pairs involving a new one
+	// need checking.
+	var fresh []image.Rectangle
+	for i, f := range split {
+		contained := false
+		for j, g := range split {
+			if i != j && f.In(g) && (f != g || i > j) {
+				contained = true
+				break
+			}
+		}
+		for _, g := range kept {
+			if contained {
+				break
+			}
+			contained = f.In(g)
+		}
+		if !contained {
+			fresh = append(fresh, f)
+		}
+	}
+	b.free = kept[:0]
+	for _, f := range kept {
+		contained := false
+		for _, g := range fresh {
+			if f.In(g) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			b.free = append(b.free, f)
+		}
+	}
+	b.free = append(b.free, fresh...)
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"image"
+	"testing"
+)
+
+func TestMaxRectsBinFillsWithoutOverlap(t *testing.T) {
+	bin := newMaxRectsBin(128, 128)
+	var placed []image.Rectangle
+	for i := 0; i < 16; i++ {
+		w, h := 32, 32
+		if i%3 == 0 {
+			w = 64
+		}
+		at, ok := bin.insert(w, h)
+		if !ok {
+			break
+		}
+		r := image.Rectangle{Min: at, Max: at.Add(image.Pt(w, h))}
+		if !r.In(image.Rect(0, 0, 128, 128)) {
+			t.Fatalf("rect %v outside the bin", r)
+		}
+		for _, p := range placed {
+			if r.Overlaps(p) {
+				t.Fatalf("rect %v overlaps %v", r, p)
+			}
+		}
+		placed = append(placed, r)
+	}
+
+	area := 0
+	for _, r := range placed {
+		area += r.Dx() * r.Dy()
+	}
+	if area != 128*128 {
+		t.Fatalf("packed area = %d, want the bin filled", area)
+	}
+	if _, ok := bin.insert(1, 1); ok {
+		t.Fatalf("insert into a full bin succeeded")
+	}
+}
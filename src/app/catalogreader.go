@@ -6,21 +6,14 @@ import (
 	"fmt"
 	"os"
 	"regexp"
-)
 
-type CatalogElem struct {
-	Type          string `json:"type"`
-	File          string `json:"file"`
-	SpriteType    int    `json:"spritetype"`
-	FirstSpriteId int    `json:"firstspriteid"`
-	LastSpriteId  int    `json:"lastspriteid"`
-	Area          int    `json:"area"`
-}
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
 
 // StreamCatalogContent opens the JSON and streams elems as they are decoded.
 // It does NOT call convertAsset or mutate globals. Errors are sent on errs.
-func StreamCatalogContent(path string) (<-chan CatalogElem, <-chan error) {
-	out := make(chan CatalogElem)
+func StreamCatalogContent(path string) (<-chan sprites.CatalogElem, <-chan error) {
+	out := make(chan sprites.CatalogElem)
 	errs := make(chan error, 1)
 
 	go func() {
@@ -47,9 +40,9 @@ func StreamCatalogContent(path string) (<-chan CatalogElem, <-chan error) {
 			return
 		}
 
-		var elem CatalogElem
+		var elem sprites.CatalogElem
 		for dec.More() {
-			elem = CatalogElem{} // reset
+			elem = sprites.CatalogElem{} // reset
 			if err := dec.Decode(&elem); err != nil {
 				errs <- err
 				return
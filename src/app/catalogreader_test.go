@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
 )
 
 func writeTempFile(t *testing.T, dir, name, contents string) string {
@@ -25,7 +27,7 @@ func TestStreamCatalogContentReadsElementsSequentially(t *testing.T) {
 
 	out, errs := StreamCatalogContent(path)
 
-	var got []CatalogElem
+	var got []sprites.CatalogElem
 	for elem := range out {
 		got = append(got, elem)
 	}
@@ -34,7 +36,7 @@ func TestStreamCatalogContentReadsElementsSequentially(t *testing.T) {
 		t.Fatalf("StreamCatalogContent error: %v", err)
 	}
 
-	want := []CatalogElem{
+	want := []sprites.CatalogElem{
 		{Type: "sprite", File: "foo.png", SpriteType: 1, FirstSpriteId: 100, LastSpriteId: 101, Area: 64},
 		{Type: "effect", File: "bar.png", SpriteType: 2, FirstSpriteId: 200, LastSpriteId: 205, Area: 128},
 	}
@@ -0,0 +1,271 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DedupMode selects what a Deduper does with an image whose pixels were
+// already written.
+type DedupMode string
+
+const (
+	// DedupHardlink links the duplicate's file to the first copy.
+	DedupHardlink DedupMode = "hardlink"
+	// DedupJSON skips the duplicate and records it in duplicatesFileName.
+	DedupJSON DedupMode = "json"
+)
+
+// DedupModes lists the modes accepted by NewDeduper.
+var DedupModes = []DedupMode{DedupHardlink, DedupJSON}
+
+// duplicatesFileName maps skipped duplicates to the file holding their
+// pixels, written by DedupJSON.
+const duplicatesFileName = "duplicates.json"
+
+// Deduper stores every distinct image once. Images are compared by a hash of
+// their size and non-premultiplied pixels, so two sprites that look the same
+// are duplicates whatever the image type that holds them. It is safe for
+// concurrent use.
+type Deduper struct {
+	mode DedupMode
+
+	mu     sync.Mutex
+	seen   map[[sha256.Size]byte]*dedupEntry
+	dups   map[string]string // duplicate name -> first name
+	unique int
+	saved  int64
+}
+
+// dedupEntry is the first image written with a given hash. done is closed
+// once it is on disk, so duplicates never link to a half-written file.
+type dedupEntry struct {
+	name string
+	path string
+	size int64
+	err  error
+	done chan struct{}
+}
+
+// DedupStats summarizes what a Deduper did.
+type DedupStats struct {
+	// Unique is the number of distinct images written, Duplicates the
+	// number of images linked or skipped instead.
+	Unique, Duplicates int
+	// BytesSaved is the encoded size of the duplicates.
+	BytesSaved int64
+}
+
+// NewDeduper returns a Deduper for one run.
+func NewDeduper(mode DedupMode) (*Deduper, error) {
+	switch mode {
+	case DedupHardlink, DedupJSON:
+	default:
+		names := make([]string, len(DedupModes))
+		for i, m := range DedupModes {
+			names[i] = string(m)
+		}
+		return nil, fmt.Errorf("unknown dedup mode %q (want %s)", string(mode), strings.Join(names, " or "))
+	}
+	return &Deduper{
+		mode: mode,
+		seen: make(map[[sha256.Size]byte]*dedupEntry),
+		dups: make(map[string]string),
+	}, nil
+}
+
+// Mode is the mode the Deduper was created with.
+func (d *Deduper) Mode() DedupMode { return d.mode }
+
+// Stats returns what the Deduper did so far.
+func (d *Deduper) Stats() DedupStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return DedupStats{Unique: d.unique, Duplicates: len(d.dups), BytesSaved: d.saved}
+}
+
+// Duplicates returns a copy of the duplicate name -> first name mapping.
+func (d *Deduper) Duplicates() map[string]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make(map[string]string, len(d.dups))
+	for k, v := range d.dups {
+		out[k] = v
+	}
+	return out
+}
+
+// claim registers img under name. The first caller for a hash gets first
+// true and must call written once the image is stored at path; later
+// callers get the entry of that first image.
+func (d *Deduper) claim(img image.Image, name, path string) (e *dedupEntry, first bool) {
+	sum := pixelHash(img)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := d.seen[sum]; ok {
+		return e, false
+	}
+	e = &dedupEntry{name: name, path: path, done: make(chan struct{})}
+	d.seen[sum] = e
+	return e, true
+}
+
+func (d *Deduper) written(e *dedupEntry, size int64, err error) {
+	d.mu.Lock()
+	e.size, e.err = size, err
+	if err == nil {
+		d.unique++
+	}
+	d.mu.Unlock()
+	close(e.done)
+}
+
+// duplicate stores the image called name, whose pixels are those of e.
+func (d *Deduper) duplicate(e *dedupEntry, name, path string) error {
+	<-e.done
+	if e.err != nil {
+		return fmt.Errorf("first copy %s was not written: %w", e.name, e.err)
+	}
+	if name == e.name {
+		return nil
+	}
+	if d.mode == DedupHardlink {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		// Links replace what an earlier run left behind.
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Link(e.path, path); err != nil {
+			return err
+		}
+	}
+
+	d.mu.Lock()
+	d.dups[name] = e.name
+	d.saved += e.size
+	d.mu.Unlock()
+	return nil
+}
+
+// finish writes duplicatesFileName for DedupJSON and logs the dedup report.
+func (d *Deduper) finish(dir string, out Output) error {
+	var err error
+	if d.mode == DedupJSON {
+		var data []byte
+		data, err = json.MarshalIndent(d.Duplicates(), "", "    ")
+		if err == nil {
+			err = out.writeFile(dir, duplicatesFileName, data)
+		}
+	}
+
+	stats := d.Stats()
+	log.Info().
+		Str("mode", string(d.mode)).
+		Int("unique", stats.Unique).
+		Int("duplicates", stats.Duplicates).
+		Int64("bytesSaved", stats.BytesSaved).
+		Msg("Deduplication finished")
+	return err
+}
+
+// loadDuplicates reads the duplicatesFileName a json dedup run wrote into
+// dir. Without one, there are no duplicates.
+func loadDuplicates(dir string) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, duplicatesFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dups map[string]string
+	if err := json.Unmarshal(data, &dups); err != nil {
+		return nil, fmt.Errorf("%s: %w", duplicatesFileName, err)
+	}
+	return dups, nil
+}
+
+// duplicateSprites lists the sprites a json dedup run of split skipped in
+// splitDir, by sprite ID, with the file holding their pixels.
+func duplicateSprites(splitDir string) map[int]string {
+	dups, err := loadDuplicates(splitDir)
+	if err != nil {
+		log.Warn().Err(err).Str("dir", splitDir).Msg("failed to read " + duplicatesFileName)
+	}
+	layout, err := loadLayout(splitDir)
+	if err != nil {
+		log.Warn().Err(err).Str("dir", splitDir).Msg("failed to read " + layoutFileName)
+	}
+	re := layout.matcher(splitFilePattern, splitNamePattern)
+	out := make(map[int]string, len(dups))
+	for name, first := range dups {
+		m := re.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		if id, err := strconv.Atoi(m[re.SubexpIndex("id")]); err == nil {
+			out[id] = first
+		}
+	}
+	return out
+}
+
+// pixelHash hashes the size and the non-premultiplied pixels of img.
+func pixelHash(img image.Image) [sha256.Size]byte {
+	b := img.Bounds()
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok {
+		nrgba = image.NewNRGBA(b)
+		draw.Draw(nrgba, b, img, b.Min, draw.Src)
+	}
+
+	h := sha256.New()
+	var size [8]byte
+	binary.LittleEndian.PutUint32(size[0:], uint32(b.Dx()))
+	binary.LittleEndian.PutUint32(size[4:], uint32(b.Dy()))
+	h.Write(size[:])
+	row := b.Dx() * 4
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		start := nrgba.PixOffset(b.Min.X, y)
+		pix := nrgba.Pix[start : start+row]
+		// Fully transparent pixels look the same whatever their color.
+		for x := 0; x < row; x += 4 {
+			if pix[x+3] == 0 && (pix[x]|pix[x+1]|pix[x+2]) != 0 {
+				pix = clearTransparent(pix)
+				break
+			}
+		}
+		h.Write(pix)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// clearTransparent returns a copy of a row of pixels with the color of every
+// fully transparent pixel zeroed.
+func clearTransparent(pix []byte) []byte {
+	out := append([]byte(nil), pix...)
+	for x := 0; x < len(out); x += 4 {
+		if out[x+3] == 0 {
+			out[x], out[x+1], out[x+2] = 0, 0, 0
+		}
+	}
+	return out
+}
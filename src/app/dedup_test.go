@@ -0,0 +1,201 @@
+package app
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
+
+// writeDuplicateSheet writes a 64x64 sheet for sprites 100-103 where 100,
+// 101 and 103 are blank and 102 is not.
+func writeDuplicateSheet(t *testing.T, dir string) {
+	t.Helper()
+	sheet := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	sheet.SetNRGBA(10, 40, color.NRGBA{R: 0xFF, A: 0xFF})
+	writeTestPNG(t, filepath.Join(dir, "Sprites-100-103-32x32.png"), sheet)
+}
+
+func TestSplitSpritesDedupHardlink(t *testing.T) {
+	extracted := t.TempDir()
+	split := t.TempDir()
+	writeDuplicateSheet(t, extracted)
+	// A leftover of an earlier run is replaced by the link.
+	if err := os.WriteFile(filepath.Join(split, "101.png"), []byte("old"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	buf, restore := captureLogs(t)
+	defer restore()
+
+	d, err := NewDeduper(DedupHardlink)
+	if err != nil {
+		t.Fatalf("NewDeduper: %v", err)
+	}
+	res, err := SplitSprites(extracted, split, Output{Dedup: d})
+	if err != nil {
+		t.Fatalf("SplitSprites: %v", err)
+	}
+	if res.Failed != 0 {
+		t.Fatalf("result = %+v, want no failures", res)
+	}
+
+	// Sprites are cut 32x32 from the 64x64 sheet: 100, 101 and 103 are
+	// blank, 102 holds the red pixel.
+	first, err := os.Stat(filepath.Join(split, "100.png"))
+	if err != nil {
+		t.Fatalf("Stat 100.png: %v", err)
+	}
+	for _, name := range []string{"101.png", "103.png"} {
+		fi, err := os.Stat(filepath.Join(split, name))
+		if err != nil {
+			t.Fatalf("Stat %s: %v", name, err)
+		}
+		if !os.SameFile(first, fi) {
+			t.Fatalf("%s is not linked to 100.png", name)
+		}
+	}
+
+	stats := d.Stats()
+	if stats.Unique != 2 || stats.Duplicates != 2 || stats.BytesSaved != 2*first.Size() {
+		t.Fatalf("stats = %+v, want 2 unique, 2 duplicates saving %d bytes", stats, 2*first.Size())
+	}
+	if _, err := os.Stat(filepath.Join(split, duplicatesFileName)); !os.IsNotExist(err) {
+		t.Fatalf("hardlink mode should not write %s", duplicatesFileName)
+	}
+	if logs := buf.String(); !strings.Contains(logs, "Deduplication finished") || !strings.Contains(logs, `"duplicates":2`) {
+		t.Fatalf("expected dedup report, got %q", buf.String())
+	}
+}
+
+func TestSplitSpritesDedupHardlinkRerunKeepsLinkedFilesApart(t *testing.T) {
+	extracted := t.TempDir()
+	split := t.TempDir()
+	writeDuplicateSheet(t, extracted)
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	run := func() {
+		t.Helper()
+		d, err := NewDeduper(DedupHardlink)
+		if err != nil {
+			t.Fatalf("NewDeduper: %v", err)
+		}
+		if res, err := SplitSprites(extracted, split, Output{Dedup: d}); err != nil || res.Failed != 0 {
+			t.Fatalf("SplitSprites = %+v, %v", res, err)
+		}
+	}
+	run()
+
+	// Sprite 100 stops being blank, while 101 and 103, linked to it by
+	// the first run, stay blank.
+	sheet := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	sheet.SetNRGBA(10, 10, color.NRGBA{G: 0xFF, A: 0xFF})
+	sheet.SetNRGBA(10, 40, color.NRGBA{R: 0xFF, A: 0xFF})
+	writeTestPNG(t, filepath.Join(extracted, "Sprites-100-103-32x32.png"), sheet)
+	run()
+
+	blank := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	changed := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	changed.SetNRGBA(10, 10, color.NRGBA{G: 0xFF, A: 0xFF})
+	compareImages(t, decodePNG(t, filepath.Join(split, "100.png")), changed)
+	for _, name := range []string{"101.png", "103.png"} {
+		compareImages(t, decodePNG(t, filepath.Join(split, name)), blank)
+	}
+}
+
+func TestSplitSpritesDedupJSON(t *testing.T) {
+	extracted := t.TempDir()
+	split := t.TempDir()
+	writeDuplicateSheet(t, extracted)
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	d, _ := NewDeduper(DedupJSON)
+	if _, err := SplitSprites(extracted, split, Output{Dedup: d}); err != nil {
+		t.Fatalf("SplitSprites: %v", err)
+	}
+
+	for _, name := range []string{"101.png", "103.png"} {
+		if _, err := os.Stat(filepath.Join(split, name)); !os.IsNotExist(err) {
+			t.Fatalf("%s should not be written, stat error = %v", name, err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(split, duplicatesFileName))
+	if err != nil {
+		t.Fatalf("read %s: %v", duplicatesFileName, err)
+	}
+	var dups map[string]string
+	if err := json.Unmarshal(data, &dups); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(dups) != 2 || dups["101.png"] != "100.png" || dups["103.png"] != "100.png" {
+		t.Fatalf("duplicates = %v", dups)
+	}
+
+	// Later steps still find the skipped sprites.
+	if _, err := openSpriteDir(split).Sprite(103); err != nil {
+		t.Fatalf("Sprite(103): %v", err)
+	}
+
+	packPath := filepath.Join(t.TempDir(), "sprites.pack")
+	if res, err := WriteSpritePack(split, packPath, nil); err != nil || res.Processed != 4 {
+		t.Fatalf("WriteSpritePack = %+v, %v, want 4 sprites", res, err)
+	}
+	pack, err := sprites.OpenSpritePack(packPath)
+	if err != nil {
+		t.Fatalf("OpenSpritePack: %v", err)
+	}
+	defer pack.Close()
+	if _, err := pack.Sprite(101); err != nil {
+		t.Fatalf("pack Sprite(101): %v", err)
+	}
+
+	atlasDir := t.TempDir()
+	if res, err := BuildAtlas(split, atlasDir, AtlasOptions{MaxSize: 128}); err != nil || res.Processed != 4 {
+		t.Fatalf("BuildAtlas = %+v, %v, want 4 frames", res, err)
+	}
+	meta := readAtlasJSON(t, filepath.Join(atlasDir, "atlas-0.json"))
+	if meta.Frames["101"].Frame != meta.Frames["100"].Frame {
+		t.Fatalf("frame 101 = %+v, want the frame of 100 %+v", meta.Frames["101"], meta.Frames["100"])
+	}
+}
+
+func TestPixelHashComparesVisiblePixels(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	rgba.Set(0, 0, color.NRGBA{R: 0x80, G: 0x20, A: 0xFF})
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	nrgba.SetNRGBA(0, 0, color.NRGBA{R: 0x80, G: 0x20, A: 0xFF})
+	// Invisible color must not matter.
+	nrgba.SetNRGBA(1, 1, color.NRGBA{G: 0xFF})
+
+	if pixelHash(rgba) != pixelHash(nrgba) {
+		t.Fatalf("same pixels in RGBA and NRGBA hash differently")
+	}
+	if pixelHash(nrgba) == pixelHash(image.NewNRGBA(image.Rect(0, 0, 1, 4))) {
+		t.Fatalf("different sizes hash the same")
+	}
+}
+
+func TestDedupHardlinkRejectsSink(t *testing.T) {
+	d, _ := NewDeduper(DedupHardlink)
+	a, err := CreateArchive(filepath.Join(t.TempDir(), "out.zip"))
+	if err != nil {
+		t.Fatalf("CreateArchive: %v", err)
+	}
+	defer a.Close()
+
+	if _, err := SplitSprites(t.TempDir(), t.TempDir(), Output{Sink: a, Dedup: d}); err == nil {
+		t.Fatalf("expected hardlink dedup into an archive to fail")
+	}
+	if _, err := NewDeduper("copy"); err == nil {
+		t.Fatalf("expected unknown mode to fail")
+	}
+}
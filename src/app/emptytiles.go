@@ -0,0 +1,133 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EmptyTilePolicy selects what SplitSpriteSheet does with a fully
+// transparent sprite, such as the padding after the last sprite of a sheet.
+type EmptyTilePolicy string
+
+const (
+	// EmptyTilesWrite writes empty sprites like any other.
+	EmptyTilesWrite EmptyTilePolicy = "write"
+	// EmptyTilesSkip does not write empty sprites.
+	EmptyTilesSkip EmptyTilePolicy = "skip"
+	// EmptyTilesReport writes empty sprites and lists their IDs in
+	// emptyTilesFileName.
+	EmptyTilesReport EmptyTilePolicy = "report"
+)
+
+// EmptyTilePolicies lists the policies accepted by NewEmptyTiles.
+var EmptyTilePolicies = []EmptyTilePolicy{EmptyTilesWrite, EmptyTilesSkip, EmptyTilesReport}
+
+// emptyTilesFileName lists the IDs of empty sprites, written by
+// EmptyTilesReport.
+const emptyTilesFileName = "empty.json"
+
+// EmptyTiles detects empty sprites while splitting and applies a policy to
+// them. It is safe for concurrent use.
+type EmptyTiles struct {
+	policy EmptyTilePolicy
+
+	mu  sync.Mutex
+	ids []int
+}
+
+// NewEmptyTiles returns an EmptyTiles for one run.
+func NewEmptyTiles(policy EmptyTilePolicy) (*EmptyTiles, error) {
+	switch policy {
+	case EmptyTilesWrite, EmptyTilesSkip, EmptyTilesReport:
+	default:
+		names := make([]string, len(EmptyTilePolicies))
+		for i, p := range EmptyTilePolicies {
+			names[i] = string(p)
+		}
+		return nil, fmt.Errorf("unknown empty tile policy %q (want %s)", string(policy), strings.Join(names, ", "))
+	}
+	return &EmptyTiles{policy: policy}, nil
+}
+
+// Policy is the policy the EmptyTiles was created with.
+func (e *EmptyTiles) Policy() EmptyTilePolicy { return e.policy }
+
+// IDs returns the sorted IDs of the empty sprites found so far.
+func (e *EmptyTiles) IDs() []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ids := append([]int(nil), e.ids...)
+	sort.Ints(ids)
+	return ids
+}
+
+// keep records sprite id when img is empty and reports whether the sprite
+// should be written.
+func (e *EmptyTiles) keep(id int, img image.Image) bool {
+	if !imageEmpty(img) {
+		return true
+	}
+	e.mu.Lock()
+	e.ids = append(e.ids, id)
+	e.mu.Unlock()
+	return e.policy != EmptyTilesSkip
+}
+
+// finish writes emptyTilesFileName for EmptyTilesReport and logs how many
+// empty sprites were found.
+func (e *EmptyTiles) finish(dir string, out Output) error {
+	ids := e.IDs()
+	var err error
+	if e.policy == EmptyTilesReport {
+		var data []byte
+		data, err = json.MarshalIndent(ids, "", "    ")
+		if err == nil {
+			err = out.writeFile(dir, emptyTilesFileName, data)
+		}
+	}
+
+	log.Info().
+		Str("policy", string(e.policy)).
+		Int("emptyTiles", len(ids)).
+		Msg("Empty tiles detected")
+	return err
+}
+
+// imageEmpty reports whether every pixel of img is fully transparent.
+func imageEmpty(img image.Image) bool {
+	b := img.Bounds()
+	switch img := img.(type) {
+	case *image.RGBA:
+		return alphaZero(img.Pix, img.Stride, img.PixOffset(b.Min.X, b.Min.Y), b)
+	case *image.NRGBA:
+		return alphaZero(img.Pix, img.Stride, img.PixOffset(b.Min.X, b.Min.Y), b)
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// alphaZero reports whether the alpha byte of every 4-byte pixel of b is
+// zero, starting at pix[start].
+func alphaZero(pix []byte, stride, start int, b image.Rectangle) bool {
+	for y := 0; y < b.Dy(); y++ {
+		row := pix[start+y*stride:][:b.Dx()*4]
+		for x := 3; x < len(row); x += 4 {
+			if row[x] != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
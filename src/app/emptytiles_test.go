@@ -0,0 +1,104 @@
+package app
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePaddedSheet writes a 64x64 sheet for sprites 1-4 where only sprite 1
+// has visible pixels.
+func writePaddedSheet(t *testing.T, dir string) {
+	t.Helper()
+	sheet := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	sheet.SetNRGBA(5, 5, color.NRGBA{G: 0xFF, A: 0xFF})
+	// Invisible color still counts as empty.
+	sheet.SetNRGBA(40, 5, color.NRGBA{R: 0xFF})
+	writeTestPNG(t, filepath.Join(dir, "Sprites-1-4-32x32.png"), sheet)
+}
+
+func TestSplitSpritesEmptyTiles(t *testing.T) {
+	tests := []struct {
+		policy     EmptyTilePolicy
+		wantFiles  []string
+		wantReport bool
+	}{
+		{EmptyTilesWrite, []string{"1.png", "2.png", "3.png", "4.png"}, false},
+		{EmptyTilesSkip, []string{"1.png"}, false},
+		{EmptyTilesReport, []string{"1.png", "2.png", "3.png", "4.png", emptyTilesFileName}, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			extracted := t.TempDir()
+			split := t.TempDir()
+			writePaddedSheet(t, extracted)
+			_, restore := captureLogs(t)
+			defer restore()
+
+			empty, err := NewEmptyTiles(tt.policy)
+			if err != nil {
+				t.Fatalf("NewEmptyTiles: %v", err)
+			}
+			res, err := SplitSprites(extracted, split, Output{EmptyTiles: empty})
+			if err != nil || res.Failed != 0 {
+				t.Fatalf("SplitSprites = %+v, %v", res, err)
+			}
+
+			entries, err := os.ReadDir(split)
+			if err != nil {
+				t.Fatalf("ReadDir: %v", err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			if len(got) != len(tt.wantFiles) {
+				t.Fatalf("files = %v, want %v", got, tt.wantFiles)
+			}
+			for i := range got {
+				if got[i] != tt.wantFiles[i] {
+					t.Fatalf("files = %v, want %v", got, tt.wantFiles)
+				}
+			}
+
+			if ids := empty.IDs(); len(ids) != 3 || ids[0] != 2 || ids[2] != 4 {
+				t.Fatalf("IDs = %v, want [2 3 4]", ids)
+			}
+			if !tt.wantReport {
+				return
+			}
+			data, err := os.ReadFile(filepath.Join(split, emptyTilesFileName))
+			if err != nil {
+				t.Fatalf("read report: %v", err)
+			}
+			var ids []int
+			if err := json.Unmarshal(data, &ids); err != nil || len(ids) != 3 {
+				t.Fatalf("report = %s (%v), want 3 IDs", data, err)
+			}
+		})
+	}
+}
+
+func TestImageEmpty(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	if !imageEmpty(rgba) {
+		t.Fatalf("blank RGBA image should be empty")
+	}
+	rgba.Set(7, 7, color.NRGBA{A: 1})
+	if imageEmpty(rgba) {
+		t.Fatalf("image with a visible pixel should not be empty")
+	}
+	// Sub-images only look at their own pixels.
+	if !imageEmpty(rgba.SubImage(image.Rect(0, 0, 7, 7))) {
+		t.Fatalf("blank sub-image should be empty")
+	}
+	if imageEmpty(image.NewGray16(image.Rect(0, 0, 2, 2))) {
+		t.Fatalf("opaque gray image should not be empty")
+	}
+	if _, err := NewEmptyTiles("drop"); err == nil {
+		t.Fatalf("expected unknown policy to fail")
+	}
+}
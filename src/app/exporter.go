@@ -0,0 +1,187 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	bar "github.com/schollz/progressbar/v3"
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
+
+// ExportOptions selects what Export writes. An empty directory skips that
+// output.
+type ExportOptions struct {
+	// SheetsDir receives the sheets as extract writes them.
+	SheetsDir string
+	// TilesDir receives one image per sprite, as split writes them.
+	TilesDir string
+	// GroupsDir receives one image per frame group, as group writes them.
+	GroupsDir string
+	// Workers is the number of sheets decoded in parallel; values lower
+	// than 1 fall back to a single worker.
+	Workers int
+	// CacheSize is the number of decoded sheets kept in memory for
+	// composing groups; values lower than 1 use the Client default.
+	CacheSize int
+	// Output selects the image format of every output. Its Sink and Dedup
+	// must be nil and its Layout the built-in one: the outputs go to
+	// separate directories. Its EmptyTiles
+	// applies to the tiles.
+	Output Output
+}
+
+// Export decodes the client assets in assetsPath and writes the requested
+// outputs without reading any of them back. Each sheet is decoded once for
+// sheets and tiles; groups read sprites through a bounded cache of decoded
+// sheets, so only sheets evicted from the cache are decoded again.
+//
+// The Result counts sheets for the sheets and tiles outputs and groups for
+// the groups output. The error is set when the catalog or the appearances
+// file cannot be read, or an output directory cannot be created.
+func Export(assetsPath string, opts ExportOptions) (Result, error) {
+	var res Result
+	if opts.SheetsDir == "" && opts.TilesDir == "" && opts.GroupsDir == "" {
+		return res, errors.New("nothing to export: no output selected")
+	}
+	if opts.Output.Sink != nil || opts.Output.Dedup != nil || opts.Output.Layout.custom() {
+		return res, errors.New("export supports no output sinks, deduplication or custom layouts")
+	}
+	if err := opts.Output.GroupMode.check(); err != nil {
+		return res, err
+	}
+
+	client, err := sprites.OpenClient(assetsPath)
+	if err != nil {
+		return res, err
+	}
+	if opts.CacheSize > 0 {
+		client.SetCacheSize(opts.CacheSize)
+	}
+
+	for _, dir := range []string{opts.SheetsDir, opts.TilesDir, opts.GroupsDir} {
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Err(err).Str("dir", dir).Msg("failed to create output directory")
+			return res, fmt.Errorf("create %s: %w", dir, err)
+		}
+	}
+
+	if opts.SheetsDir != "" || opts.TilesDir != "" {
+		res.add(exportSheets(client, opts))
+	}
+	if opts.TilesDir != "" && opts.Output.EmptyTiles != nil {
+		if err := opts.Output.EmptyTiles.finish(opts.TilesDir, opts.Output); err != nil {
+			log.Err(err).Msg("failed to write the empty tile report")
+			res.fail(emptyTilesFileName, err)
+		}
+	}
+
+	if opts.GroupsDir != "" {
+		apps, err := client.Appearances()
+		if err != nil {
+			log.Err(err).Msg("failed to read appearances")
+			return res, fmt.Errorf("read appearances: %w", err)
+		}
+		groups := appearanceGroups(apps)
+		// Neighbouring groups then share sheets, which keeps the cache warm.
+		sort.SliceStable(groups, func(i, j int) bool {
+			return firstSpriteID(groups[i].SpriteInfo) < firstSpriteID(groups[j].SpriteInfo)
+		})
+		res.add(writeGroups(groups, client, opts.GroupsDir, opts.Output))
+	}
+
+	log.Info().
+		Int("exported", res.Processed).
+		Int("skipped", res.Skipped).
+		Int("missing", res.Missing).
+		Int("failed", res.Failed).
+		Msg("Exporting sprites finished")
+
+	return res, nil
+}
+
+// exportSheets writes the sheets and tiles outputs, decoding every sheet of
+// the catalog once.
+func exportSheets(client *sprites.Client, opts ExportOptions) Result {
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	sheets := client.Sheets()
+
+	progress := bar.NewOptions(
+		len(sheets),
+		bar.OptionSetDescription("Exporting sheets"),
+		bar.OptionShowCount(),
+		bar.OptionShowIts(),
+		bar.OptionSetItsString("sheets"),
+		bar.OptionThrottle(100),
+		bar.OptionClearOnFinish(),
+	)
+
+	var res syncResult
+	jobs := make(chan sprites.CatalogElem)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range jobs {
+				err := exportSheet(client, e, opts)
+				if err != nil && !errors.Is(err, fs.ErrNotExist) {
+					log.Err(err).Str("file", e.File).Msg("failed to export sheet")
+				}
+				res.update(func(r *Result) {
+					switch {
+					case errors.Is(err, fs.ErrNotExist):
+						r.miss(e.File, err)
+					case err != nil:
+						r.fail(e.File, err)
+					default:
+						r.Processed++
+					}
+				})
+				_ = progress.Add(1)
+			}
+		}()
+	}
+	for _, e := range sheets {
+		jobs <- e
+	}
+	close(jobs)
+	wg.Wait()
+	_ = progress.Finish()
+
+	return res.res
+}
+
+func exportSheet(client *sprites.Client, e sprites.CatalogElem, opts ExportOptions) error {
+	img, err := client.SheetImage(e.File)
+	if err != nil {
+		return err
+	}
+	if opts.SheetsDir != "" {
+		name := sheetFileName(e.FirstSpriteId, e.LastSpriteId, e.SpriteType, opts.Output.Ext())
+		if err := opts.Output.writeImage(opts.SheetsDir, name, img); err != nil {
+			return err
+		}
+	}
+	if opts.TilesDir != "" {
+		return SplitSpriteSheet(img, e.FirstSpriteId, e.LastSpriteId, e.SpriteType, opts.TilesDir, opts.Output)
+	}
+	return nil
+}
+
+func firstSpriteID(g sprites.SpriteInfo) int {
+	if len(g.SpriteIDs) == 0 {
+		return 0
+	}
+	return g.SpriteIDs[0]
+}
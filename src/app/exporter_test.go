@@ -0,0 +1,123 @@
+package app
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeExportTestClient(t *testing.T) string {
+	t.Helper()
+
+	dir := writeTestClient(t, map[string]image.Image{
+		"a.bin": newTestImage(384, 384),
+		"b.bin": newTestImage(384, 384),
+	}, `[
+                {"type":"appearances","file":"appearances.dat"},
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":3,"area":0},
+                {"type":"sprite","file":"b.bin","spritetype":3,"firstspriteid":4,"lastspriteid":5,"area":0}
+        ]`)
+	dat := protoMessage{}.
+		message(1, buildAppearance(100, buildSpriteInfo(1, 1, 1, 1, 4, 5))).
+		message(1, buildAppearance(101, buildSpriteInfo(1, 1, 1, 1, 1, 2, 3)))
+	if err := os.WriteFile(filepath.Join(dir, "appearances.dat"), dat, 0o644); err != nil {
+		t.Fatalf("write appearances: %v", err)
+	}
+	return dir
+}
+
+func TestExportMatchesExtractSplitAndGroup(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	assets := writeExportTestClient(t)
+	tmp := t.TempDir()
+
+	staged := filepath.Join(tmp, "staged")
+	if _, err := ConvertAssetsFromCatalogContent(assets, filepath.Join(assets, "catalog-content.json"), filepath.Join(staged, "sheets"), ExtractOptions{}); err != nil {
+		t.Fatalf("ConvertAssetsFromCatalogContent error: %v", err)
+	}
+	if _, err := SplitSprites(filepath.Join(staged, "sheets"), filepath.Join(staged, "tiles"), Output{}); err != nil {
+		t.Fatalf("SplitSprites error: %v", err)
+	}
+	if _, err := GroupSplitSprites(assets, "appearances.dat", filepath.Join(staged, "tiles"), filepath.Join(staged, "groups"), Output{}); err != nil {
+		t.Fatalf("GroupSplitSprites error: %v", err)
+	}
+
+	direct := filepath.Join(tmp, "direct")
+	res, err := Export(assets, ExportOptions{
+		SheetsDir: filepath.Join(direct, "sheets"),
+		TilesDir:  filepath.Join(direct, "tiles"),
+		GroupsDir: filepath.Join(direct, "groups"),
+		Workers:   2,
+		CacheSize: 1,
+	})
+	if err != nil {
+		t.Fatalf("Export error: %v", err)
+	}
+	// Two sheets plus two groups.
+	if res.Processed != 4 || res.Failed != 0 || res.Missing != 0 {
+		t.Fatalf("result = %+v, want 4 processed", res)
+	}
+
+	for _, name := range []string{
+		"sheets/Sprites-1-3-32x32.png",
+		"sheets/Sprites-4-5-64x64.png",
+		"tiles/1.png",
+		"tiles/3.png",
+		"tiles/5.png",
+		"groups/objects/101.png",
+		"groups/objects/100.png",
+	} {
+		want := decodePNG(t, filepath.Join(staged, name))
+		got := decodePNG(t, filepath.Join(direct, name))
+		compareImages(t, got, want)
+	}
+}
+
+func TestExportWritesOnlySelectedOutputs(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	assets := writeExportTestClient(t)
+	out := t.TempDir()
+	groups := filepath.Join(out, "groups")
+
+	res, err := Export(assets, ExportOptions{GroupsDir: groups})
+	if err != nil {
+		t.Fatalf("Export error: %v", err)
+	}
+	if res.Processed != 2 {
+		t.Fatalf("result = %+v, want 2 groups", res)
+	}
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "groups" {
+		t.Fatalf("output contains %v, want only groups", entries)
+	}
+
+	if _, err := Export(assets, ExportOptions{}); err == nil {
+		t.Fatalf("expected error when no output is selected")
+	}
+}
+
+func TestExportCountsMissingSheets(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	assets := writeExportTestClient(t)
+	if err := os.Remove(filepath.Join(assets, "b.bin")); err != nil {
+		t.Fatalf("remove sheet: %v", err)
+	}
+
+	res, err := Export(assets, ExportOptions{TilesDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Export error: %v", err)
+	}
+	if res.Processed != 1 || res.Missing != 1 || res.Failed != 0 {
+		t.Fatalf("result = %+v, want 1 processed, 1 missing", res)
+	}
+}
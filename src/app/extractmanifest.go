@@ -0,0 +1,144 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
+
+// manifestFileName is written next to the extracted sheets.
+const manifestFileName = "manifest.json"
+
+// ManifestEntry records what extract produced for one catalog file.
+type ManifestEntry struct {
+	SourceHash    string             `json:"sourcehash"`
+	FirstSpriteId int                `json:"firstspriteid"`
+	LastSpriteId  int                `json:"lastspriteid"`
+	SpriteType    sprites.SpriteType `json:"spritetype"`
+	Output        string             `json:"output"`
+	OutputHash    string             `json:"outputhash"`
+	// Encoder describes the encoder settings the output was written with,
+	// see encoderSettings.
+	Encoder string `json:"encoder"`
+}
+
+// ExtractManifest maps catalog file names to their extracted sheet. It lets
+// extract skip sheets whose source and output are unchanged since the last
+// run, and remove sheets whose catalog entries are gone.
+type ExtractManifest struct {
+	Files map[string]ManifestEntry `json:"files"`
+
+	mu sync.Mutex
+}
+
+func newExtractManifest() *ExtractManifest {
+	return &ExtractManifest{Files: make(map[string]ManifestEntry)}
+}
+
+// loadExtractManifest reads the manifest from outputPath. A missing manifest
+// yields an empty one, so the first run extracts everything.
+func loadExtractManifest(outputPath string) (*ExtractManifest, error) {
+	m := newExtractManifest()
+	data, err := os.ReadFile(filepath.Join(outputPath, manifestFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return newExtractManifest(), err
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]ManifestEntry)
+	}
+	return m, nil
+}
+
+func (m *ExtractManifest) save(outputPath string, out Output) error {
+	data, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return err
+	}
+	return out.writeFile(outputPath, manifestFileName, data)
+}
+
+func (m *ExtractManifest) get(file string) (ManifestEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.Files[file]
+	return e, ok
+}
+
+func (m *ExtractManifest) set(file string, e ManifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Files[file] = e
+}
+
+// upToDate reports whether the previous run already produced this exact
+// output from this exact source.
+func (m *ExtractManifest) upToDate(outputPath, file string, e ManifestEntry) bool {
+	prev, ok := m.get(file)
+	if !ok || prev.SourceHash != e.SourceHash || prev.Output != e.Output || prev.Encoder != e.Encoder {
+		return false
+	}
+	hash, err := hashFile(filepath.Join(outputPath, filepath.FromSlash(prev.Output)))
+	return err == nil && hash == prev.OutputHash
+}
+
+// staleOutputs lists outputs of the previous manifest that the current one
+// no longer produces.
+func staleOutputs(prev, cur *ExtractManifest) []string {
+	keep := make(map[string]bool, len(cur.Files))
+	for _, e := range cur.Files {
+		keep[e.Output] = true
+	}
+	var stale []string
+	for _, e := range prev.Files {
+		if !keep[e.Output] {
+			stale = append(stale, e.Output)
+		}
+	}
+	return stale
+}
+
+// encoderSettings describes enc for the manifest, so changing the format,
+// the PNG compression or --optimize between runs re-extracts every sheet.
+func encoderSettings(enc ImageEncoder) string {
+	switch enc := enc.(type) {
+	case PNGEncoder:
+		return fmt.Sprintf("png compression=%d", enc.CompressionLevel)
+	case *OptimizedPNGEncoder:
+		if enc.Baseline == nil {
+			return "optimized png"
+		}
+		return fmt.Sprintf("optimized png baseline=%d", enc.Baseline.CompressionLevel)
+	case TIFFEncoder:
+		return fmt.Sprintf("tiff uncompressed=%t", enc.Uncompressed)
+	default:
+		return fmt.Sprintf("%T", enc)
+	}
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
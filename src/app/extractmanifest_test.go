@@ -0,0 +1,272 @@
+package app
+
+import (
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func modTime(t *testing.T, path string) time.Time {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat %s: %v", path, err)
+	}
+	return fi.ModTime()
+}
+
+func backdate(t *testing.T, paths ...string) {
+	t.Helper()
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, p := range paths {
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatalf("Chtimes %s: %v", p, err)
+		}
+	}
+}
+
+func TestConvertAssetsFromCatalogContentIsIncremental(t *testing.T) {
+	assetsDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeCIPFile(t, assetsDir, "a.bin", makeCIPAssetFromImage(t, newTestImage(64, 64)))
+	writeCIPFile(t, assetsDir, "b.bin", makeCIPAssetFromImage(t, newTestImage(64, 32)))
+	writeCIPFile(t, assetsDir, "c.bin", makeCIPAssetFromImage(t, newTestImage(32, 32)))
+	catalog := writeTempFile(t, assetsDir, "catalog-content.json", `[
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":4},
+                {"type":"sprite","file":"b.bin","spritetype":0,"firstspriteid":5,"lastspriteid":6},
+                {"type":"sprite","file":"c.bin","spritetype":0,"firstspriteid":7,"lastspriteid":7}
+        ]`)
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, ExtractOptions{Workers: 2})
+
+	m, err := loadExtractManifest(outputDir)
+	if err != nil {
+		t.Fatalf("loadExtractManifest error: %v", err)
+	}
+	entry, ok := m.Files["a.bin"]
+	if !ok || entry.Output != "Sprites-1-4-32x32.png" || entry.FirstSpriteId != 1 || entry.LastSpriteId != 4 || entry.SourceHash == "" || entry.OutputHash == "" {
+		t.Fatalf("manifest entry for a.bin = %+v (ok=%v)", entry, ok)
+	}
+
+	pathA := filepath.Join(outputDir, "Sprites-1-4-32x32.png")
+	pathB := filepath.Join(outputDir, "Sprites-5-6-32x32.png")
+	pathC := filepath.Join(outputDir, "Sprites-7-7-32x32.png")
+	backdate(t, pathA, pathB, pathC)
+	stampA, stampB := modTime(t, pathA), modTime(t, pathB)
+
+	// b.bin changes at the source, c.bin leaves the catalog.
+	writeCIPFile(t, assetsDir, "b.bin", makeCIPAssetFromImage(t, newTestImage(32, 64)))
+	writeTempFile(t, assetsDir, "catalog-content.json", `[
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":4},
+                {"type":"sprite","file":"b.bin","spritetype":0,"firstspriteid":5,"lastspriteid":6}
+        ]`)
+
+	ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, ExtractOptions{Workers: 2})
+
+	if !modTime(t, pathA).Equal(stampA) {
+		t.Fatalf("unchanged sheet was rewritten")
+	}
+	if modTime(t, pathB).Equal(stampB) {
+		t.Fatalf("changed sheet was not rewritten")
+	}
+	compareImages(t, decodePNG(t, pathB), newTestImage(32, 64))
+	if _, err := os.Stat(pathC); !os.IsNotExist(err) {
+		t.Fatalf("sheet of removed catalog entry still exists (err=%v)", err)
+	}
+
+	m, err = loadExtractManifest(outputDir)
+	if err != nil {
+		t.Fatalf("loadExtractManifest error: %v", err)
+	}
+	if _, ok := m.Files["c.bin"]; ok || len(m.Files) != 2 {
+		t.Fatalf("manifest files = %+v, want a.bin and b.bin", m.Files)
+	}
+}
+
+func TestConvertAssetsFromCatalogContentKeepsSheetsThatFailToExtract(t *testing.T) {
+	assetsDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeCIPFile(t, assetsDir, "a.bin", makeCIPAssetFromImage(t, newTestImage(64, 64)))
+	writeCIPFile(t, assetsDir, "b.bin", makeCIPAssetFromImage(t, newTestImage(32, 32)))
+	catalog := writeTempFile(t, assetsDir, "catalog-content.json", `[
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":4},
+                {"type":"sprite","file":"b.bin","spritetype":0,"firstspriteid":5,"lastspriteid":5}
+        ]`)
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	if _, err := ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, ExtractOptions{Workers: 2}); err != nil {
+		t.Fatalf("first extract: %v", err)
+	}
+
+	// a.bin turns corrupt and b.bin disappears, but both stay in the catalog.
+	writeCIPFile(t, assetsDir, "a.bin", makeCIPAssetFromBytes(t, []byte("not a bmp")))
+	if err := os.Remove(filepath.Join(assetsDir, "b.bin")); err != nil {
+		t.Fatalf("remove b.bin: %v", err)
+	}
+	res, err := ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, ExtractOptions{Workers: 2})
+	if err != nil {
+		t.Fatalf("second extract: %v", err)
+	}
+	if res.Failed != 1 || res.Missing != 1 {
+		t.Fatalf("result = %+v, want one failed and one missing sheet", res)
+	}
+
+	compareImages(t, decodePNG(t, filepath.Join(outputDir, "Sprites-1-4-32x32.png")), newTestImage(64, 64))
+	compareImages(t, decodePNG(t, filepath.Join(outputDir, "Sprites-5-5-32x32.png")), newTestImage(32, 32))
+	m, err := loadExtractManifest(outputDir)
+	if err != nil {
+		t.Fatalf("loadExtractManifest error: %v", err)
+	}
+	if _, ok := m.Files["a.bin"]; !ok || len(m.Files) != 2 {
+		t.Fatalf("manifest files = %+v, want a.bin and b.bin kept", m.Files)
+	}
+}
+
+func TestConvertAssetsFromCatalogContentRewritesTamperedOutputs(t *testing.T) {
+	assetsDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeCIPFile(t, assetsDir, "a.bin", makeCIPAssetFromImage(t, newTestImage(64, 64)))
+	catalog := writeTempFile(t, assetsDir, "catalog-content.json", `[
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":4}
+        ]`)
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, ExtractOptions{Workers: 1})
+
+	path := filepath.Join(outputDir, "Sprites-1-4-32x32.png")
+	if err := os.WriteFile(path, []byte("edited"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, ExtractOptions{Workers: 1})
+	compareImages(t, decodePNG(t, path), newTestImage(64, 64))
+}
+
+func TestConvertAssetsFromCatalogContentForceIgnoresManifest(t *testing.T) {
+	assetsDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeCIPFile(t, assetsDir, "a.bin", makeCIPAssetFromImage(t, newTestImage(64, 64)))
+	catalog := writeTempFile(t, assetsDir, "catalog-content.json", `[
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":4}
+        ]`)
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, ExtractOptions{Workers: 1})
+	path := filepath.Join(outputDir, "Sprites-1-4-32x32.png")
+	backdate(t, path)
+	stamp := modTime(t, path)
+
+	ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, ExtractOptions{Workers: 1, Force: true})
+	if modTime(t, path).Equal(stamp) {
+		t.Fatalf("forced extract did not rewrite the sheet")
+	}
+}
+
+func TestConvertAssetsFromCatalogContentRewritesSheetsWhenEncoderChanges(t *testing.T) {
+	assetsDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeCIPFile(t, assetsDir, "a.bin", makeCIPAssetFromImage(t, newTestImage(64, 64)))
+	catalog := writeTempFile(t, assetsDir, "catalog-content.json", `[
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":4}
+        ]`)
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	path := filepath.Join(outputDir, "Sprites-1-4-32x32.png")
+	for _, tt := range []struct {
+		enc     ImageEncoder
+		rewrite bool
+	}{
+		{nil, true},
+		{PNGEncoder{}, false},
+		{PNGEncoder{CompressionLevel: png.BestCompression}, true},
+		{PNGEncoder{CompressionLevel: png.BestCompression}, false},
+		{&OptimizedPNGEncoder{}, true},
+		{&OptimizedPNGEncoder{Baseline: &PNGEncoder{}}, true},
+	} {
+		res, err := ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, ExtractOptions{Output: Output{Encoder: tt.enc}})
+		if err != nil {
+			t.Fatalf("ConvertAssetsFromCatalogContent(%T): %v", tt.enc, err)
+		}
+		if rewritten := res.Processed == 1; rewritten != tt.rewrite {
+			t.Fatalf("encoder %#v: result = %+v, want rewrite %v", tt.enc, res, tt.rewrite)
+		}
+	}
+	compareImages(t, decodePNG(t, path), newTestImage(64, 64))
+}
+
+func TestLoadExtractManifestToleratesMissingAndCorruptFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := loadExtractManifest(dir)
+	if err != nil || len(m.Files) != 0 {
+		t.Fatalf("missing manifest = %+v, %v; want empty, nil", m.Files, err)
+	}
+
+	writeTempFile(t, dir, manifestFileName, "{")
+	m, err = loadExtractManifest(dir)
+	if err == nil {
+		t.Fatalf("expected error for corrupt manifest")
+	}
+	if m == nil || m.Files == nil || len(m.Files) != 0 {
+		t.Fatalf("corrupt manifest should fall back to an empty one, got %+v", m)
+	}
+}
+
+func TestConvertAssetsFromCatalogContentFollowsLayout(t *testing.T) {
+	assetsDir := t.TempDir()
+	outputDir := t.TempDir()
+	writeCIPFile(t, assetsDir, "a.bin", makeCIPAssetFromImage(t, newTestImage(64, 64)))
+	catalog := writeTempFile(t, assetsDir, "catalog-content.json", `[
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":4}
+        ]`)
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	shard, _ := ParseNameTemplate("{first/1000}")
+	opts := ExtractOptions{Output: Output{Layout: Layout{Shard: shard}}}
+	if _, err := ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, opts); err != nil {
+		t.Fatalf("ConvertAssetsFromCatalogContent: %v", err)
+	}
+	sharded := filepath.Join(outputDir, "0", "Sprites-1-4-32x32.png")
+	if _, err := os.Stat(sharded); err != nil {
+		t.Fatalf("expected sharded sheet: %v", err)
+	}
+	if res, err := SplitSprites(outputDir, t.TempDir(), Output{}); err != nil || res.Processed != 1 {
+		t.Fatalf("SplitSprites = %+v, %v, want the sharded sheet", res, err)
+	}
+
+	// Going back to the built-in layout moves the sheet.
+	if _, err := ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, ExtractOptions{}); err != nil {
+		t.Fatalf("ConvertAssetsFromCatalogContent: %v", err)
+	}
+	if _, err := os.Stat(sharded); !os.IsNotExist(err) {
+		t.Fatalf("sharded sheet should be removed, stat error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "Sprites-1-4-32x32.png")); err != nil {
+		t.Fatalf("expected flat sheet: %v", err)
+	}
+
+	bad, _ := ParseNameTemplate("{id}")
+	if _, err := ConvertAssetsFromCatalogContent(assetsDir, catalog, outputDir, ExtractOptions{Output: Output{Layout: Layout{Name: bad}}}); err == nil {
+		t.Fatalf("expected {id} to be rejected for sheets")
+	}
+}
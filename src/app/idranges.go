@@ -0,0 +1,58 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IDRange is an inclusive range of sprite IDs.
+type IDRange struct {
+	First int
+	Last  int
+}
+
+// IDRanges selects sprite IDs. An empty IDRanges selects every ID.
+type IDRanges []IDRange
+
+// ParseIDRanges parses a comma separated list of IDs and inclusive ranges,
+// e.g. "100-200,305,410-420".
+func ParseIDRanges(s string) (IDRanges, error) {
+	var out IDRanges
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		a, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("invalid sprite ID %q", part)
+		}
+		b := a
+		if isRange {
+			b, err = strconv.Atoi(strings.TrimSpace(last))
+			if err != nil {
+				return nil, fmt.Errorf("invalid sprite ID range %q", part)
+			}
+		}
+		if b < a {
+			return nil, fmt.Errorf("invalid sprite ID range %q: end before start", part)
+		}
+		out = append(out, IDRange{First: a, Last: b})
+	}
+	return out, nil
+}
+
+// Contains reports whether id is selected.
+func (r IDRanges) Contains(id int) bool {
+	if len(r) == 0 {
+		return true
+	}
+	for _, rng := range r {
+		if id >= rng.First && id <= rng.Last {
+			return true
+		}
+	}
+	return false
+}
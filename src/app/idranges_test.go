@@ -0,0 +1,23 @@
+package app
+
+import "testing"
+
+func TestParseIDRanges(t *testing.T) {
+	ids, err := ParseIDRanges(" 10-20, 35 ,40-40")
+	if err != nil {
+		t.Fatalf("ParseIDRanges error: %v", err)
+	}
+	for id, want := range map[int]bool{9: false, 10: true, 20: true, 21: false, 35: true, 40: true, 41: false} {
+		if got := ids.Contains(id); got != want {
+			t.Fatalf("Contains(%d) = %v, want %v", id, got, want)
+		}
+	}
+	if !(IDRanges(nil)).Contains(12345) {
+		t.Fatalf("empty ranges should select every ID")
+	}
+	for _, bad := range []string{"a", "5-", "9-3"} {
+		if _, err := ParseIDRanges(bad); err == nil {
+			t.Fatalf("ParseIDRanges(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,467 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NameTemplate builds file names from placeholders in braces. A placeholder
+// is a name, optionally divided by a number and padded to a width:
+//
+//	{id}       sprite ID
+//	{id:06}    sprite ID zero-padded to 6 digits
+//	{id/1000}  sprite ID divided by 1000, e.g. for sharding
+//
+// Which names are available depends on what is written; see SheetNames,
+// SpriteNames and GroupNames.
+type NameTemplate struct {
+	src   string
+	parts []nameField
+}
+
+// nameField is one literal or placeholder of a NameTemplate.
+type nameField struct {
+	lit   string
+	name  string
+	div   int
+	width int
+	zero  bool
+}
+
+var placeholderPattern = regexp.MustCompile(`^([a-zA-Z]+)(?:/([1-9]\d*))?(?::(0?)([1-9]\d?))?$`)
+
+// ParseNameTemplate parses a template such as "{id:06}" or "{id/1000}".
+// The extension follows the image format, so a trailing image extension,
+// as in "{id:06}.png", is dropped.
+func ParseNameTemplate(s string) (*NameTemplate, error) {
+	for _, ext := range imageExtensions {
+		if strings.HasSuffix(strings.ToLower(s), ext) {
+			s = s[:len(s)-len(ext)]
+			break
+		}
+	}
+	if s == "" {
+		return nil, errors.New("name template is empty")
+	}
+	if strings.HasPrefix(s, "/") || strings.Contains(s, `\`) {
+		return nil, fmt.Errorf("name template %q: only relative, slash-separated paths are allowed", s)
+	}
+	t := &NameTemplate{src: s}
+	rest := s
+	for rest != "" {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			t.parts = append(t.parts, nameField{lit: rest})
+			break
+		}
+		if rest[open] == '}' {
+			return nil, fmt.Errorf("name template %q: unexpected }", s)
+		}
+		if open > 0 {
+			t.parts = append(t.parts, nameField{lit: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("name template %q: missing }", s)
+		}
+		m := placeholderPattern.FindStringSubmatch(rest[open+1 : open+end])
+		if m == nil {
+			return nil, fmt.Errorf("name template %q: invalid placeholder %q", s, rest[open:open+end+1])
+		}
+		f := nameField{name: m[1], zero: m[3] == "0"}
+		f.div, _ = strconv.Atoi(m[2])
+		f.width, _ = strconv.Atoi(m[4])
+		t.parts = append(t.parts, f)
+		rest = rest[open+end+1:]
+	}
+	for _, f := range t.parts {
+		if f.name != "" {
+			continue
+		}
+		for _, seg := range strings.Split(f.lit, "/") {
+			if seg == ".." {
+				return nil, fmt.Errorf("name template %q: .. is not allowed", s)
+			}
+		}
+	}
+	return t, nil
+}
+
+func (t *NameTemplate) String() string { return t.src }
+
+// nameVars holds the values of placeholders, ints or strings.
+type nameVars map[string]any
+
+// execute fills in the template. Placeholders without a value are empty.
+func (t *NameTemplate) execute(vars nameVars) string {
+	var b strings.Builder
+	for _, f := range t.parts {
+		if f.name == "" {
+			b.WriteString(f.lit)
+			continue
+		}
+		var s string
+		switch v := vars[f.name].(type) {
+		case int:
+			if f.div > 0 {
+				v /= f.div
+			}
+			s = strconv.Itoa(v)
+		case string:
+			s = v
+		}
+		pad := " "
+		if f.zero {
+			pad = "0"
+		}
+		if n := f.width - len(s); n > 0 {
+			s = strings.Repeat(pad, n) + s
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// pattern is a regular expression matching what execute writes. The first
+// undivided occurrence of every placeholder is captured in a group named
+// after it; captured lists the names captured so far.
+func (t *NameTemplate) pattern(captured map[string]bool) string {
+	var b strings.Builder
+	for _, f := range t.parts {
+		if f.name == "" {
+			b.WriteString(regexp.QuoteMeta(f.lit))
+			continue
+		}
+		p := namePlaceholderPatterns[f.name]
+		if p == "" {
+			p = `\d+`
+		}
+		if f.width > 0 {
+			p = ` *` + p
+		}
+		if f.div == 0 && !captured[f.name] {
+			captured[f.name] = true
+			fmt.Fprintf(&b, "(?P<%s>%s)", f.name, p)
+			continue
+		}
+		fmt.Fprintf(&b, "(?:%s)", p)
+	}
+	return b.String()
+}
+
+// namePlaceholderPatterns matches the placeholders that are not numbers.
+var namePlaceholderPatterns = map[string]string{
+	"type":     `\d+x\d+`,
+	"category": `[a-z]+`,
+	"name":     `[0-9a-z_]*`,
+}
+
+// NameKind lists the placeholders available for one kind of output.
+type NameKind struct {
+	what string
+	// vars maps every placeholder to whether it is a number.
+	vars map[string]bool
+	// required placeholders must appear undivided, so the files can be
+	// read back.
+	required []string
+}
+
+var (
+	// SheetNames are the placeholders for sheets written by extract: the
+	// sprite range {first} and {last} and the sprite size {type}, e.g.
+	// "32x32".
+	SheetNames = NameKind{
+		what:     "sheet",
+		vars:     map[string]bool{"first": true, "last": true, "type": false},
+		required: []string{"first", "last"},
+	}
+	// SpriteNames are the placeholders for sprites written by split: the
+	// sprite {id}, and the {first}, {last} and {type} of its sheet.
+	SpriteNames = NameKind{
+		what:     "sprite",
+		vars:     map[string]bool{"id": true, "first": true, "last": true, "type": false},
+		required: []string{"id"},
+	}
+	// GroupNames are the placeholders for groups written by group: the
+	// {appearance} ID, its {category} ("object", "outfit", "effect" or
+	// "missile") and {name} (lower case, with runs of other characters than
+	// letters and digits replaced by "_"), the {frameGroup} index within the
+	// appearance and the {first} and {last} sprite of the group.
+	GroupNames = NameKind{
+		what: "group",
+		vars: map[string]bool{"appearance": true, "category": false, "name": false, "frameGroup": true, "first": true, "last": true},
+	}
+)
+
+// Layout names the images an entry point writes. The zero value keeps the
+// built-in names in one flat directory.
+type Layout struct {
+	// Name builds the file name, without extension.
+	Name *NameTemplate
+	// Shard builds the directory, relative to the output directory, that
+	// the file is written into, e.g. "{id/1000}".
+	Shard *NameTemplate
+	// AppearanceNames adds the appearance name to the built-in group
+	// names. Name templates use {name} instead.
+	AppearanceNames bool
+}
+
+// fileSafeName lowers s and replaces every run of characters other than
+// ASCII letters and digits with "_", trimming it from both ends.
+func fileSafeName(s string) string {
+	var b strings.Builder
+	sep := false
+	for _, r := range strings.ToLower(s) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if sep && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			sep = false
+			b.WriteRune(r)
+			continue
+		}
+		sep = true
+	}
+	return b.String()
+}
+
+// layoutFileName records a custom Layout next to the files it names, so
+// that later steps can find them.
+const layoutFileName = "layout.json"
+
+// layoutJSON is the content of layoutFileName.
+type layoutJSON struct {
+	Name  string `json:"name,omitempty"`
+	Shard string `json:"shard,omitempty"`
+}
+
+func (l Layout) custom() bool {
+	return l.Name != nil || l.Shard != nil
+}
+
+// check rejects templates using placeholders kind does not have, or missing
+// the ones it needs.
+func (l Layout) check(kind NameKind) error {
+	if !l.custom() {
+		return nil
+	}
+	undivided := make(map[string]bool)
+	for _, t := range []*NameTemplate{l.Shard, l.Name} {
+		if t == nil {
+			continue
+		}
+		for _, f := range t.parts {
+			if f.name == "" {
+				continue
+			}
+			numeric, ok := kind.vars[f.name]
+			if !ok {
+				return fmt.Errorf("name template %q: unknown %s placeholder {%s}", t, kind.what, f.name)
+			}
+			if !numeric && f.div > 0 {
+				return fmt.Errorf("name template %q: {%s} cannot be divided", t, f.name)
+			}
+			if f.div == 0 {
+				undivided[f.name] = true
+			}
+		}
+	}
+	if l.Name != nil {
+		for _, f := range l.Name.parts {
+			if strings.Contains(f.lit, "/") {
+				return fmt.Errorf("name template %q: use the shard template for directories", l.Name)
+			}
+		}
+	}
+	// The built-in names hold the required placeholders.
+	if l.Name == nil {
+		return nil
+	}
+	for _, name := range kind.required {
+		if !undivided[name] {
+			return fmt.Errorf("name templates must contain {%s} to tell every %s apart", name, kind.what)
+		}
+	}
+	return nil
+}
+
+// file returns the slash-separated path of an image relative to the output
+// directory. def is the built-in name, used without a Name template.
+func (l Layout) file(def string, vars nameVars, ext string) string {
+	name := def
+	if l.Name != nil {
+		name = l.Name.execute(vars)
+	}
+	if l.Shard != nil {
+		if dir := l.Shard.execute(vars); dir != "" {
+			name = path.Join(dir, name)
+		}
+	}
+	return name + ext
+}
+
+// matcher returns the pattern matching the paths l writes, with a group
+// named after every placeholder it can read back. def matches the built-in
+// names.
+func (l Layout) matcher(def *regexp.Regexp, defName string) *regexp.Regexp {
+	if !l.custom() {
+		return def
+	}
+	captured := make(map[string]bool)
+	var b strings.Builder
+	b.WriteString("^")
+	if l.Shard != nil {
+		b.WriteString("(?:")
+		b.WriteString(l.Shard.pattern(captured))
+		b.WriteString("/)?")
+	}
+	if l.Name != nil {
+		b.WriteString(l.Name.pattern(captured))
+	} else {
+		b.WriteString(defName)
+	}
+	b.WriteString(imageExtPattern + "$")
+	return regexp.MustCompile(b.String())
+}
+
+// save records a custom layout in dir, and removes the record of an earlier
+// run otherwise.
+func (l Layout) save(dir string, out Output) error {
+	if !l.custom() {
+		if out.Sink != nil {
+			return nil
+		}
+		err := os.Remove(filepath.Join(dir, layoutFileName))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	var rec layoutJSON
+	if l.Name != nil {
+		rec.Name = l.Name.String()
+	}
+	if l.Shard != nil {
+		rec.Shard = l.Shard.String()
+	}
+	data, err := json.MarshalIndent(rec, "", "    ")
+	if err != nil {
+		return err
+	}
+	return out.writeFile(dir, layoutFileName, data)
+}
+
+// loadLayout reads the layout recorded in dir. Without a record, dir uses
+// the built-in names.
+func loadLayout(dir string) (Layout, error) {
+	var l Layout
+	data, err := os.ReadFile(filepath.Join(dir, layoutFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return l, err
+	}
+	var rec layoutJSON
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return l, fmt.Errorf("%s: %w", layoutFileName, err)
+	}
+	if rec.Name != "" {
+		if l.Name, err = ParseNameTemplate(rec.Name); err != nil {
+			return Layout{}, err
+		}
+	}
+	if rec.Shard != "" {
+		if l.Shard, err = ParseNameTemplate(rec.Shard); err != nil {
+			return Layout{}, err
+		}
+	}
+	return l, nil
+}
+
+// layoutFile is an image found by scanLayout, with the placeholder values
+// read back from its path.
+type layoutFile struct {
+	// name is slash-separated and relative to the scanned directory.
+	name string
+	vars map[string]string
+}
+
+// scanLayout lists the images in dir that its recorded layout names, with
+// def matching the built-in names. Custom layouts are searched in every
+// subdirectory, the built-in one only in dir itself.
+func scanLayout(dir string, def *regexp.Regexp, defName string) ([]layoutFile, error) {
+	l, err := loadLayout(dir)
+	if err != nil {
+		return nil, err
+	}
+	re := l.matcher(def, defName)
+
+	var files []layoutFile
+	add := func(name string) {
+		if m := re.FindStringSubmatch(name); m != nil {
+			vars := make(map[string]string)
+			for i, n := range re.SubexpNames() {
+				if n != "" && m[i] != "" {
+					vars[n] = strings.TrimLeft(m[i], " ")
+				}
+			}
+			files = append(files, layoutFile{name: name, vars: vars})
+		}
+	}
+
+	if !l.custom() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				add(e.Name())
+			}
+		}
+		return files, nil
+	}
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		add(filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+// splitSpriteFiles lists the sprites written by split into splitDir by ID,
+// as slash-separated paths relative to splitDir. PNG files win over other
+// formats of the same sprite.
+func splitSpriteFiles(splitDir string) (map[int]string, error) {
+	files, err := scanLayout(splitDir, splitFilePattern, splitNamePattern)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[int]string, len(files))
+	for _, f := range files {
+		id, err := strconv.Atoi(f.vars["id"])
+		if err != nil {
+			continue
+		}
+		if prev, ok := out[id]; ok && path.Ext(prev) == ".png" {
+			continue
+		}
+		out[id] = f.name
+	}
+	return out, nil
+}
@@ -0,0 +1,195 @@
+package app
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNameTemplateExecute(t *testing.T) {
+	tests := []struct {
+		tmpl string
+		vars nameVars
+		want string
+	}{
+		{"{id:06}", nameVars{"id": 1234}, "001234"},
+		{"{id/1000}", nameVars{"id": 12345}, "12"},
+		{"{id/1000:03}/x", nameVars{"id": 2500}, "002/x"},
+		{"{category}_{appearance}-{frameGroup}", nameVars{"category": "outfit", "appearance": 7, "frameGroup": 1}, "outfit_7-1"},
+		{"sheet-{type}", nameVars{"type": "32x64"}, "sheet-32x64"},
+		{"{missing}x", nil, "x"},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseNameTemplate(tt.tmpl)
+		if err != nil {
+			t.Fatalf("ParseNameTemplate(%q): %v", tt.tmpl, err)
+		}
+		if got := tmpl.execute(tt.vars); got != tt.want {
+			t.Fatalf("%q = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestParseNameTemplateRejectsBadTemplates(t *testing.T) {
+	for _, s := range []string{"", "{id", "id}", "{id/0}", "{id:x}", "{}", "/abs/{id}", `a\{id}`, "../{id}", ".png"} {
+		if _, err := ParseNameTemplate(s); err == nil {
+			t.Fatalf("ParseNameTemplate(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestParseNameTemplateDropsImageExtension(t *testing.T) {
+	for _, s := range []string{"{id:06}.png", "{id:06}.PNG", "{id:06}.tiff"} {
+		tmpl, err := ParseNameTemplate(s)
+		if err != nil {
+			t.Fatalf("ParseNameTemplate(%q): %v", s, err)
+		}
+		if tmpl.String() != "{id:06}" {
+			t.Fatalf("ParseNameTemplate(%q) = %q, want {id:06}", s, tmpl)
+		}
+		if got := (Layout{Name: tmpl}).file("123", nameVars{"id": 123}, ".png"); got != "000123.png" {
+			t.Fatalf("file name for %q = %q, want 000123.png", s, got)
+		}
+	}
+}
+
+func TestFileSafeName(t *testing.T) {
+	for in, want := range map[string]string{
+		"gold coin":            "gold_coin",
+		"  Mage's Cap (Red)  ": "mage_s_cap_red",
+		"ÄÖ":                   "",
+		"brass helmet--2":      "brass_helmet_2",
+	} {
+		if got := fileSafeName(in); got != want {
+			t.Fatalf("fileSafeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLayoutCheck(t *testing.T) {
+	tmpl := func(s string) *NameTemplate {
+		t.Helper()
+		nt, err := ParseNameTemplate(s)
+		if err != nil {
+			t.Fatalf("ParseNameTemplate(%q): %v", s, err)
+		}
+		return nt
+	}
+	tests := []struct {
+		layout Layout
+		kind   NameKind
+		errSub string
+	}{
+		{Layout{Name: tmpl("{id:06}"), Shard: tmpl("{id/1000}")}, SpriteNames, ""},
+		{Layout{Shard: tmpl("{first/10000}")}, SheetNames, ""},
+		{Layout{Name: tmpl("{category}_{appearance}")}, GroupNames, ""},
+		{Layout{Name: tmpl("{appearance}_{name}")}, GroupNames, ""},
+		{Layout{Name: tmpl("{name/2}")}, GroupNames, "cannot be divided"},
+		{Layout{Name: tmpl("{id/10}")}, SpriteNames, "must contain {id}"},
+		{Layout{Name: tmpl("{first}")}, SheetNames, "must contain {last}"},
+		{Layout{Shard: tmpl("{id/1000}")}, SheetNames, "unknown sheet placeholder {id}"},
+		{Layout{Shard: tmpl("{type/2}")}, SpriteNames, "cannot be divided"},
+		{Layout{Name: tmpl("a/{id}")}, SpriteNames, "shard template"},
+	}
+	for _, tt := range tests {
+		err := tt.layout.check(tt.kind)
+		if tt.errSub == "" {
+			if err != nil {
+				t.Fatalf("check(%+v) = %v", tt.layout, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.errSub) {
+			t.Fatalf("check(%+v) = %v, want %q", tt.layout, err, tt.errSub)
+		}
+	}
+}
+
+func TestCustomLayoutsRoundTrip(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	// Sheets as extract writes them with --sheetName and --shard.
+	extracted := t.TempDir()
+	sheetName, _ := ParseNameTemplate("{first:05}_{last:05}@{type}")
+	sheetShard, _ := ParseNameTemplate("sheets/{first/1000}")
+	sheetLayout := Layout{Name: sheetName, Shard: sheetShard}
+	sheet := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	sheet.SetNRGBA(1, 1, color.NRGBA{R: 0xFF, A: 0xFF})
+	sheet.SetNRGBA(33, 1, color.NRGBA{B: 0xFF, A: 0xFF})
+	name := sheetLayout.file("", nameVars{"first": 1999, "last": 2000, "type": "32x32"}, ".png")
+	if name != "sheets/1/01999_02000@32x32.png" {
+		t.Fatalf("sheet name = %q", name)
+	}
+	writeTestPNG(t, filepath.Join(extracted, filepath.FromSlash(name)), sheet)
+	if err := sheetLayout.save(extracted, Output{}); err != nil {
+		t.Fatalf("save layout: %v", err)
+	}
+
+	split := t.TempDir()
+	spriteName, _ := ParseNameTemplate("{id:06}")
+	spriteShard, _ := ParseNameTemplate("{id/1000}")
+	res, err := SplitSprites(extracted, split, Output{Layout: Layout{Name: spriteName, Shard: spriteShard}})
+	if err != nil || res.Processed != 1 {
+		t.Fatalf("SplitSprites = %+v, %v, want 1 sheet", res, err)
+	}
+	for _, p := range []string{"1/001999.png", "2/002000.png", layoutFileName} {
+		if _, err := os.Stat(filepath.Join(split, filepath.FromSlash(p))); err != nil {
+			t.Fatalf("expected %s: %v", p, err)
+		}
+	}
+
+	files, err := splitSpriteFiles(split)
+	if err != nil || len(files) != 2 || files[2000] != "2/002000.png" {
+		t.Fatalf("splitSpriteFiles = %v, %v", files, err)
+	}
+	img, err := openSpriteDir(split).Sprite(2000)
+	if err != nil {
+		t.Fatalf("Sprite(2000): %v", err)
+	}
+	if _, _, b, _ := img.At(1, 1).RGBA(); b == 0 {
+		t.Fatalf("Sprite(2000) is not the blue sprite")
+	}
+	if _, err := openSpriteDir(split).Sprite(2001); !strings.Contains(err.Error(), "not exist") {
+		t.Fatalf("Sprite(2001) error = %v, want not exist", err)
+	}
+	if res, err := WriteSpritePack(split, filepath.Join(t.TempDir(), "sprites.pack"), nil); err != nil || res.Processed != 2 {
+		t.Fatalf("WriteSpritePack = %+v, %v, want 2 sprites", res, err)
+	}
+
+	// Splitting again with the built-in names drops the layout record.
+	if _, err := SplitSprites(extracted, split, Output{}); err != nil {
+		t.Fatalf("SplitSprites: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(split, layoutFileName)); !os.IsNotExist(err) {
+		t.Fatalf("%s should be removed, stat error = %v", layoutFileName, err)
+	}
+	if _, err := os.Stat(filepath.Join(split, "2000.png")); err != nil {
+		t.Fatalf("expected 2000.png: %v", err)
+	}
+}
+
+func TestCustomLayoutWithJSONDedup(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	extracted := t.TempDir()
+	writeDuplicateSheet(t, extracted)
+	split := t.TempDir()
+	shard, _ := ParseNameTemplate("{id/2}")
+	d, _ := NewDeduper(DedupJSON)
+	if _, err := SplitSprites(extracted, split, Output{Dedup: d, Layout: Layout{Shard: shard}}); err != nil {
+		t.Fatalf("SplitSprites: %v", err)
+	}
+
+	dups := duplicateSprites(split)
+	if len(dups) != 2 || dups[103] != "50/100.png" {
+		t.Fatalf("duplicateSprites = %v", dups)
+	}
+	if _, err := openSpriteDir(split).Sprite(101); err != nil {
+		t.Fatalf("Sprite(101): %v", err)
+	}
+}
@@ -0,0 +1,416 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
+
+// The outfit palette has 7 rows of 19 colors: a gray followed by 18 hues at
+// one saturation and intensity.
+const (
+	outfitHues   = 19
+	outfitShades = 7
+	// OutfitPaletteSize is the number of colors an outfit part can be dyed
+	// with; valid indices are 0 to OutfitPaletteSize-1.
+	OutfitPaletteSize = outfitHues * outfitShades
+)
+
+// outfitShadeSI are the saturation and intensity of the hues of every row
+// of the outfit palette.
+var outfitShadeSI = [outfitShades][2]float64{
+	{0.25, 1}, {0.25, 0.75}, {0.5, 0.75}, {0.667, 0.75}, {1, 1}, {1, 0.75}, {1, 0.5},
+}
+
+// ErrOutfitNotFound is returned for outfit IDs the appearances do not list.
+var ErrOutfitNotFound = errors.New("outfit not found")
+
+// OutfitColor returns color index of the outfit palette, computed from hue,
+// saturation and intensity as the client does. Indices outside the palette
+// give its first color, like in the client.
+func OutfitColor(index int) color.NRGBA {
+	if index < 0 || index >= OutfitPaletteSize {
+		index = 0
+	}
+	var hue, sat, val float64
+	if index%outfitHues == 0 {
+		// The first column is a gray ramp from white down.
+		val = 1 - float64(index/outfitHues)/outfitShades
+	} else {
+		hue = float64(index%outfitHues) / (outfitHues - 1)
+		sat, val = outfitShadeSI[index/outfitHues][0], outfitShadeSI[index/outfitHues][1]
+	}
+	if sat == 0 {
+		v := uint8(val * 255)
+		return color.NRGBA{R: v, G: v, B: v, A: 0xFF}
+	}
+
+	var r, g, b float64
+	switch {
+	case hue < 1.0/6:
+		r, b = val, val*(1-sat)
+		g = b + (val-b)*6*hue
+	case hue < 2.0/6:
+		g, b = val, val*(1-sat)
+		r = g - (val-b)*(6*hue-1)
+	case hue < 3.0/6:
+		g, r = val, val*(1-sat)
+		b = r + (val-r)*(6*hue-2)
+	case hue < 4.0/6:
+		b, r = val, val*(1-sat)
+		g = b - (val-r)*(6*hue-3)
+	case hue < 5.0/6:
+		b, g = val, val*(1-sat)
+		r = g + (val-g)*(6*hue-4)
+	default:
+		r, g = val, val*(1-sat)
+		b = r - (val-g)*(6*hue-5)
+	}
+	return color.NRGBA{R: uint8(r * 255), G: uint8(g * 255), B: uint8(b * 255), A: 0xFF}
+}
+
+// OutfitColors are the palette indices the four parts of an outfit are dyed
+// with.
+type OutfitColors struct {
+	Head, Body, Legs, Feet int
+}
+
+func (c OutfitColors) check() error {
+	for _, part := range []struct {
+		name  string
+		index int
+	}{{"head", c.Head}, {"body", c.Body}, {"legs", c.Legs}, {"feet", c.Feet}} {
+		if part.index < 0 || part.index >= OutfitPaletteSize {
+			return fmt.Errorf("%s color %d is outside the outfit palette (0-%d)", part.name, part.index, OutfitPaletteSize-1)
+		}
+	}
+	return nil
+}
+
+// Direction is the way a creature faces, numbered like the x patterns of
+// outfits.
+type Direction int
+
+const (
+	DirectionNorth Direction = iota
+	DirectionEast
+	DirectionSouth
+	DirectionWest
+)
+
+// Directions lists the directions in pattern order.
+var Directions = []Direction{DirectionNorth, DirectionEast, DirectionSouth, DirectionWest}
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionNorth:
+		return "north"
+	case DirectionEast:
+		return "east"
+	case DirectionSouth:
+		return "south"
+	case DirectionWest:
+		return "west"
+	}
+	return fmt.Sprintf("direction(%d)", int(d))
+}
+
+// ParseDirection reads a direction by name or initial, e.g. "south" or "s".
+func ParseDirection(s string) (Direction, error) {
+	s = strings.ToLower(s)
+	for _, d := range Directions {
+		if name := d.String(); s == name || s == name[:1] {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown direction %q (want north, east, south or west)", s)
+}
+
+// Outfit addons, the bits of OutfitOptions.Addons.
+const (
+	OutfitAddon1 = 1 << iota
+	OutfitAddon2
+	// OutfitAddons has every addon bit set.
+	OutfitAddons = OutfitAddon1 | OutfitAddon2
+)
+
+// OutfitOptions select how RenderOutfit draws an outfit.
+type OutfitOptions struct {
+	Colors    OutfitColors
+	Direction Direction
+	// Addons is a bitmask of OutfitAddon1 and OutfitAddon2, drawn from the
+	// second and third y pattern of the outfit.
+	Addons int
+	// Mount is the outfit ID of the mount drawn underneath, or 0 for none.
+	// The outfit is then drawn from its mounted z pattern.
+	Mount int
+	// Strip draws all four directions side by side, in Directions order,
+	// instead of Direction only.
+	Strip bool
+}
+
+func (o OutfitOptions) check() error {
+	if err := o.Colors.check(); err != nil {
+		return err
+	}
+	if o.Addons&^OutfitAddons != 0 {
+		return fmt.Errorf("addons %d is not a mask of addon 1 (1) and addon 2 (2)", o.Addons)
+	}
+	return nil
+}
+
+// fileName is the built-in name of an outfit rendered with o, e.g.
+// "128_78-69-58-76_south" or "128_78-69-58-76_addons3_mount368_strip".
+func (o OutfitOptions) fileName(id int) string {
+	c := o.Colors
+	name := fmt.Sprintf("%d_%d-%d-%d-%d", id, c.Head, c.Body, c.Legs, c.Feet)
+	if o.Addons != 0 {
+		name += fmt.Sprintf("_addons%d", o.Addons)
+	}
+	if o.Mount != 0 {
+		name += fmt.Sprintf("_mount%d", o.Mount)
+	}
+	if o.Strip {
+		return name + "_strip"
+	}
+	return name + "_" + o.Direction.String()
+}
+
+// RenderOutfit draws the first idle frame of outfit id of c facing
+// opts.Direction, dyed with opts.Colors, with the addons and mount of opts.
+func RenderOutfit(c *sprites.Client, id int, opts OutfitOptions) (*image.NRGBA, error) {
+	apps, err := c.Appearances()
+	if err != nil {
+		return nil, fmt.Errorf("read appearances: %w", err)
+	}
+	return renderOutfit(c, apps, id, opts)
+}
+
+// WriteOutfit renders outfit id of the client in assetsPath with
+// RenderOutfit and writes it into outputDir. It returns the slash-separated
+// name of the file written, relative to outputDir.
+func WriteOutfit(assetsPath, outputDir string, id int, opts OutfitOptions, out Output) (string, error) {
+	names, err := WriteOutfits(assetsPath, outputDir, id, opts, []Direction{opts.Direction}, out)
+	if err != nil {
+		return "", err
+	}
+	return names[0], nil
+}
+
+// WriteOutfits is WriteOutfit for every direction of directions, reading the
+// client once. With opts.Strip it writes the one strip of all directions.
+func WriteOutfits(assetsPath, outputDir string, id int, opts OutfitOptions, directions []Direction, out Output) ([]string, error) {
+	if err := out.validate(); err != nil {
+		return nil, err
+	}
+	if opts.Strip || len(directions) == 0 {
+		directions = []Direction{opts.Direction}
+	}
+	client, err := sprites.OpenClient(assetsPath)
+	if err != nil {
+		return nil, err
+	}
+	apps, err := client.Appearances()
+	if err != nil {
+		return nil, fmt.Errorf("read appearances: %w", err)
+	}
+
+	imgs := make([]*image.NRGBA, len(directions))
+	for i, d := range directions {
+		opts.Direction = d
+		if imgs[i], err = renderOutfit(client, apps, id, opts); err != nil {
+			return nil, err
+		}
+	}
+	if err := out.mkdir(outputDir); err != nil {
+		return nil, fmt.Errorf("create %s: %w", outputDir, err)
+	}
+	names := make([]string, len(directions))
+	for i, d := range directions {
+		opts.Direction = d
+		names[i] = opts.fileName(id) + out.Ext()
+		if err := out.writeImage(outputDir, names[i], out.scale(imgs[i])); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// outfitFrameGroup returns the idle frame group of outfit id, or its first
+// frame group when it has no idle one.
+func outfitFrameGroup(apps *sprites.Appearances, id int) (sprites.SpriteInfo, error) {
+	for _, a := range apps.Outfits {
+		if a.ID != id {
+			continue
+		}
+		if len(a.FrameGroups) == 0 {
+			return sprites.SpriteInfo{}, fmt.Errorf("outfit %d has no frame groups", id)
+		}
+		info := a.FrameGroups[0].SpriteInfo
+		for _, g := range a.FrameGroups {
+			if g.FixedFrameGroup == sprites.FrameGroupOutfitIdle {
+				info = g.SpriteInfo
+				break
+			}
+		}
+		if want := info.SpriteCount(); len(info.SpriteIDs) < want {
+			return sprites.SpriteInfo{}, fmt.Errorf("outfit %d lists %d sprites, want %d", id, len(info.SpriteIDs), want)
+		}
+		return info, nil
+	}
+	return sprites.SpriteInfo{}, fmt.Errorf("outfit %d: %w", id, ErrOutfitNotFound)
+}
+
+func renderOutfit(src spriteSource, apps *sprites.Appearances, id int, opts OutfitOptions) (*image.NRGBA, error) {
+	if err := opts.check(); err != nil {
+		return nil, err
+	}
+	info, err := outfitFrameGroup(apps, id)
+	if err != nil {
+		return nil, err
+	}
+	var mount *sprites.SpriteInfo
+	if opts.Mount != 0 {
+		m, err := outfitFrameGroup(apps, opts.Mount)
+		if err != nil {
+			return nil, fmt.Errorf("mount: %w", err)
+		}
+		mount = &m
+	}
+
+	directions := []Direction{opts.Direction}
+	if opts.Strip {
+		directions = Directions
+	}
+	frames := make([][]image.Image, len(directions))
+	var cell image.Point
+	for i, d := range directions {
+		if frames[i], err = outfitSprites(src, info, mount, d, opts); err != nil {
+			return nil, err
+		}
+		for _, img := range frames[i] {
+			b := img.Bounds()
+			cell.X, cell.Y = max(cell.X, b.Dx()), max(cell.Y, b.Dy())
+		}
+	}
+
+	// Sprites larger than a tile grow up and left, so all are drawn with
+	// their bottom-right corner at the one of the cell.
+	dst := image.NewNRGBA(image.Rect(0, 0, cell.X*len(frames), cell.Y))
+	for i, frame := range frames {
+		corner := image.Pt((i+1)*cell.X, cell.Y)
+		for _, img := range frame {
+			b := img.Bounds()
+			draw.Draw(dst, image.Rectangle{Min: corner.Sub(b.Size()), Max: corner}, img, b.Min, draw.Over)
+		}
+	}
+	return dst, nil
+}
+
+// outfitSprites returns the images making up the outfit facing d, in drawing
+// order: the mount, the outfit and its addons, each dyed.
+func outfitSprites(src spriteSource, info sprites.SpriteInfo, mount *sprites.SpriteInfo, d Direction, opts OutfitOptions) ([]image.Image, error) {
+	var imgs []image.Image
+	z := 0
+	if mount != nil {
+		// Mounts are not dyed here, so only their base layer is drawn.
+		img, err := src.Sprite(mount.SpriteIDs[mount.SpriteIndex(0, 0, 0, int(d)%mount.PatternsX(), 0)])
+		if err != nil {
+			return nil, fmt.Errorf("mount: %w", err)
+		}
+		imgs = append(imgs, img)
+		z = min(1, info.PatternsZ()-1)
+	}
+
+	x := int(d) % info.PatternsX()
+	for y := 0; y < info.PatternsY(); y++ {
+		// The y patterns past the first are the addons, which outfits
+		// without them simply lack.
+		if y > 0 && opts.Addons&(1<<(y-1)) == 0 {
+			continue
+		}
+		base, err := src.Sprite(info.SpriteIDs[info.SpriteIndex(0, z, y, x, 0)])
+		if err != nil {
+			return nil, err
+		}
+		// The second layer is the color template; outfits without one
+		// cannot be dyed.
+		var mask image.Image
+		if info.LayerCount() > 1 {
+			if mask, err = src.Sprite(info.SpriteIDs[info.SpriteIndex(0, z, y, x, 1)]); err != nil {
+				return nil, err
+			}
+		}
+		imgs = append(imgs, colorizeOutfit(base, mask, opts.Colors))
+	}
+	return imgs, nil
+}
+
+// colorizeOutfit multiplies the pixels of base by the color of the part the
+// template mask marks them as: yellow for the head, red for the body, green
+// for the legs and blue for the feet. Pixels the mask leaves transparent or
+// marks otherwise keep their color.
+func colorizeOutfit(base, mask image.Image, colors OutfitColors) *image.NRGBA {
+	dst := toNRGBA(base)
+	if mask == nil {
+		return dst
+	}
+	tints := map[outfitPart]color.NRGBA{
+		partHead: OutfitColor(colors.Head),
+		partBody: OutfitColor(colors.Body),
+		partLegs: OutfitColor(colors.Legs),
+		partFeet: OutfitColor(colors.Feet),
+	}
+	mb := mask.Bounds()
+	b := dst.Bounds()
+	for y := 0; y < b.Dy() && y < mb.Dy(); y++ {
+		for x := 0; x < b.Dx() && x < mb.Dx(); x++ {
+			tint, ok := tints[maskPart(mask.At(mb.Min.X+x, mb.Min.Y+y))]
+			if !ok {
+				continue
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(int(dst.Pix[i]) * int(tint.R) / 0xFF)
+			dst.Pix[i+1] = uint8(int(dst.Pix[i+1]) * int(tint.G) / 0xFF)
+			dst.Pix[i+2] = uint8(int(dst.Pix[i+2]) * int(tint.B) / 0xFF)
+		}
+	}
+	return dst
+}
+
+// outfitPart is a part of an outfit the color template marks.
+type outfitPart int
+
+const (
+	partNone outfitPart = iota
+	partHead
+	partBody
+	partLegs
+	partFeet
+)
+
+// maskPart tells which part a template pixel marks. Channels count as set
+// from half intensity, so slightly off template colors still match.
+func maskPart(c color.Color) outfitPart {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A == 0 {
+		return partNone
+	}
+	r, g, b := n.R >= 0x80, n.G >= 0x80, n.B >= 0x80
+	switch {
+	case r && g && !b:
+		return partHead
+	case r && !g && !b:
+		return partBody
+	case !r && g && !b:
+		return partLegs
+	case !r && !g && b:
+		return partFeet
+	}
+	return partNone
+}
@@ -0,0 +1,241 @@
+package app
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
+
+func TestOutfitColorMatchesClientPalette(t *testing.T) {
+	for _, tt := range []struct {
+		index int
+		want  color.NRGBA
+	}{
+		{0, color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+		{1, color.NRGBA{R: 255, G: 212, B: 191, A: 255}},
+		{19, color.NRGBA{R: 218, G: 218, B: 218, A: 255}},
+		{132, color.NRGBA{R: 127, A: 255}},
+		{133, color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+	} {
+		if got := OutfitColor(tt.index); got != tt.want {
+			t.Fatalf("OutfitColor(%d) = %#v, want %#v", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestColorizeOutfitTintsTemplateParts(t *testing.T) {
+	base := solidImage(5, 1, color.NRGBA{R: 200, G: 200, B: 200, A: 255})
+	mask := image.NewNRGBA(image.Rect(0, 0, 5, 1))
+	mask.SetNRGBA(0, 0, color.NRGBA{R: 255, G: 255, A: 255})
+	mask.SetNRGBA(1, 0, color.NRGBA{R: 255, A: 255})
+	mask.SetNRGBA(2, 0, color.NRGBA{G: 255, A: 255})
+	mask.SetNRGBA(3, 0, color.NRGBA{B: 255, A: 255})
+	colors := OutfitColors{Head: 1, Body: 132, Legs: 19, Feet: 0}
+
+	got := colorizeOutfit(base, mask, colors)
+	tint := func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{R: uint8(200 * int(c.R) / 255), G: uint8(200 * int(c.G) / 255), B: uint8(200 * int(c.B) / 255), A: 255}
+	}
+	for x, want := range []color.NRGBA{
+		tint(OutfitColor(1)),
+		tint(OutfitColor(132)),
+		tint(OutfitColor(19)),
+		tint(OutfitColor(0)),
+		{R: 200, G: 200, B: 200, A: 255},
+	} {
+		if c := got.NRGBAAt(x, 0); c != want {
+			t.Fatalf("pixel %d = %#v, want %#v", x, c, want)
+		}
+	}
+}
+
+func outfitAppearances(ids ...int) *sprites.Appearances {
+	info := sprites.SpriteInfo{PatternWidth: 4, PatternHeight: 1, PatternDepth: 1, Layers: 2, SpriteIDs: ids}
+	return &sprites.Appearances{Outfits: []sprites.Appearance{{
+		ID: 128,
+		FrameGroups: []sprites.FrameGroup{
+			{FixedFrameGroup: sprites.FrameGroupOutfitMoving},
+			{FixedFrameGroup: sprites.FrameGroupOutfitIdle, SpriteInfo: info},
+		},
+	}}}
+}
+
+func TestRenderOutfitDyesTheFacedDirection(t *testing.T) {
+	dir := t.TempDir()
+	gray := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	for id := 1; id <= 8; id++ {
+		c := gray
+		if id%2 == 0 {
+			// Templates: the south one marks the body, the others the head.
+			c = color.NRGBA{R: 255, G: 255, A: 255}
+			if id == 6 {
+				c = color.NRGBA{R: 255, A: 255}
+			}
+		}
+		writeSolidTile(t, dir, id, c, 32)
+	}
+	apps := outfitAppearances(1, 2, 3, 4, 5, 6, 7, 8)
+
+	opts := OutfitOptions{Colors: OutfitColors{Head: 0, Body: 132, Legs: 0, Feet: 0}, Direction: DirectionSouth}
+	img, err := renderOutfit(openSpriteDir(dir), apps, 128, opts)
+	if err != nil {
+		t.Fatalf("renderOutfit: %v", err)
+	}
+	if got := img.NRGBAAt(10, 10); got != OutfitColor(132) {
+		t.Fatalf("south pixel = %#v, want body color %#v", got, OutfitColor(132))
+	}
+
+	if _, err := renderOutfit(openSpriteDir(dir), apps, 129, opts); !errors.Is(err, ErrOutfitNotFound) {
+		t.Fatalf("unknown outfit error = %v, want ErrOutfitNotFound", err)
+	}
+	opts.Colors.Feet = 133
+	if _, err := renderOutfit(openSpriteDir(dir), apps, 128, opts); err == nil || !strings.Contains(err.Error(), "feet color 133") {
+		t.Fatalf("bad color error = %v", err)
+	}
+}
+
+func TestParseDirection(t *testing.T) {
+	for s, want := range map[string]Direction{"north": DirectionNorth, "E": DirectionEast, "south": DirectionSouth, "w": DirectionWest} {
+		if got, err := ParseDirection(s); err != nil || got != want {
+			t.Fatalf("ParseDirection(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseDirection("up"); err == nil {
+		t.Fatalf("ParseDirection(up) succeeded")
+	}
+}
+
+func TestWriteOutfitReadsTheClient(t *testing.T) {
+	dir := writeTestClient(t, map[string]image.Image{"a.bin": newTestImage(384, 384)}, `[
+                {"type":"appearances","file":"appearances.dat"},
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":8,"area":0}
+        ]`)
+	outfit := protoMessage{}.
+		varint(1, 128).
+		message(2, protoMessage{}.varint(1, int(sprites.FrameGroupOutfitIdle)).message(3, buildSpriteInfo(4, 1, 1, 2, 1, 2, 3, 4, 5, 6, 7, 8)))
+	if err := os.WriteFile(filepath.Join(dir, "appearances.dat"), protoMessage{}.message(2, outfit), 0o644); err != nil {
+		t.Fatalf("write appearances: %v", err)
+	}
+
+	out := t.TempDir()
+	name, err := WriteOutfit(dir, out, 128, OutfitOptions{Colors: OutfitColors{Head: 78, Body: 69, Legs: 58, Feet: 76}, Direction: DirectionSouth}, Output{})
+	if err != nil {
+		t.Fatalf("WriteOutfit: %v", err)
+	}
+	if name != "128_78-69-58-76_south.png" {
+		t.Fatalf("name = %q", name)
+	}
+	if b := decodePNG(t, filepath.Join(out, name)).Bounds(); b.Dx() != 32 || b.Dy() != 32 {
+		t.Fatalf("outfit image is %v, want 32x32", b)
+	}
+
+	names, err := WriteOutfits(dir, out, 128, OutfitOptions{}, Directions, Output{})
+	if err != nil {
+		t.Fatalf("WriteOutfits: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"128_0-0-0-0_north.png", "128_0-0-0-0_east.png", "128_0-0-0-0_south.png", "128_0-0-0-0_west.png"}) {
+		t.Fatalf("names = %v", names)
+	}
+	if names, err = WriteOutfits(dir, out, 128, OutfitOptions{Strip: true}, Directions, Output{}); err != nil || len(names) != 1 {
+		t.Fatalf("WriteOutfits strip = %v, %v", names, err)
+	}
+	if b := decodePNG(t, filepath.Join(out, names[0])).Bounds(); b.Dx() != 128 || b.Dy() != 32 {
+		t.Fatalf("strip image is %v, want 128x32", b)
+	}
+}
+
+// writeMarkedSprite writes a transparent sprite of size with one pixel set
+// at mark, so composed images tell which sprites were drawn where.
+func writeMarkedSprite(t *testing.T, dir string, id, size int, mark image.Point) {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	img.SetNRGBA(mark.X, mark.Y, tileColor(id))
+	writeTestPNG(t, filepath.Join(dir, strconv.Itoa(id)+".png"), img)
+}
+
+func TestRenderOutfitAddonsMountAndStrip(t *testing.T) {
+	dir := t.TempDir()
+	// Outfit 128 has 4 directions, the base and two addons, unmounted and
+	// mounted: sprites 1-24, each marking pixel (id-1, id-1).
+	for id := 1; id <= 24; id++ {
+		writeMarkedSprite(t, dir, id, 32, image.Pt(id-1, id-1))
+	}
+	// Mount 368 is a 64x64 sprite per direction, 25-28.
+	for id := 25; id <= 28; id++ {
+		writeMarkedSprite(t, dir, id, 64, image.Pt(id-25, 0))
+	}
+	ids := func(from, to int) []int {
+		var list []int
+		for id := from; id <= to; id++ {
+			list = append(list, id)
+		}
+		return list
+	}
+	apps := &sprites.Appearances{Outfits: []sprites.Appearance{
+		{ID: 128, FrameGroups: []sprites.FrameGroup{{FixedFrameGroup: sprites.FrameGroupOutfitIdle, SpriteInfo: sprites.SpriteInfo{
+			PatternWidth: 4, PatternHeight: 3, PatternDepth: 2, Layers: 1, SpriteIDs: ids(1, 24),
+		}}}},
+		{ID: 368, FrameGroups: []sprites.FrameGroup{{FixedFrameGroup: sprites.FrameGroupOutfitIdle, SpriteInfo: sprites.SpriteInfo{
+			PatternWidth: 4, PatternHeight: 1, PatternDepth: 1, Layers: 1, SpriteIDs: ids(25, 28),
+		}}}},
+	}}
+	opaque := func(img *image.NRGBA) []image.Point {
+		var pts []image.Point
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			for x := 0; x < img.Bounds().Dx(); x++ {
+				if img.NRGBAAt(x, y).A != 0 {
+					pts = append(pts, image.Pt(x, y))
+				}
+			}
+		}
+		return pts
+	}
+
+	// Facing east with addon 2 on the mount: mount sprite 26 at the
+	// top-left of the 64x64 image, then the mounted base (14) and addon 2
+	// (22) anchored at its bottom-right corner.
+	img, err := renderOutfit(openSpriteDir(dir), apps, 128, OutfitOptions{Direction: DirectionEast, Addons: OutfitAddon2, Mount: 368})
+	if err != nil {
+		t.Fatalf("renderOutfit: %v", err)
+	}
+	want := []image.Point{{1, 0}, {45, 45}, {53, 53}}
+	if got := opaque(img); !reflect.DeepEqual(got, want) || img.Bounds().Dx() != 64 {
+		t.Fatalf("mounted outfit pixels = %v in %v, want %v in 64x64", got, img.Bounds(), want)
+	}
+
+	// The strip is the unmounted base of every direction, north first.
+	img, err = renderOutfit(openSpriteDir(dir), apps, 128, OutfitOptions{Strip: true})
+	if err != nil {
+		t.Fatalf("renderOutfit strip: %v", err)
+	}
+	want = []image.Point{{0, 0}, {33, 1}, {66, 2}, {99, 3}}
+	if got := opaque(img); !reflect.DeepEqual(got, want) || img.Bounds() != image.Rect(0, 0, 128, 32) {
+		t.Fatalf("strip pixels = %v in %v, want %v in 128x32", got, img.Bounds(), want)
+	}
+
+	if _, err := renderOutfit(openSpriteDir(dir), apps, 128, OutfitOptions{Addons: 4}); err == nil {
+		t.Fatalf("addons 4 accepted")
+	}
+	if _, err := renderOutfit(openSpriteDir(dir), apps, 128, OutfitOptions{Mount: 1}); !errors.Is(err, ErrOutfitNotFound) || !strings.HasPrefix(err.Error(), "mount: ") {
+		t.Fatalf("unknown mount error = %v", err)
+	}
+}
+
+func TestOutfitOptionsFileName(t *testing.T) {
+	opts := OutfitOptions{Colors: OutfitColors{Head: 78, Body: 69, Legs: 58, Feet: 76}, Direction: DirectionWest}
+	if got := opts.fileName(128); got != "128_78-69-58-76_west" {
+		t.Fatalf("fileName = %q", got)
+	}
+	opts.Addons, opts.Mount, opts.Strip = OutfitAddons, 368, true
+	if got := opts.fileName(128); got != "128_78-69-58-76_addons3_mount368_strip" {
+		t.Fatalf("fileName = %q", got)
+	}
+}
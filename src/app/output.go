@@ -0,0 +1,234 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// ImageEncoder writes images in one file format.
+type ImageEncoder interface {
+	Encode(w io.Writer, img image.Image) error
+	// Extension is the file extension of the format, including the dot.
+	Extension() string
+}
+
+// PNGEncoder writes PNG files.
+type PNGEncoder struct {
+	CompressionLevel png.CompressionLevel
+}
+
+func (e PNGEncoder) Encode(w io.Writer, img image.Image) error {
+	enc := png.Encoder{CompressionLevel: e.CompressionLevel}
+	return enc.Encode(w, img)
+}
+
+func (PNGEncoder) Extension() string { return ".png" }
+
+// BMPEncoder writes uncompressed BMP files.
+type BMPEncoder struct{}
+
+func (BMPEncoder) Encode(w io.Writer, img image.Image) error { return bmp.Encode(w, img) }
+
+func (BMPEncoder) Extension() string { return ".bmp" }
+
+// TIFFEncoder writes TIFF files, deflate compressed unless Uncompressed.
+type TIFFEncoder struct {
+	Uncompressed bool
+}
+
+func (e TIFFEncoder) Encode(w io.Writer, img image.Image) error {
+	opts := &tiff.Options{Compression: tiff.Deflate}
+	if e.Uncompressed {
+		opts.Compression = tiff.Uncompressed
+	}
+	return tiff.Encode(w, img, opts)
+}
+
+func (TIFFEncoder) Extension() string { return ".tiff" }
+
+// imageExtensions are the extensions of every format an ImageEncoder writes.
+// Readers of earlier outputs accept all of them.
+var imageExtensions = []string{".png", ".bmp", ".tiff"}
+
+// imageExtPattern matches any of imageExtensions in a regular expression.
+const imageExtPattern = `\.(?:png|bmp|tiff)`
+
+// ImageFormats lists the names accepted by NewImageEncoder.
+var ImageFormats = []string{"png", "bmp", "tiff"}
+
+// PNGCompressionLevels maps the names accepted by NewImageEncoder to levels.
+var PNGCompressionLevels = map[string]png.CompressionLevel{
+	"default": png.DefaultCompression,
+	"none":    png.NoCompression,
+	"fast":    png.BestSpeed,
+	"best":    png.BestCompression,
+}
+
+// NewImageEncoder returns the encoder for a format name. pngCompression is
+// only used for PNG; empty means "default".
+func NewImageEncoder(format, pngCompression string) (ImageEncoder, error) {
+	switch strings.ToLower(format) {
+	case "", "png":
+		if pngCompression == "" {
+			pngCompression = "default"
+		}
+		level, ok := PNGCompressionLevels[strings.ToLower(pngCompression)]
+		if !ok {
+			return nil, fmt.Errorf("unknown PNG compression %q (want default, none, fast or best)", pngCompression)
+		}
+		return PNGEncoder{CompressionLevel: level}, nil
+	case "bmp":
+		return BMPEncoder{}, nil
+	case "tiff", "tif":
+		return TIFFEncoder{}, nil
+	}
+	return nil, fmt.Errorf("unknown image format %q (want %s)", format, strings.Join(ImageFormats, ", "))
+}
+
+// Output controls how the batch entry points write images. The zero value
+// writes PNG files with default compression into the output directory.
+type Output struct {
+	Encoder ImageEncoder
+	// Sink, when set, receives every file instead of the output directory,
+	// named by its path relative to that directory.
+	Sink OutputSink
+	// Dedup, when set, stores images with identical pixels once. Only
+	// SplitSprites and GroupSplitSprites support it.
+	Dedup *Deduper
+	// EmptyTiles, when set, detects fully transparent sprites cut by
+	// SplitSpriteSheet and decides whether they are written. SplitSprites
+	// and the tiles of Export support it.
+	EmptyTiles *EmptyTiles
+	// Scaler, when set, enlarges the sprites cut by SplitSpriteSheet and the
+	// groups composed by GroupSplitSprites before they are encoded. Export
+	// scales its tiles and groups but not its sheets.
+	Scaler *Scaler
+	// GroupMode arranges the sprites of the groups composed by
+	// GroupSplitSprites and Export. The zero value is GroupGrid.
+	GroupMode GroupMode
+	// Layout names the images. Custom layouts are recorded in the output
+	// directory so that later steps find the images. Export does not
+	// support them.
+	Layout Layout
+}
+
+func (o Output) encoder() ImageEncoder {
+	if o.Encoder == nil {
+		return PNGEncoder{}
+	}
+	return o.Encoder
+}
+
+// Ext is the extension of the images written, including the dot.
+func (o Output) Ext() string {
+	return o.encoder().Extension()
+}
+
+// scale enlarges a sprite or group image with the Scaler, if any.
+func (o Output) scale(img image.Image) image.Image {
+	if o.Scaler == nil {
+		return img
+	}
+	return o.Scaler.Scale(img)
+}
+
+// mkdir creates the output directory, unless the files go to a Sink.
+func (o Output) mkdir(dir string) error {
+	if o.Sink != nil {
+		return nil
+	}
+	return os.MkdirAll(dir, 0o755)
+}
+
+// validate rejects combinations the entry points cannot honour.
+func (o Output) validate() error {
+	if o.Dedup != nil && o.Dedup.Mode() == DedupHardlink && o.Sink != nil {
+		return errors.New("hardlink deduplication needs an output directory, not an output sink")
+	}
+	return o.GroupMode.check()
+}
+
+// writeImage encodes img as name inside dir, creating parent directories as
+// needed. name is slash-separated and must already carry the extension
+// returned by Ext.
+func (o Output) writeImage(dir, name string, img image.Image) error {
+	if o.Dedup == nil {
+		_, err := o.storeImage(dir, name, img)
+		return err
+	}
+
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	e, first := o.Dedup.claim(img, name, path)
+	if !first {
+		return o.Dedup.duplicate(e, name, path)
+	}
+	size, err := o.storeImage(dir, name, img)
+	o.Dedup.written(e, size, err)
+	return err
+}
+
+// storeImage encodes img into the Sink or the output directory and returns
+// the encoded size.
+func (o Output) storeImage(dir, name string, img image.Image) (int64, error) {
+	if o.Sink != nil {
+		var buf bytes.Buffer
+		if err := o.encoder().Encode(&buf, img); err != nil {
+			return 0, err
+		}
+		return int64(buf.Len()), o.Sink.WriteFile(name, buf.Bytes())
+	}
+
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	// An earlier hardlink dedup run may have linked path to other files;
+	// truncating it would rewrite them too.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	out := countingWriter{w: f}
+	if err := o.encoder().Encode(&out, img); err != nil {
+		_ = f.Close()
+		return 0, err
+	}
+	return out.n, f.Close()
+}
+
+// finish writes what the run needs once every image is written.
+func (o Output) finish(dir string) error {
+	err := o.Layout.save(dir, o)
+	if o.EmptyTiles != nil {
+		err = errors.Join(err, o.EmptyTiles.finish(dir, o))
+	}
+	if o.Dedup != nil {
+		err = errors.Join(err, o.Dedup.finish(dir, o))
+	}
+	return err
+}
+
+// writeFile stores data as name inside dir, or in the Sink.
+func (o Output) writeFile(dir, name string, data []byte) error {
+	if o.Sink != nil {
+		return o.Sink.WriteFile(name, data)
+	}
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
+
+func TestOutputWritesEveryFormat(t *testing.T) {
+	img := newTestImage(64, 32)
+
+	for _, format := range ImageFormats {
+		enc, err := NewImageEncoder(format, "")
+		if err != nil {
+			t.Fatalf("NewImageEncoder(%q): %v", format, err)
+		}
+		out := Output{Encoder: enc}
+		if out.Ext() != "."+format {
+			t.Fatalf("Ext() = %q, want .%s", out.Ext(), format)
+		}
+
+		dir := t.TempDir()
+		if err := out.writeImage(dir, "nested/sprite"+out.Ext(), img); err != nil {
+			t.Fatalf("writeImage %s: %v", format, err)
+		}
+		got, err := loadImage(filepath.Join(dir, "nested", "sprite"+out.Ext()))
+		if err != nil {
+			t.Fatalf("loadImage %s: %v", format, err)
+		}
+		compareImages(t, got, img)
+	}
+}
+
+func TestOutputZeroValueWritesPNG(t *testing.T) {
+	var out Output
+	if out.Ext() != ".png" {
+		t.Fatalf("Ext() = %q, want .png", out.Ext())
+	}
+	dir := t.TempDir()
+	if err := out.writeImage(dir, "sprite.png", newTestImage(4, 4)); err != nil {
+		t.Fatalf("writeImage: %v", err)
+	}
+	f, err := os.Open(filepath.Join(dir, "sprite.png"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	if _, err := png.Decode(f); err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+}
+
+func TestTIFFKeepsTransparency(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 0xFF, A: 0xFF})
+
+	out := Output{Encoder: TIFFEncoder{}}
+	dir := t.TempDir()
+	if err := out.writeImage(dir, "sprite.tiff", img); err != nil {
+		t.Fatalf("writeImage: %v", err)
+	}
+	got, err := loadImage(filepath.Join(dir, "sprite.tiff"))
+	if err != nil {
+		t.Fatalf("loadImage: %v", err)
+	}
+	if _, _, _, a := got.At(1, 0).RGBA(); a != 0 {
+		t.Fatalf("transparent pixel alpha = %d, want 0", a)
+	}
+}
+
+func TestNewImageEncoder(t *testing.T) {
+	enc, err := NewImageEncoder("PNG", "best")
+	if err != nil {
+		t.Fatalf("NewImageEncoder: %v", err)
+	}
+	if got := enc.(PNGEncoder).CompressionLevel; got != png.BestCompression {
+		t.Fatalf("compression = %v, want BestCompression", got)
+	}
+	if enc, err := NewImageEncoder("", ""); err != nil || enc.Extension() != ".png" {
+		t.Fatalf("NewImageEncoder default = %v, %v, want PNG", enc, err)
+	}
+
+	if _, err := NewImageEncoder("jpeg", ""); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+	if _, err := NewImageEncoder("png", "ultra"); err == nil {
+		t.Fatalf("expected error for unknown PNG compression")
+	}
+	// The compression level only applies to PNG.
+	if _, err := NewImageEncoder("bmp", "ultra"); err != nil {
+		t.Fatalf("NewImageEncoder bmp: %v", err)
+	}
+}
+
+func TestSplitAndGroupReadEarlierFormats(t *testing.T) {
+	extracted := t.TempDir()
+	split := t.TempDir()
+
+	sheet := newTestImage(128, 64)
+	bmpOut := Output{Encoder: BMPEncoder{}}
+	if err := bmpOut.writeImage(extracted, sheetFileName(100, 101, sprites.SpriteType64x64, bmpOut.Ext()), sheet); err != nil {
+		t.Fatalf("writeImage: %v", err)
+	}
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	res, err := SplitSprites(extracted, split, Output{Encoder: TIFFEncoder{}})
+	if err != nil {
+		t.Fatalf("SplitSprites: %v", err)
+	}
+	if res.Processed != 1 {
+		t.Fatalf("result = %+v, want 1 processed", res)
+	}
+	if _, err := os.Stat(filepath.Join(split, "100.tiff")); err != nil {
+		t.Fatalf("expected 100.tiff: %v", err)
+	}
+
+	got, err := openSpriteDir(split).Sprite(101)
+	if err != nil {
+		t.Fatalf("Sprite(101): %v", err)
+	}
+	want := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	draw.Draw(want, want.Bounds(), sheet, image.Pt(64, 0), draw.Src)
+	compareImages(t, got, want)
+
+	if _, err := openSpriteDir(split).Sprite(102); !os.IsNotExist(err) {
+		t.Fatalf("Sprite(102) error = %v, want not exist", err)
+	}
+}
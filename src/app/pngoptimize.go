@@ -0,0 +1,177 @@
+package app
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"sort"
+	"sync/atomic"
+)
+
+// OptimizedPNGEncoder writes the smallest lossless PNG it can cheaply find.
+// Images with at most 256 distinct colors, transparency included, are
+// written as indexed PNGs with a tRNS chunk; other images as RGBA with the
+// best compression.
+//
+// Use it through a pointer; it is safe for concurrent use.
+type OptimizedPNGEncoder struct {
+	// Baseline, when set, is the encoder the output is measured against.
+	// Every image is also encoded with it, the smaller of the two is
+	// written, and Stats reports the bytes saved. Without it, images are
+	// encoded once and OriginalBytes stays zero.
+	Baseline *PNGEncoder
+
+	images, paletted atomic.Int64
+	original, output atomic.Int64
+}
+
+// OptimizeStats summarizes what an OptimizedPNGEncoder wrote.
+type OptimizeStats struct {
+	// Images is the number of images written, Paletted how many of them
+	// as indexed PNGs.
+	Images, Paletted int
+	// OriginalBytes is what the Baseline encoder would have written;
+	// WrittenBytes is what was written instead.
+	OriginalBytes, WrittenBytes int64
+}
+
+// Saved is the number of bytes the optimization saved, or 0 when it was not
+// measured against a baseline.
+func (s OptimizeStats) Saved() int64 {
+	if s.OriginalBytes == 0 {
+		return 0
+	}
+	return s.OriginalBytes - s.WrittenBytes
+}
+
+func (e *OptimizedPNGEncoder) Encode(w io.Writer, img image.Image) error {
+	var baseline []byte
+	if e.Baseline != nil {
+		var buf bytes.Buffer
+		if err := e.Baseline.Encode(&buf, img); err != nil {
+			return err
+		}
+		baseline = buf.Bytes()
+	}
+
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	var best []byte
+	paletted := false
+	if p := palettedCopy(img); p != nil {
+		var buf bytes.Buffer
+		if err := enc.Encode(&buf, p); err != nil {
+			return err
+		}
+		best, paletted = buf.Bytes(), true
+	} else if baseline == nil || e.Baseline.CompressionLevel != png.BestCompression {
+		var buf bytes.Buffer
+		if err := enc.Encode(&buf, img); err != nil {
+			return err
+		}
+		best = buf.Bytes()
+	}
+	// Indexed PNGs are written without filters, so smooth gradients can
+	// still compress better as RGBA.
+	if baseline != nil && (best == nil || len(baseline) < len(best)) {
+		best, paletted = baseline, false
+	}
+	if _, err := w.Write(best); err != nil {
+		return err
+	}
+
+	e.images.Add(1)
+	if paletted {
+		e.paletted.Add(1)
+	}
+	e.original.Add(int64(len(baseline)))
+	e.output.Add(int64(len(best)))
+	return nil
+}
+
+func (*OptimizedPNGEncoder) Extension() string { return ".png" }
+
+// Stats returns what the encoder wrote so far.
+func (e *OptimizedPNGEncoder) Stats() OptimizeStats {
+	return OptimizeStats{
+		Images:        int(e.images.Load()),
+		Paletted:      int(e.paletted.Load()),
+		OriginalBytes: e.original.Load(),
+		WrittenBytes:  e.output.Load(),
+	}
+}
+
+// palettedCopy returns img as a paletted image holding exactly the same
+// pixels, or nil when it has more than 256 colors. Colors are compared as
+// PNG stores them, non-premultiplied; every fully transparent pixel shares
+// one entry. Translucent colors come first, so the tRNS chunk stays short.
+func palettedCopy(img image.Image) *image.Paletted {
+	b := img.Bounds()
+	index := make(map[color.NRGBA]int)
+	pixels := make([]color.NRGBA, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				c = color.NRGBA{}
+			}
+			if _, ok := index[c]; !ok {
+				if len(index) == 256 {
+					return nil
+				}
+				index[c] = 0
+			}
+			pixels = append(pixels, c)
+		}
+	}
+
+	pal := make([]color.NRGBA, 0, len(index))
+	for c := range index {
+		pal = append(pal, c)
+	}
+	sort.Slice(pal, func(i, j int) bool {
+		a, b := pal[i], pal[j]
+		if a.A != b.A {
+			return a.A < b.A
+		}
+		if a.R != b.R {
+			return a.R < b.R
+		}
+		if a.G != b.G {
+			return a.G < b.G
+		}
+		return a.B < b.B
+	})
+	p := make(color.Palette, len(pal))
+	for i, c := range pal {
+		index[c] = i
+		p[i] = c
+	}
+
+	out := image.NewPaletted(b, p)
+	i := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.SetColorIndex(x, y, uint8(index[pixels[i]]))
+			i++
+		}
+	}
+	return out
+}
+
+// countingWriter counts the bytes written through it. A nil w discards them.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	var err error
+	if c.w != nil {
+		n, err = c.w.Write(p)
+	}
+	c.n += int64(n)
+	return n, err
+}
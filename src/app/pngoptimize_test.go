@@ -0,0 +1,127 @@
+package app
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestOptimizedPNGEncoderWritesPalettedWhenLossless(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	seed := uint32(1)
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			switch {
+			case x < 16:
+				// fully transparent
+			case x < 32:
+				img.SetNRGBA(x, y, color.NRGBA{R: 0x80, A: 0x80})
+			default:
+				// 64 scattered shades, like a dithered sprite
+				seed = seed*1103515245 + 12345
+				img.SetNRGBA(x, y, color.NRGBA{G: uint8(seed>>16) & 0xFC, B: 0xFF, A: 0xFF})
+			}
+		}
+	}
+
+	enc := &OptimizedPNGEncoder{Baseline: &PNGEncoder{}}
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, img); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	p, ok := decoded.(*image.Paletted)
+	if !ok {
+		t.Fatalf("decoded %T, want *image.Paletted", decoded)
+	}
+	if len(p.Palette) != 66 {
+		t.Fatalf("palette has %d colors, want 66", len(p.Palette))
+	}
+	if _, _, _, a := p.Palette[0].RGBA(); a != 0 {
+		t.Fatalf("first palette entry alpha = %d, want the transparent color first", a)
+	}
+	compareImages(t, decoded, img)
+
+	stats := enc.Stats()
+	if stats.Images != 1 || stats.Paletted != 1 {
+		t.Fatalf("stats = %+v, want 1 paletted image", stats)
+	}
+	if stats.WrittenBytes != int64(buf.Len()) {
+		t.Fatalf("WrittenBytes = %d, want %d", stats.WrittenBytes, buf.Len())
+	}
+	if stats.Saved() <= 0 {
+		t.Fatalf("Saved() = %d, want a positive saving", stats.Saved())
+	}
+}
+
+func TestOptimizedPNGEncoderFallsBackToRGBA(t *testing.T) {
+	img := newTestImage(64, 32)
+
+	enc := &OptimizedPNGEncoder{}
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, img); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if _, ok := decoded.(*image.Paletted); ok {
+		t.Fatalf("image with %d colors decoded as paletted", 64*32)
+	}
+	compareImages(t, decoded, img)
+
+	if stats := enc.Stats(); stats.Images != 1 || stats.Paletted != 0 {
+		t.Fatalf("stats = %+v, want 1 RGBA image", stats)
+	}
+}
+
+func TestOptimizedPNGEncoderNeverExceedsBaseline(t *testing.T) {
+	img := newTestImage(64, 32)
+	baseline := PNGEncoder{CompressionLevel: png.BestCompression}
+	var want bytes.Buffer
+	if err := baseline.Encode(&want, img); err != nil {
+		t.Fatalf("baseline Encode: %v", err)
+	}
+
+	enc := &OptimizedPNGEncoder{Baseline: &baseline}
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, img); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+		t.Fatalf("wrote %d bytes, want the %d-byte baseline", buf.Len(), want.Len())
+	}
+	if stats := enc.Stats(); stats.OriginalBytes != int64(want.Len()) || stats.Saved() != 0 {
+		t.Fatalf("stats = %+v, want no saving over the baseline", stats)
+	}
+
+	// Without a baseline nothing is measured.
+	enc = &OptimizedPNGEncoder{}
+	if err := enc.Encode(&bytes.Buffer{}, img); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if stats := enc.Stats(); stats.OriginalBytes != 0 || stats.Saved() != 0 || stats.WrittenBytes == 0 {
+		t.Fatalf("stats = %+v, want only written bytes", stats)
+	}
+}
+
+func TestPalettedCopyLimit(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 2))
+	for x := 0; x < 256; x++ {
+		img.SetNRGBA(x, 0, color.NRGBA{R: uint8(x), A: 0xFF})
+		img.SetNRGBA(x, 1, color.NRGBA{R: uint8(x), A: 0xFF})
+	}
+	if palettedCopy(img) == nil {
+		t.Fatalf("256 colors should fit a palette")
+	}
+	img.SetNRGBA(0, 1, color.NRGBA{G: 1, A: 0xFF})
+	if palettedCopy(img) != nil {
+		t.Fatalf("257 colors should not fit a palette")
+	}
+}
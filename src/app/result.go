@@ -0,0 +1,75 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Result summarises a batch run over sheets, sprites or groups.
+type Result struct {
+	Processed int
+	Skipped   int
+	Failed    int
+	Missing   int
+	Errors    []ItemError
+}
+
+// ItemError ties a failure to the file or group it happened on.
+type ItemError struct {
+	Item string
+	Err  error
+	// Missing marks an item counted in Result.Missing rather than
+	// Result.Failed.
+	Missing bool
+}
+
+func (e ItemError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Item, e.Err)
+}
+
+func (e ItemError) Unwrap() error {
+	return e.Err
+}
+
+func (r *Result) fail(item string, err error) {
+	r.Failed++
+	r.Errors = append(r.Errors, ItemError{Item: item, Err: err})
+}
+
+// miss records an item whose input does not exist. Missing items are not
+// failures: they usually mean an earlier step was not run.
+func (r *Result) miss(item string, err error) {
+	r.Missing++
+	r.Errors = append(r.Errors, ItemError{Item: item, Err: err, Missing: true})
+}
+
+// FirstFailure is the first error of a failed item, skipping missing ones.
+func (r Result) FirstFailure() (ItemError, bool) {
+	for _, e := range r.Errors {
+		if !e.Missing {
+			return e, true
+		}
+	}
+	return ItemError{}, false
+}
+
+// add merges the counts and errors of o into r.
+func (r *Result) add(o Result) {
+	r.Processed += o.Processed
+	r.Skipped += o.Skipped
+	r.Failed += o.Failed
+	r.Missing += o.Missing
+	r.Errors = append(r.Errors, o.Errors...)
+}
+
+// syncResult lets workers update a shared Result.
+type syncResult struct {
+	mu  sync.Mutex
+	res Result
+}
+
+func (s *syncResult) update(fn func(r *Result)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.res)
+}
@@ -0,0 +1,301 @@
+package app
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"strings"
+)
+
+// ScaleFilters lists the filters accepted by NewScaler:
+//
+//	nearest  repeats every pixel; any factor
+//	epx      EPX/Scale2x and Scale3x; factors made of 2s and 3s
+//	xbr      xBR-style edge smoothing; powers of two
+var ScaleFilters = []string{"nearest", "epx", "xbr"}
+
+// Scaler enlarges pixel art by an integer factor. Fully transparent pixels
+// never lend their color to visible ones, so alpha edges stay clean.
+type Scaler struct {
+	filter string
+	factor int
+	// steps are the factors of the passes that make up factor.
+	steps []int
+}
+
+// NewScaler returns a Scaler for one of ScaleFilters. A factor of 1 returns
+// images unchanged.
+func NewScaler(filter string, factor int) (*Scaler, error) {
+	filter = strings.ToLower(filter)
+	if factor < 1 {
+		return nil, fmt.Errorf("scale factor %d is not positive", factor)
+	}
+	s := &Scaler{filter: filter, factor: factor}
+	switch filter {
+	case "nearest":
+		if factor > 1 {
+			s.steps = []int{factor}
+		}
+	case "epx":
+		s.steps = factorSteps(factor, 3, 2)
+	case "xbr":
+		s.steps = factorSteps(factor, 2)
+	default:
+		return nil, fmt.Errorf("unknown scale filter %q (want %s)", filter, strings.Join(ScaleFilters, ", "))
+	}
+	if s.steps == nil && factor > 1 {
+		return nil, fmt.Errorf("%s cannot scale by %d", filter, factor)
+	}
+	return s, nil
+}
+
+// factorSteps splits factor into a product of steps, largest first. It
+// returns nil when factor is not such a product.
+func factorSteps(factor int, steps ...int) []int {
+	var out []int
+	for _, step := range steps {
+		for factor%step == 0 {
+			out = append(out, step)
+			factor /= step
+		}
+	}
+	if factor != 1 {
+		return nil
+	}
+	return out
+}
+
+// Factor is the scale factor.
+func (s *Scaler) Factor() int { return s.factor }
+
+// Filter is the name of the filter.
+func (s *Scaler) Filter() string { return s.filter }
+
+// Scale returns img enlarged by the factor.
+func (s *Scaler) Scale(img image.Image) image.Image {
+	if len(s.steps) == 0 {
+		return img
+	}
+	src := toNRGBA(img)
+	for _, step := range s.steps {
+		switch {
+		case s.filter == "nearest":
+			src = scaleNearest(src, step)
+		case s.filter == "epx" && step == 2:
+			src = scale2x(src)
+		case s.filter == "epx":
+			src = scale3x(src)
+		default:
+			src = scaleXBR2x(src)
+		}
+	}
+	return src
+}
+
+// toNRGBA copies img into a zero-based NRGBA image, with the color of fully
+// transparent pixels cleared so that they all compare equal.
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	for i := 0; i < len(dst.Pix); i += 4 {
+		if dst.Pix[i+3] == 0 {
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2] = 0, 0, 0
+		}
+	}
+	return dst
+}
+
+// nrgbaGrid reads pixels of an NRGBA image as packed uint32 values,
+// clamping coordinates to the image.
+type nrgbaGrid struct {
+	img  *image.NRGBA
+	w, h int
+}
+
+func newGrid(img *image.NRGBA) nrgbaGrid {
+	b := img.Bounds()
+	return nrgbaGrid{img: img, w: b.Dx(), h: b.Dy()}
+}
+
+func (g nrgbaGrid) at(x, y int) uint32 {
+	x = min(max(x, 0), g.w-1)
+	y = min(max(y, 0), g.h-1)
+	i := g.img.PixOffset(x, y)
+	p := g.img.Pix[i : i+4 : i+4]
+	return uint32(p[0])<<24 | uint32(p[1])<<16 | uint32(p[2])<<8 | uint32(p[3])
+}
+
+func setPixel(dst *image.NRGBA, x, y int, c uint32) {
+	i := dst.PixOffset(x, y)
+	dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(c>>24), uint8(c>>16), uint8(c>>8), uint8(c)
+}
+
+func scaleNearest(src *image.NRGBA, n int) *image.NRGBA {
+	g := newGrid(src)
+	dst := image.NewNRGBA(image.Rect(0, 0, g.w*n, g.h*n))
+	for y := 0; y < g.h*n; y++ {
+		for x := 0; x < g.w*n; x++ {
+			setPixel(dst, x, y, g.at(x/n, y/n))
+		}
+	}
+	return dst
+}
+
+// scale2x is EPX, also known as Scale2x.
+func scale2x(src *image.NRGBA) *image.NRGBA {
+	g := newGrid(src)
+	dst := image.NewNRGBA(image.Rect(0, 0, g.w*2, g.h*2))
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			p := g.at(x, y)
+			a, b, c, d := g.at(x, y-1), g.at(x+1, y), g.at(x-1, y), g.at(x, y+1)
+			e0, e1, e2, e3 := p, p, p, p
+			if c == a && c != d && a != b {
+				e0 = a
+			}
+			if a == b && a != c && b != d {
+				e1 = b
+			}
+			if d == c && d != b && c != a {
+				e2 = c
+			}
+			if b == d && b != a && d != c {
+				e3 = d
+			}
+			setPixel(dst, 2*x, 2*y, e0)
+			setPixel(dst, 2*x+1, 2*y, e1)
+			setPixel(dst, 2*x, 2*y+1, e2)
+			setPixel(dst, 2*x+1, 2*y+1, e3)
+		}
+	}
+	return dst
+}
+
+// scale3x is the Scale3x extension of EPX.
+func scale3x(src *image.NRGBA) *image.NRGBA {
+	g := newGrid(src)
+	dst := image.NewNRGBA(image.Rect(0, 0, g.w*3, g.h*3))
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			a, b, c := g.at(x-1, y-1), g.at(x, y-1), g.at(x+1, y-1)
+			d, e, f := g.at(x-1, y), g.at(x, y), g.at(x+1, y)
+			gg, h, i := g.at(x-1, y+1), g.at(x, y+1), g.at(x+1, y+1)
+			out := [9]uint32{e, e, e, e, e, e, e, e, e}
+			if b != h && d != f {
+				if d == b {
+					out[0] = d
+				}
+				if (d == b && e != c) || (b == f && e != a) {
+					out[1] = b
+				}
+				if b == f {
+					out[2] = f
+				}
+				if (d == b && e != gg) || (d == h && e != a) {
+					out[3] = d
+				}
+				if (b == f && e != i) || (h == f && e != c) {
+					out[5] = f
+				}
+				if d == h {
+					out[6] = d
+				}
+				if (d == h && e != i) || (h == f && e != gg) {
+					out[7] = h
+				}
+				if h == f {
+					out[8] = f
+				}
+			}
+			for k, c := range out {
+				setPixel(dst, 3*x+k%3, 3*y+k/3, c)
+			}
+		}
+	}
+	return dst
+}
+
+// scaleXBR2x doubles an image with the first level of the xBR algorithm:
+// every output pixel looks for an edge across its corner of the source
+// pixel and, when it finds one, blends in the color on the other side.
+func scaleXBR2x(src *image.NRGBA) *image.NRGBA {
+	g := newGrid(src)
+	dst := image.NewNRGBA(image.Rect(0, 0, g.w*2, g.h*2))
+	// Corners in the order bottom-right, bottom-left, top-left, top-right;
+	// each is the previous one rotated by 90 degrees.
+	corners := [4]struct{ ox, oy int }{{1, 1}, {0, 1}, {0, 0}, {1, 0}}
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			e := g.at(x, y)
+			for r, corner := range corners {
+				// px reads the neighbour at (dx, dy) as seen from the
+				// bottom-right corner, rotated towards this corner.
+				px := func(dx, dy int) uint32 {
+					for k := 0; k < r; k++ {
+						dx, dy = -dy, dx
+					}
+					return g.at(x+dx, y+dy)
+				}
+				out := e
+				b, c, d, f := px(0, -1), px(1, -1), px(-1, 0), px(1, 0)
+				gg, h, i := px(-1, 1), px(0, 1), px(1, 1)
+				f4, i4, h5, i5 := px(2, 0), px(2, 1), px(0, 2), px(1, 2)
+				across := colorDist(e, c) + colorDist(e, gg) + colorDist(i, f4) + colorDist(i, h5) + 4*colorDist(h, f)
+				along := colorDist(h, d) + colorDist(h, i5) + colorDist(f, i4) + colorDist(f, b) + 4*colorDist(e, i)
+				if across < along {
+					n := h
+					if colorDist(e, f) <= colorDist(e, h) {
+						n = f
+					}
+					out = blendPremultiplied(e, n)
+				}
+				setPixel(dst, 2*x+corner.ox, 2*y+corner.oy, out)
+			}
+		}
+	}
+	return dst
+}
+
+// colorDist compares two packed NRGBA pixels by their premultiplied YUV
+// components and alpha, the way xBR weighs them.
+func colorDist(a, b uint32) int {
+	ya, ua, va, aa := yuva(a)
+	yb, ub, vb, ab := yuva(b)
+	return 48*absInt(ya-yb) + 7*absInt(ua-ub) + 6*absInt(va-vb) + 48*absInt(aa-ab)
+}
+
+func yuva(c uint32) (y, u, v, a int) {
+	a = int(c & 0xFF)
+	r := int(c>>24) * a / 0xFF
+	g := int(c>>16&0xFF) * a / 0xFF
+	b := int(c>>8&0xFF) * a / 0xFF
+	y = (299*r + 587*g + 114*b) / 1000
+	u = (-169*r - 331*g + 500*b) / 1000
+	v = (500*r - 419*g - 81*b) / 1000
+	return y, u, v, a
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// blendPremultiplied mixes two packed NRGBA pixels half and half, weighing
+// their colors by alpha so transparent pixels add no color.
+func blendPremultiplied(p, q uint32) uint32 {
+	pa, qa := int(p&0xFF), int(q&0xFF)
+	a := (pa + qa + 1) / 2
+	if pa+qa == 0 {
+		return 0
+	}
+	var out uint32
+	for shift := 24; shift >= 8; shift -= 8 {
+		pc, qc := int(p>>shift&0xFF), int(q>>shift&0xFF)
+		c := (pc*pa + qc*qa + (pa+qa)/2) / (pa + qa)
+		out |= uint32(c) << shift
+	}
+	return out | uint32(a)
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	red  = color.NRGBA{R: 0xFF, A: 0xFF}
+	blue = color.NRGBA{B: 0xFF, A: 0xFF}
+)
+
+// cornerImage is a blue 3x3 image whose pixels above and left of the
+// center are red, the pattern EPX rounds off.
+func cornerImage() *image.NRGBA {
+	img := solidImage(3, 3, blue)
+	img.SetNRGBA(1, 0, red)
+	img.SetNRGBA(0, 1, red)
+	return img
+}
+
+func mustScaler(t *testing.T, filter string, factor int) *Scaler {
+	t.Helper()
+	s, err := NewScaler(filter, factor)
+	if err != nil {
+		t.Fatalf("NewScaler(%s, %d): %v", filter, factor, err)
+	}
+	return s
+}
+
+func TestNewScalerValidatesFactors(t *testing.T) {
+	for _, tt := range []struct {
+		filter string
+		factor int
+		ok     bool
+	}{
+		{"nearest", 5, true},
+		{"epx", 6, true},
+		{"EPX", 4, true},
+		{"xbr", 8, true},
+		{"xbr", 1, true},
+		{"epx", 5, false},
+		{"xbr", 3, false},
+		{"nearest", 0, false},
+		{"bicubic", 2, false},
+	} {
+		_, err := NewScaler(tt.filter, tt.factor)
+		if (err == nil) != tt.ok {
+			t.Fatalf("NewScaler(%s, %d) error = %v, want ok %v", tt.filter, tt.factor, err, tt.ok)
+		}
+	}
+}
+
+func TestScalerKeepsSolidImages(t *testing.T) {
+	src := solidImage(4, 3, red)
+	for _, s := range []*Scaler{mustScaler(t, "nearest", 3), mustScaler(t, "epx", 6), mustScaler(t, "xbr", 4)} {
+		got := s.Scale(src)
+		want := solidImage(4*s.Factor(), 3*s.Factor(), red)
+		compareImages(t, got, want)
+	}
+}
+
+func TestScaleEPX(t *testing.T) {
+	got := mustScaler(t, "epx", 2).Scale(cornerImage()).(*image.NRGBA)
+	if got.NRGBAAt(2, 2) != red || got.NRGBAAt(3, 3) != blue || got.NRGBAAt(3, 2) != blue {
+		t.Fatalf("Scale2x did not round off the top-left corner of the center pixel")
+	}
+
+	got = mustScaler(t, "epx", 3).Scale(cornerImage()).(*image.NRGBA)
+	if got.NRGBAAt(3, 3) != red || got.NRGBAAt(4, 3) != blue || got.NRGBAAt(4, 4) != blue {
+		t.Fatalf("Scale3x did not round off the top-left corner of the center pixel")
+	}
+}
+
+func TestScaleXBRSmoothsDiagonals(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 6, 6))
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 6; x++ {
+			if x+y < 6 {
+				src.SetNRGBA(x, y, red)
+			} else {
+				src.SetNRGBA(x, y, blue)
+			}
+		}
+	}
+	got := mustScaler(t, "xbr", 2).Scale(src).(*image.NRGBA)
+	blended := false
+	for y := 0; y < 12; y++ {
+		for x := 0; x < 12; x++ {
+			if c := got.NRGBAAt(x, y); c.R != 0 && c.B != 0 {
+				blended = true
+			}
+		}
+	}
+	if !blended {
+		t.Fatalf("xbr left the staircase unsmoothed")
+	}
+}
+
+func TestScalersPreserveAlphaEdges(t *testing.T) {
+	// A red diamond on a transparent background whose invisible pixels
+	// hold green; no filter may let the green show.
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if absInt(x-4)+absInt(y-4) < 3 {
+				src.SetNRGBA(x, y, red)
+			} else {
+				src.SetNRGBA(x, y, color.NRGBA{G: 0xFF})
+			}
+		}
+	}
+	for _, filter := range ScaleFilters {
+		got := mustScaler(t, filter, 2).Scale(src).(*image.NRGBA)
+		for y := 0; y < 16; y++ {
+			for x := 0; x < 16; x++ {
+				c := got.NRGBAAt(x, y)
+				if c.A != 0 && (c.G != 0 || c.R != 0xFF) {
+					t.Fatalf("%s: pixel (%d,%d) = %#v bleeds the transparent color", filter, x, y, c)
+				}
+			}
+		}
+		if got.NRGBAAt(0, 0).A != 0 || got.NRGBAAt(8, 8).A != 0xFF {
+			t.Fatalf("%s: transparent or opaque areas changed", filter)
+		}
+	}
+}
+
+func TestSplitSpritesScales(t *testing.T) {
+	extracted := t.TempDir()
+	split := t.TempDir()
+	writeTestPNG(t, filepath.Join(extracted, "Sprites-1-1-32x32.png"), newTestImage(32, 32))
+	_, restore := captureLogs(t)
+	defer restore()
+
+	if _, err := SplitSprites(extracted, split, Output{Scaler: mustScaler(t, "nearest", 2)}); err != nil {
+		t.Fatalf("SplitSprites: %v", err)
+	}
+	got := decodePNG(t, filepath.Join(split, "1.png"))
+	compareImages(t, got, scaleNearest(toNRGBA(newTestImage(32, 32)), 2))
+}
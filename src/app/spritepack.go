@@ -0,0 +1,166 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	bar "github.com/schollz/progressbar/v3"
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
+
+// WriteSpritePack packs the sprites written by split into one sprite pack at
+// packPath, in the format read by sprites.SpritePack. PNG sprites are stored as they are; sprites split to other
+// formats are re-encoded as PNG. Sprites that a json dedup run of split
+// skipped share the blob of the file holding their pixels. ids selects the
+// sprites; empty packs all.
+// Per-sprite failures are collected in the Result; the error is set when
+// there is nothing to pack or the pack file cannot be written.
+func WriteSpritePack(splitDir, packPath string, ids IDRanges) (Result, error) {
+	var res Result
+
+	files, err := splitSpriteFiles(splitDir)
+	if err != nil {
+		log.Err(err).
+			Str("splitDir", splitDir).
+			Msg("Failed to read directory. Did you run the split command?")
+		return res, fmt.Errorf("read %s: %w", splitDir, err)
+	}
+	for id := range files {
+		if !ids.Contains(id) {
+			delete(files, id)
+		}
+	}
+	for id, first := range duplicateSprites(splitDir) {
+		if _, ok := files[id]; !ok && ids.Contains(id) {
+			files[id] = first
+		}
+	}
+	if len(files) == 0 {
+		log.Warn().
+			Str("splitDir", splitDir).
+			Msg("No sprites found to pack. Did you run the split command?")
+		return res, fmt.Errorf("%w in %s", ErrNoSplitSprites, splitDir)
+	}
+	order := make([]int, 0, len(files))
+	for id := range files {
+		order = append(order, id)
+	}
+	sort.Ints(order)
+	first, last := order[0], order[len(order)-1]
+	slots := last - first + 1
+
+	if err := os.MkdirAll(filepath.Dir(packPath), 0o755); err != nil {
+		return res, fmt.Errorf("create %s: %w", filepath.Dir(packPath), err)
+	}
+	f, err := os.Create(packPath)
+	if err != nil {
+		return res, err
+	}
+	// The index is filled in while the blobs stream out behind it.
+	index := make([]byte, slots*sprites.SpritePackSlotLen)
+	offset := int64(sprites.SpritePackHeaderLen + len(index))
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return res, err
+	}
+	w := bufio.NewWriter(f)
+
+	progress := bar.NewOptions(
+		len(order),
+		bar.OptionSetDescription("Packing sprites"),
+		bar.OptionShowCount(),
+		bar.OptionShowIts(),
+		bar.OptionSetItsString("sprites"),
+		bar.OptionThrottle(100),
+		bar.OptionClearOnFinish(),
+	)
+	var writeErr error
+	// Sprites stored in the same file, as json dedup leaves them, share
+	// one blob.
+	blobs := make(map[string][]byte)
+	for _, id := range order {
+		_ = progress.Add(1)
+		name := files[id]
+		slot := index[(id-first)*sprites.SpritePackSlotLen:][:sprites.SpritePackSlotLen]
+		if blob, ok := blobs[name]; ok {
+			copy(slot, blob)
+			res.Processed++
+			continue
+		}
+		data, err := spritePNGData(filepath.Join(splitDir, filepath.FromSlash(name)))
+		if err != nil {
+			log.Error().Str("file", name).Err(err).Msg("failed to read sprite")
+			res.fail(name, err)
+			continue
+		}
+		if _, writeErr = w.Write(data); writeErr != nil {
+			break
+		}
+		binary.LittleEndian.PutUint64(slot[0:], uint64(offset))
+		binary.LittleEndian.PutUint32(slot[8:], uint32(len(data)))
+		blobs[name] = slot
+		offset += int64(len(data))
+		res.Processed++
+	}
+	_ = progress.Finish()
+
+	if writeErr == nil {
+		writeErr = w.Flush()
+	}
+	if writeErr == nil {
+		var h [sprites.SpritePackHeaderLen]byte
+		copy(h[:], sprites.SpritePackMagic)
+		binary.LittleEndian.PutUint32(h[4:], sprites.SpritePackVersion)
+		binary.LittleEndian.PutUint32(h[8:], uint32(first))
+		binary.LittleEndian.PutUint32(h[12:], uint32(slots))
+		_, writeErr = f.WriteAt(append(h[:], index...), 0)
+	}
+	if err := f.Close(); writeErr == nil {
+		writeErr = err
+	}
+	if writeErr != nil {
+		_ = os.Remove(packPath)
+		log.Err(writeErr).Str("pack", packPath).Msg("failed to write sprite pack")
+		return res, fmt.Errorf("write %s: %w", packPath, writeErr)
+	}
+
+	log.Info().
+		Int("packed", res.Processed).
+		Int("failed", res.Failed).
+		Int("firstID", first).
+		Int("lastID", last).
+		Int64("bytes", offset).
+		Str("pack", packPath).
+		Msg("Packing sprites finished")
+
+	return res, nil
+}
+
+// spritePNGData returns the PNG bytes of a split sprite, re-encoding sprites
+// split to other formats.
+func spritePNGData(path string) ([]byte, error) {
+	if filepath.Ext(path) == ".png" {
+		data, err := os.ReadFile(path)
+		if err == nil && !bytes.HasPrefix(data, pngSignature) {
+			err = fmt.Errorf("%s is not a PNG file", path)
+		}
+		return data, err
+	}
+	img, err := loadImage(path)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
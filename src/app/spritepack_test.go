@@ -0,0 +1,144 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
+
+// countingReaderAt records how many bytes are read from a sprite pack.
+type countingReaderAt struct {
+	r     *bytes.Reader
+	reads int
+	bytes int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.reads++
+	n, err := c.r.ReadAt(p, off)
+	c.bytes += n
+	return n, err
+}
+
+func TestWriteSpritePackRoundTrip(t *testing.T) {
+	splitDir := t.TempDir()
+	red := solidImage(32, 32, color.NRGBA{R: 0xFF, A: 0xFF})
+	blue := solidImage(64, 32, color.NRGBA{B: 0xFF, A: 0xFF})
+	writeTestPNG(t, filepath.Join(splitDir, "10.png"), red)
+	if err := (Output{Encoder: BMPEncoder{}}).writeImage(splitDir, "13.bmp", blue); err != nil {
+		t.Fatalf("writeImage: %v", err)
+	}
+	writeTestPNG(t, filepath.Join(splitDir, "99.png"), red)
+	if err := os.WriteFile(filepath.Join(splitDir, "notes.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	packPath := filepath.Join(t.TempDir(), "sprites.pack")
+	ids, _ := ParseIDRanges("1-50")
+	res, err := WriteSpritePack(splitDir, packPath, ids)
+	if err != nil {
+		t.Fatalf("WriteSpritePack: %v", err)
+	}
+	if res.Processed != 2 || res.Failed != 0 {
+		t.Fatalf("result = %+v, want 2 processed", res)
+	}
+
+	pack, err := sprites.OpenSpritePack(packPath)
+	if err != nil {
+		t.Fatalf("OpenSpritePack: %v", err)
+	}
+	defer pack.Close()
+	if first, last := pack.IDRange(); first != 10 || last != 13 {
+		t.Fatalf("IDRange = %d-%d, want 10-13", first, last)
+	}
+
+	got, err := pack.Sprite(10)
+	if err != nil {
+		t.Fatalf("Sprite(10): %v", err)
+	}
+	compareImages(t, got, red)
+	got, err = pack.Sprite(13)
+	if err != nil {
+		t.Fatalf("Sprite(13): %v", err)
+	}
+	compareImages(t, got, blue)
+
+	// PNG sprites are stored byte for byte.
+	want, _ := os.ReadFile(filepath.Join(splitDir, "10.png"))
+	if data, err := pack.SpriteData(10); err != nil || !bytes.Equal(data, want) {
+		t.Fatalf("SpriteData(10) differs from 10.png (err %v)", err)
+	}
+
+	for _, id := range []int{9, 11, 14, 99} {
+		if _, err := pack.Sprite(id); !errors.Is(err, sprites.ErrSpriteNotFound) {
+			t.Fatalf("Sprite(%d) error = %v, want ErrSpriteNotFound", id, err)
+		}
+	}
+}
+
+func TestSpritePackLookupReadsOnlyTheSprite(t *testing.T) {
+	splitDir := t.TempDir()
+	for _, name := range []string{"1.png", "500.png", "1000.png"} {
+		writeTestPNG(t, filepath.Join(splitDir, name), newTestImage(32, 32))
+	}
+	_, restore := captureLogs(t)
+	defer restore()
+
+	packPath := filepath.Join(t.TempDir(), "sprites.pack")
+	if _, err := WriteSpritePack(splitDir, packPath, nil); err != nil {
+		t.Fatalf("WriteSpritePack: %v", err)
+	}
+	data, err := os.ReadFile(packPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	r := &countingReaderAt{r: bytes.NewReader(data)}
+	pack, err := sprites.NewSpritePack(r, int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewSpritePack: %v", err)
+	}
+	if r.bytes != sprites.SpritePackHeaderLen {
+		t.Fatalf("opening read %d bytes, want only the %d byte header", r.bytes, sprites.SpritePackHeaderLen)
+	}
+
+	r.reads, r.bytes = 0, 0
+	sprite, err := pack.SpriteData(1000)
+	if err != nil {
+		t.Fatalf("SpriteData(1000): %v", err)
+	}
+	if r.reads != 2 || r.bytes != sprites.SpritePackSlotLen+len(sprite) {
+		t.Fatalf("lookup made %d reads of %d bytes, want 2 reads of %d", r.reads, r.bytes, sprites.SpritePackSlotLen+len(sprite))
+	}
+}
+
+func TestWriteSpritePackCountsBadSprites(t *testing.T) {
+	splitDir := t.TempDir()
+	writeTestPNG(t, filepath.Join(splitDir, "1.png"), newTestImage(32, 32))
+	if err := os.WriteFile(filepath.Join(splitDir, "2.png"), []byte("not a png"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	_, restore := captureLogs(t)
+	defer restore()
+
+	packPath := filepath.Join(t.TempDir(), "sprites.pack")
+	res, err := WriteSpritePack(splitDir, packPath, nil)
+	if err != nil {
+		t.Fatalf("WriteSpritePack: %v", err)
+	}
+	if res.Processed != 1 || res.Failed != 1 {
+		t.Fatalf("result = %+v, want 1 processed and 1 failed", res)
+	}
+
+	if _, err := WriteSpritePack(t.TempDir(), packPath, nil); !errors.Is(err, ErrNoSplitSprites) {
+		t.Fatalf("empty dir error = %v, want ErrNoSplitSprites", err)
+	}
+}
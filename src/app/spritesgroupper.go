@@ -5,39 +5,86 @@ import (
 	"fmt"
 	"image"
 	"image/draw"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 	bar "github.com/schollz/progressbar/v3"
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
 )
 
-type spriteInfo struct {
-	SpriteIDs []int
-}
-
-func GroupSplitSprites(catalogContentJsonPath, appearancesFileName, splitSpitesDir, outputGroupedDir string) {
-	datPath := filepath.Join(catalogContentJsonPath, appearancesFileName)
-	if _, err := os.Stat(datPath); err != nil {
-		log.Fatal().Msgf("[read] dat file not found: %v", err)
+// GroupSplitSprites composes one image per appearance frame group from the
+// sprites written by split. Missing and empty sprites leave blank slots;
+// groups without sprites, or whose sprites are all missing, such as after
+// split skipped them as empty, are skipped. Other per-group failures are
+// collected in the Result. The error is set when the appearances
+// file cannot be read or the output directory cannot be created.
+func GroupSplitSprites(catalogContentJsonPath, appearancesFileName, splitSpitesDir, outputGroupedDir string, out Output) (Result, error) {
+	if err := out.validate(); err != nil {
+		return Result{}, err
 	}
-
-	data, err := os.ReadFile(datPath)
+	if err := out.Layout.check(GroupNames); err != nil {
+		return Result{}, err
+	}
+	datPath := filepath.Join(catalogContentJsonPath, appearancesFileName)
+	apps, err := sprites.LoadAppearances(datPath)
 	if err != nil {
-		log.Fatal().Msgf("[read] failed to read dat file: %v", err)
+		log.Error().Msgf("[read] failed to read dat file: %v", err)
+		return Result{}, fmt.Errorf("read appearances: %w", err)
 	}
-	log.Debug().Msgf("[read] appearances.dat bytes=%d", len(data))
+	log.Debug().
+		Int("objects", len(apps.Objects)).
+		Int("outfits", len(apps.Outfits)).
+		Int("effects", len(apps.Effects)).
+		Int("missiles", len(apps.Missiles)).
+		Msg("[read] appearances decoded")
 
-	groups := scanSpriteInfos(data)
-	log.Debug().Msgf("[parse] found %d candidate groups (sprite-info blocks)", len(groups))
+	groups := appearanceGroups(apps)
+	log.Debug().Msgf("[parse] found %d groups (frame groups)", len(groups))
 
-	if err := os.MkdirAll(outputGroupedDir, 0o755); err != nil {
-		log.Fatal().Msgf("[fs] failed to create outputGroupedDir=%s: %v", outputGroupedDir, err)
+	if err := out.mkdir(outputGroupedDir); err != nil {
+		log.Error().Msgf("[fs] failed to create outputGroupedDir=%s: %v", outputGroupedDir, err)
+		return Result{}, fmt.Errorf("create %s: %w", outputGroupedDir, err)
 	}
 	log.Debug().Msgf("[fs] outputGroupedDir directory ready: %s", outputGroupedDir)
 
-	exported, skipped, failPNG := 0, 0, 0
+	res := writeGroups(groups, openSpriteDir(splitSpitesDir), outputGroupedDir, out)
+	if err := out.finish(outputGroupedDir); err != nil {
+		log.Err(err).Msg("failed to finish grouped output")
+		res.fail(outputGroupedDir, err)
+	}
+
+	if res.Processed == 0 && res.Skipped > 0 {
+		log.Warn().
+			Str("splitDir", splitSpitesDir).
+			Msg("No group could be composed. Did you run the extract and split command?")
+	}
+	pngErrors := res.Failed + res.Missing
+	if pngErrors > 0 {
+		log.Warn().
+			Int("pngErrors", pngErrors).
+			Msg("Some PNGs could not be composed. Did you run the extract and split command?")
+	}
+
+	log.Info().
+		Int("exported", res.Processed).
+		Int("skipped", res.Skipped).
+		Int("missing", res.Missing).
+		Int("pngErrors", pngErrors).
+		Str("outputGroupedDir", outputGroupedDir).
+		Msg("Exporting groups finished")
+
+	return res, nil
+}
+
+// writeGroups composes and writes one image per group, reading sprites from
+// src.
+func writeGroups(groups []appearanceGroup, src spriteSource, outputGroupedDir string, out Output) Result {
+	var res Result
+
 	progress := bar.NewOptions(
 		len(groups),
 		bar.OptionSetDescription("Grouping sprites"),
@@ -49,7 +96,7 @@ func GroupSplitSprites(catalogContentJsonPath, appearancesFileName, splitSpitesD
 	)
 	for idx, g := range groups {
 		if len(g.SpriteIDs) == 0 {
-			skipped++
+			res.Skipped++
 			if idx < 5 {
 				log.Debug().Msgf("[skip #%d] no sprite IDs", idx)
 			}
@@ -57,186 +104,325 @@ func GroupSplitSprites(catalogContentJsonPath, appearancesFileName, splitSpitesD
 			continue
 		}
 
-		first, last := g.SpriteIDs[0], g.SpriteIDs[len(g.SpriteIDs)-1]
-		base := strconv.Itoa(first)
-		if first != last {
-			base = fmt.Sprintf("%d-%d", first, last)
+		base := g.fileName(out.Layout.AppearanceNames)
+		var vars nameVars
+		if out.Layout.custom() {
+			vars = g.nameVars()
 		}
-		outPNG := filepath.Join(outputGroupedDir, base+".png")
+		name := out.Layout.file(base, vars, out.Ext())
 
 		log.Debug().Int("group", idx).Int("sprites", len(g.SpriteIDs)).Msg("compose group")
 
-		img, err := composeGroupImage(splitSpitesDir, g)
+		img, err := composeGroupImage(src, g, out.GroupMode)
+		if errors.Is(err, errNoTiles) {
+			// Every sprite is a blank slot, e.g. after split skipped them
+			// as empty, so there is nothing to write.
+			res.Skipped++
+			log.Debug().Int("group", idx).Str("file", name).Msg("skipping: no sprites of the group were split")
+			_ = progress.Add(1)
+			continue
+		}
 		if err != nil {
-			failPNG++
+			res.fail(base, err)
 			log.Error().Msgf("[compose #%d] %v", idx, err)
 			_ = progress.Add(1)
 			continue
 		}
-		if err := writePNG(outPNG, img); err != nil {
-			failPNG++
-			log.Error().Msgf("[writePNG #%d] %v", idx, err)
+		if err := out.writeImage(outputGroupedDir, name, out.scale(img)); err != nil {
+			res.fail(base, err)
+			log.Error().Msgf("[write #%d] %v", idx, err)
 			_ = progress.Add(1)
 			continue
 		}
-		log.Debug().Int("group", idx).Str("outPNG", outPNG).Msg("wrote grouped PNG")
-		exported++
+		log.Debug().Int("group", idx).Str("file", name).Msg("wrote grouped image")
+		res.Processed++
 		_ = progress.Add(1)
 	}
 	_ = progress.Finish()
 
-	if failPNG > 0 {
-		log.Warn().
-			Int("pngErrors", failPNG).
-			Msg("Some PNGs could not be composed. Did you run the extract and split command?")
-	}
+	return res
+}
 
-	log.Info().
-		Int("exported", exported).
-		Int("skipped", skipped).
-		Int("pngErrors", failPNG).
-		Str("outputGroupedDir", outputGroupedDir).
-		Msg("Exporting groups finished")
+// appearanceGroup is one frame group of an appearance, as group writes it.
+type appearanceGroup struct {
+	sprites.SpriteInfo
+	category   sprites.AppearanceCategory
+	appearance int
+	name       string
+	frameGroup int
+	// frameGroups is the number of frame groups of the appearance.
+	frameGroups int
 }
 
-func scanSpriteInfos(buf []byte) []spriteInfo {
-	out := make([]spriteInfo, 0, 1024)
-	n, i, scanned := len(buf), 0, 0
+// fileName is the built-in name of g: "<category>s/<appearance>", with
+// "_<frameGroup>" for outfits and for appearances with several frame groups,
+// and "_<name>" when withName is set and the appearance has a name.
+func (g appearanceGroup) fileName(withName bool) string {
+	name := g.category.String() + "s/" + strconv.Itoa(g.appearance)
+	if g.category == sprites.CategoryOutfit || g.frameGroups > 1 {
+		name += "_" + strconv.Itoa(g.frameGroup)
+	}
+	if n := fileSafeName(g.name); withName && n != "" {
+		name += "_" + n
+	}
+	return name
+}
 
-	for i < n-8 {
-		if !(buf[i] == 0x08 && i+6 < n && buf[i+2] == 0x10 && buf[i+4] == 0x18 && buf[i+6] == 0x20) {
-			i++
-			continue
-		}
-		w, p, ok := readVarint(buf, i+1)
-		if !ok {
-			i++
-			continue
-		}
-		h, p, ok := readVarint(buf, p+1)
-		if !ok {
-			i++
-			continue
-		}
-		l, p, ok := readVarint(buf, p+1)
-		if !ok {
-			i++
-			continue
-		}
-		pw, p, ok := readVarint(buf, p+1)
-		if !ok {
-			i++
-			continue
-		}
-		if w <= 0 || h <= 0 || l <= 0 {
-			i++
-			continue
-		}
+// nameVars are the GroupNames placeholders of g.
+func (g appearanceGroup) nameVars() nameVars {
+	vars := nameVars{
+		"appearance": g.appearance,
+		"category":   g.category.String(),
+		"name":       fileSafeName(g.name),
+		"frameGroup": g.frameGroup,
+	}
+	if n := len(g.SpriteIDs); n > 0 {
+		vars["first"], vars["last"] = g.SpriteIDs[0], g.SpriteIDs[n-1]
+	}
+	return vars
+}
 
-		ids := make([]int, 0, 64)
-		k := p
-		for k < n && buf[k] == 0x28 {
-			v, k2, ok := readVarint(buf, k+1)
-			if !ok {
-				break
+// appearanceGroups lists every frame group of every appearance, in file
+// order: objects, outfits, effects, then missiles.
+func appearanceGroups(apps *sprites.Appearances) []appearanceGroup {
+	var out []appearanceGroup
+	for _, c := range sprites.AppearanceCategories {
+		for _, a := range apps.List(c) {
+			for i, g := range a.FrameGroups {
+				out = append(out, appearanceGroup{
+					SpriteInfo:  g.SpriteInfo,
+					category:    c,
+					appearance:  a.ID,
+					name:        a.Name,
+					frameGroup:  i,
+					frameGroups: len(a.FrameGroups),
+				})
 			}
-			ids = append(ids, v)
-			k = k2
-			if len(ids) > 1_000_000 {
-				break
-			}
-		}
-		out = append(out, spriteInfo{SpriteIDs: ids})
-		if scanned < 5 {
-			log.Printf("[scan] off=%d w=%d h=%d layers=%d pw=%d ids=%d", i, w, h, l, pw, len(ids))
 		}
-		scanned++
-		i = k
 	}
-	log.Printf("[scan] total=%d sprite-info blocks", len(out))
 	return out
 }
 
-func readVarint(buf []byte, i int) (int, int, bool) {
-	var x uint64
-	var s uint
-	start := i
-	for {
-		if i >= len(buf) {
-			return 0, start, false
-		}
-		b := buf[i]
-		if b < 0x80 {
-			if s >= 64 {
-				return 0, start, false
-			}
-			x |= uint64(b) << s
-			i++
-			return int(x), i, true
+// errNoTiles is returned by composeGroupImage when none of the group's sprites
+// exist.
+var errNoTiles = errors.New("no tiles found for this group (check spritesDir)")
+
+// spriteSource yields single sprites by ID. It is implemented by spriteDir
+// for the output of split and by Client for in-memory reads.
+type spriteSource interface {
+	Sprite(id int) (image.Image, error)
+}
+
+// spriteDir reads the files written by split, in any format an ImageEncoder
+// writes and under any layout. Sprites that a json dedup run skipped are read
+// from the file holding their pixels.
+type spriteDir struct {
+	dir string
+	// files indexes the sprites of a custom layout; nil means the built-in
+	// "<id>.<ext>" names.
+	files map[int]string
+	dups  map[int]string
+}
+
+func openSpriteDir(dir string) spriteDir {
+	d := spriteDir{dir: dir, dups: duplicateSprites(dir)}
+	layout, err := loadLayout(dir)
+	if err != nil {
+		log.Warn().Err(err).Str("dir", dir).Msg("failed to read " + layoutFileName)
+	}
+	if layout.custom() {
+		if d.files, err = splitSpriteFiles(dir); err != nil {
+			log.Warn().Err(err).Str("dir", dir).Msg("failed to list split sprites")
+		}
+	}
+	return d
+}
+
+func (d spriteDir) Sprite(id int) (image.Image, error) {
+	if name, ok := d.dups[id]; ok {
+		return loadImage(filepath.Join(d.dir, filepath.FromSlash(name)))
+	}
+	if d.files != nil {
+		name, ok := d.files[id]
+		if !ok {
+			return nil, fmt.Errorf("sprite %d: %w", id, fs.ErrNotExist)
 		}
-		x |= uint64(b&0x7F) << s
-		s += 7
-		i++
-		if s > 70 {
-			return 0, start, false
+		return loadImage(filepath.Join(d.dir, filepath.FromSlash(name)))
+	}
+	base := strconv.Itoa(id)
+	var err error
+	for _, ext := range imageExtensions {
+		var img image.Image
+		img, err = loadImage(filepath.Join(d.dir, base+ext))
+		if !errors.Is(err, fs.ErrNotExist) {
+			return img, err
 		}
 	}
+	return nil, err
 }
 
-func composeGroupImage(splitSpitesDir string, g spriteInfo) (image.Image, error) {
-	total := len(g.SpriteIDs)
+// GroupMode selects how composeGroupImage arranges the sprites of a group.
+type GroupMode string
+
+const (
+	// GroupGrid puts every sprite in its own cell. Rows follow the z and y
+	// patterns and the layers, columns the x patterns and, for each of
+	// them, the animation phases.
+	GroupGrid GroupMode = "grid"
+	// GroupComposite draws the layers of each pattern over each other and
+	// gives every z pattern a row and every phase a column. The x and y
+	// patterns of an object are placed on the 32-pixel tile grid of the
+	// map, where a sprite several tiles large covers the tiles above and
+	// left of its own, so multi-tile objects, grounds and walls take their
+	// real shape. Other categories, whose patterns are directions, keep one
+	// cell per pattern, and outfits leave out their color template layer.
+	GroupComposite GroupMode = "composite"
+)
+
+// GroupModes lists the modes accepted in Output.GroupMode.
+var GroupModes = []GroupMode{GroupGrid, GroupComposite}
+
+// check rejects modes other than GroupModes; empty means GroupGrid.
+func (m GroupMode) check() error {
+	names := make([]string, len(GroupModes))
+	for i, known := range GroupModes {
+		if m == "" || m == known {
+			return nil
+		}
+		names[i] = string(known)
+	}
+	return fmt.Errorf("unknown group mode %q (want %s)", string(m), strings.Join(names, ", "))
+}
+
+// tileSize is the size of a map tile, the unit the client positions sprites
+// in.
+const tileSize = 32
+
+// composeGroupImage arranges the sprites of g as mode says. A sprite info
+// whose sprite count does not match its patterns, layers and phases is laid
+// out in one row. Missing and fully transparent sprites, such as those split
+// with EmptyTilesSkip, leave a blank slot.
+func composeGroupImage(src spriteSource, g appearanceGroup, mode GroupMode) (image.Image, error) {
+	info := g.SpriteInfo
+	total := len(info.SpriteIDs)
 	if total == 0 {
 		return nil, errors.New("no sprite ids")
 	}
+	if info.SpriteCount() != total {
+		log.Debug().
+			Int("appearance", g.appearance).
+			Int("sprites", total).
+			Int("want", info.SpriteCount()).
+			Msg("sprite count does not match the patterns; laying the group out in one row")
+		info = sprites.SpriteInfo{PatternWidth: total, Layers: 1, SpriteIDs: info.SpriteIDs}
+	}
 
-	tiles := make([]image.Image, total)
-	var tileW, tileH int
-	for idx := 0; idx < total; idx++ {
-		path := filepath.Join(splitSpitesDir, strconv.Itoa(g.SpriteIDs[idx])+".png")
-		img, err := loadPNG(path)
+	tiles := make(map[int]image.Image, total)
+	var cell image.Point
+	var loadErr error
+	for _, id := range info.SpriteIDs {
+		if _, ok := tiles[id]; ok {
+			continue
+		}
+		img, err := src.Sprite(id)
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, sprites.ErrSpriteNotFound) {
+			log.Debug().Int("sprite", id).Msg("blank tile: sprite missing")
+			tiles[id] = nil
+			continue
+		}
 		if err != nil {
-			log.Error().Str("file", path).Msg("tile error")
+			log.Error().Int("sprite", id).Err(err).Msg("tile error")
+			tiles[id] = nil
+			if loadErr == nil {
+				loadErr = fmt.Errorf("sprite %d: %w", id, err)
+			}
 			continue
 		}
-		if tileW == 0 {
-			b := img.Bounds()
-			tileW, tileH = b.Dx(), b.Dy()
+		b := img.Bounds()
+		cell.X, cell.Y = max(cell.X, b.Dx()), max(cell.Y, b.Dy())
+		if imageEmpty(img) {
+			img = nil
 		}
-		tiles[idx] = img
+		tiles[id] = img
 	}
-	if tileW == 0 {
-		return nil, errors.New("no tiles found for this group (check spritesDir)")
+	if cell.X == 0 {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		return nil, errNoTiles
 	}
-	if tileW < 32 || tileH < 32 {
+	if cell.X < tileSize || cell.Y < tileSize {
 		return nil, errors.New("tile size too small")
 	}
 
-	dst := image.NewNRGBA(image.Rect(0, 0, tileW*total, tileH))
-	for i := 0; i < total; i++ {
-		if tiles[i] == nil {
-			continue
+	// drawTile draws sprite id with its bottom-right corner at corner, the
+	// way the client anchors sprites larger than a tile.
+	drawTile := func(dst *image.NRGBA, id int, corner image.Point) {
+		tile := tiles[id]
+		if tile == nil {
+			return
+		}
+		b := tile.Bounds()
+		draw.Draw(dst, image.Rectangle{Min: corner.Sub(b.Size()), Max: corner}, tile, b.Min, draw.Over)
+	}
+
+	phases, layers := info.Phases(), info.LayerCount()
+	px, py, pz := info.PatternsX(), info.PatternsY(), info.PatternsZ()
+	if mode != GroupComposite {
+		dst := image.NewNRGBA(image.Rect(0, 0, cell.X*px*phases, cell.Y*pz*py*layers))
+		for phase := 0; phase < phases; phase++ {
+			for z := 0; z < pz; z++ {
+				for y := 0; y < py; y++ {
+					for x := 0; x < px; x++ {
+						for layer := 0; layer < layers; layer++ {
+							col := x*phases + phase
+							row := (z*py+y)*layers + layer
+							corner := image.Pt((col+1)*cell.X, (row+1)*cell.Y)
+							drawTile(dst, info.SpriteIDs[info.SpriteIndex(phase, z, y, x, layer)], corner)
+						}
+					}
+				}
+			}
+		}
+		return dst, nil
+	}
+
+	step := cell
+	if g.category == sprites.CategoryObject {
+		step = image.Pt(tileSize, tileSize)
+	}
+	frame := image.Pt((px-1)*step.X+cell.X, (py-1)*step.Y+cell.Y)
+	drawLayers := animationLayers(g.category, info)
+	dst := image.NewNRGBA(image.Rect(0, 0, frame.X*phases, frame.Y*pz))
+	for phase := 0; phase < phases; phase++ {
+		for z := 0; z < pz; z++ {
+			origin := image.Pt(phase*frame.X, z*frame.Y)
+			// Top to bottom and left to right, so southern and eastern
+			// sprites overlap their neighbours as on the map.
+			for y := 0; y < py; y++ {
+				for x := 0; x < px; x++ {
+					corner := origin.Add(image.Pt(x*step.X+cell.X, y*step.Y+cell.Y))
+					for layer := 0; layer < drawLayers; layer++ {
+						drawTile(dst, info.SpriteIDs[info.SpriteIndex(phase, z, y, x, layer)], corner)
+					}
+				}
+			}
 		}
-		pt := image.Pt(i*tileW, 0)
-		draw.Draw(dst, image.Rectangle{Min: pt, Max: pt.Add(tiles[i].Bounds().Size())}, tiles[i], tiles[i].Bounds().Min, draw.Over)
 	}
 	return dst, nil
 }
 
-func loadPNG(path string) (image.Image, error) {
-	fi, err := os.Stat(path)
-	if err != nil {
-		return nil, err // file truly not found or perms
-	}
+func loadImage(path string) (image.Image, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return nil, err // open failure (perm/locks)
+		return nil, err // file not found, perms or locks
 	}
 	defer f.Close()
 
 	// try generic decoder (gives you the real reason if decoding fails)
-	img, format, err := image.Decode(f)
+	img, _, err := image.Decode(f)
 	if err != nil {
-		log.Fatal().Msgf("[decode] failed: size=%dB format=%q: %v", fi.Size(), format, err)
+		return nil, fmt.Errorf("decode %s: %w", path, err)
 	}
 	return img, nil
 }
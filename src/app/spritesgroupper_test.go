@@ -1,7 +1,7 @@
 package app
 
 import (
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -10,49 +10,9 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
-)
-
-func TestReadVarintDecodesValues(t *testing.T) {
-	var buf [10]byte
-	n := binary.PutUvarint(buf[:], 300)
-
-	got, next, ok := readVarint(buf[:n], 0)
-	if !ok {
-		t.Fatalf("readVarint reported failure")
-	}
-	if got != 300 {
-		t.Fatalf("readVarint decoded %d, want 300", got)
-	}
-	if next != n {
-		t.Fatalf("readVarint next index = %d, want %d", next, n)
-	}
-}
 
-func TestReadVarintFailsOnTruncatedInput(t *testing.T) {
-	buf := []byte{0x80}
-
-	if _, _, ok := readVarint(buf, 0); ok {
-		t.Fatalf("readVarint succeeded on truncated input")
-	}
-}
-
-func TestScanSpriteInfosExtractsGroups(t *testing.T) {
-	block1 := buildSpriteInfoBlock(32, 32, 1, 1, 100, 101)
-	block2 := buildSpriteInfoBlock(64, 64, 2, 1)
-	buf := append(block1, block2...)
-	buf = append(buf, 0x00) // ensure loop can advance past last candidate
-
-	groups := scanSpriteInfos(buf)
-	if len(groups) != 2 {
-		t.Fatalf("scanSpriteInfos returned %d groups, want 2", len(groups))
-	}
-	if got := groups[0].SpriteIDs; len(got) != 2 || got[0] != 100 || got[1] != 101 {
-		t.Fatalf("first group IDs = %v, want [100 101]", got)
-	}
-	if got := groups[1].SpriteIDs; len(got) != 0 {
-		t.Fatalf("second group should have zero IDs, got %v", got)
-	}
-}
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+)
 
 func TestGroupSplitSpritesExportsGroupsAndLogsSummary(t *testing.T) {
 	buf, restore := captureLogs(t)
@@ -70,12 +30,10 @@ func TestGroupSplitSpritesExportsGroupsAndLogsSummary(t *testing.T) {
 		t.Fatalf("MkdirAll splitDir: %v", err)
 	}
 
-	blockSkip := buildSpriteInfoBlock(32, 32, 1, 1)
-	blockGood := buildSpriteInfoBlock(32, 32, 1, 1, 1, 2)
-	blockMissing := buildSpriteInfoBlock(32, 32, 1, 1, 99)
-	dat := append(blockSkip, blockGood...)
-	dat = append(dat, blockMissing...)
-	dat = append(dat, 0x00)
+	dat := protoMessage{}.
+		message(1, buildAppearance(100, buildSpriteInfo(1, 1, 1, 1))).
+		message(1, buildAppearance(101, buildSpriteInfo(1, 1, 1, 1, 1, 2))).
+		message(2, buildAppearance(1, buildSpriteInfo(1, 1, 1, 1, 99)))
 
 	datPath := filepath.Join(catalogDir, "appearances.dat")
 	if err := os.WriteFile(datPath, dat, 0o644); err != nil {
@@ -85,9 +43,17 @@ func TestGroupSplitSpritesExportsGroupsAndLogsSummary(t *testing.T) {
 	writeSolidTile(t, splitDir, 1, color.NRGBA{R: 255, A: 255}, 32)
 	writeSolidTile(t, splitDir, 2, color.NRGBA{G: 255, A: 255}, 32)
 
-	GroupSplitSprites(catalogDir, "appearances.dat", splitDir, outputDir)
+	res, err := GroupSplitSprites(catalogDir, "appearances.dat", splitDir, outputDir, Output{})
+	if err != nil {
+		t.Fatalf("GroupSplitSprites error: %v", err)
+	}
+	// Sprite 99 was never written, as after split --emptyTiles skip, so
+	// its group is skipped like the one without sprites.
+	if res.Processed != 1 || res.Skipped != 2 || res.Missing != 0 || res.Failed != 0 {
+		t.Fatalf("result = %+v, want 1 processed, 2 skipped", res)
+	}
 
-	outPath := filepath.Join(outputDir, "1-2.png")
+	outPath := filepath.Join(outputDir, "objects", "101.png")
 	if _, err := os.Stat(outPath); err != nil {
 		t.Fatalf("grouped PNG not written: %v", err)
 	}
@@ -108,7 +74,10 @@ func TestGroupSplitSpritesExportsGroupsAndLogsSummary(t *testing.T) {
 	}
 
 	logs := buf.String()
-	for _, want := range []string{"\"exported\":1", "\"skipped\":1", "\"pngErrors\":1"} {
+	if strings.Contains(logs, `"level":"error"`) {
+		t.Fatalf("skipped group logged as an error: %s", logs)
+	}
+	for _, want := range []string{"\"exported\":1", "\"skipped\":2", "\"pngErrors\":0"} {
 		if !strings.Contains(logs, want) {
 			t.Fatalf("log output missing %s: %s", want, logs)
 		}
@@ -118,6 +87,15 @@ func TestGroupSplitSpritesExportsGroupsAndLogsSummary(t *testing.T) {
 	}
 }
 
+func TestGroupSplitSpritesErrorsWhenAppearancesMissing(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	if _, err := GroupSplitSprites(t.TempDir(), "appearances.dat", t.TempDir(), t.TempDir(), Output{}); err == nil {
+		t.Fatalf("expected error when the appearances file is missing")
+	}
+}
+
 func TestComposeGroupImageStitchesTilesHorizontally(t *testing.T) {
 	dir := t.TempDir()
 	ids := []int{10, 11, 12}
@@ -132,7 +110,7 @@ func TestComposeGroupImageStitchesTilesHorizontally(t *testing.T) {
 		writeSolidTile(t, dir, id, colors[i], size)
 	}
 
-	img, err := composeGroupImage(dir, spriteInfo{SpriteIDs: ids})
+	img, err := composeGroupImage(openSpriteDir(dir), appearanceGroup{SpriteInfo: sprites.SpriteInfo{SpriteIDs: ids}}, GroupGrid)
 	if err != nil {
 		t.Fatalf("composeGroupImage error: %v", err)
 	}
@@ -158,7 +136,7 @@ func TestComposeGroupImageStitchesTilesHorizontally(t *testing.T) {
 func TestComposeGroupImageReturnsErrorWhenTilesMissing(t *testing.T) {
 	dir := t.TempDir()
 
-	_, err := composeGroupImage(dir, spriteInfo{SpriteIDs: []int{42}})
+	_, err := composeGroupImage(openSpriteDir(dir), appearanceGroup{SpriteInfo: sprites.SpriteInfo{SpriteIDs: []int{42}}}, GroupGrid)
 	if err == nil {
 		t.Fatalf("composeGroupImage expected error when tiles missing")
 	}
@@ -167,26 +145,49 @@ func TestComposeGroupImageReturnsErrorWhenTilesMissing(t *testing.T) {
 	}
 }
 
-func buildSpriteInfoBlock(w, h, layers, pw int, ids ...int) []byte {
-	block := []byte{0x08}
-	block = append(block, encodeVarint(w)...)
-	block = append(block, 0x10)
-	block = append(block, encodeVarint(h)...)
-	block = append(block, 0x18)
-	block = append(block, encodeVarint(layers)...)
-	block = append(block, 0x20)
-	block = append(block, encodeVarint(pw)...)
-	for _, id := range ids {
-		block = append(block, 0x28)
-		block = append(block, encodeVarint(id)...)
+func TestComposeGroupImageReportsUnreadableSprites(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "42.png"), []byte("not a png"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	_, err := composeGroupImage(openSpriteDir(dir), appearanceGroup{SpriteInfo: sprites.SpriteInfo{SpriteIDs: []int{42}}}, GroupGrid)
+	if err == nil || errors.Is(err, errNoTiles) || !strings.Contains(err.Error(), "sprite 42") {
+		t.Fatalf("composeGroupImage error = %v, want the decode error of sprite 42", err)
 	}
-	return block
 }
 
-func encodeVarint(v int) []byte {
-	var buf [10]byte
-	n := binary.PutUvarint(buf[:], uint64(v))
-	return buf[:n]
+func TestComposeGroupImageLeavesMissingAndEmptySpritesBlank(t *testing.T) {
+	buf, restore := captureLogs(t)
+	defer restore()
+
+	dir := t.TempDir()
+	red := color.NRGBA{R: 255, A: 255}
+	writeSolidTile(t, dir, 1, color.NRGBA{}, 32)
+	writeSolidTile(t, dir, 3, red, 32)
+
+	img, err := composeGroupImage(openSpriteDir(dir), appearanceGroup{SpriteInfo: sprites.SpriteInfo{SpriteIDs: []int{1, 2, 3}}}, GroupGrid)
+	if err != nil {
+		t.Fatalf("composeGroupImage error: %v", err)
+	}
+	nrgba := img.(*image.NRGBA)
+	if nrgba.Bounds().Dx() != 96 {
+		t.Fatalf("composed width = %d, want 96", nrgba.Bounds().Dx())
+	}
+	if px := nrgba.NRGBAAt(1, 1); px.A != 0 {
+		t.Fatalf("empty slot pixel = %#v, want transparent", px)
+	}
+	if px := nrgba.NRGBAAt(33, 1); px.A != 0 {
+		t.Fatalf("missing slot pixel = %#v, want transparent", px)
+	}
+	if px := nrgba.NRGBAAt(65, 1); px != red {
+		t.Fatalf("tile 3 pixel = %#v, want %#v", px, red)
+	}
+	if strings.Contains(buf.String(), "tile error") {
+		t.Fatalf("missing sprite logged as tile error: %q", buf.String())
+	}
 }
 
 func writeSolidTile(t *testing.T, dir string, id int, c color.NRGBA, size int) {
@@ -210,3 +211,178 @@ func writeSolidTile(t *testing.T, dir string, id int, c color.NRGBA, size int) {
 		t.Fatalf("png.Encode %s: %v", path, err)
 	}
 }
+
+func TestGroupSplitSpritesNamesFilesByCategoryAndAppearance(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	catalogDir := t.TempDir()
+	splitDir := t.TempDir()
+	outputDir := t.TempDir()
+	// Appearances sharing sprites must not overwrite each other.
+	dat := protoMessage{}.
+		message(1, buildAppearance(7, buildSpriteInfo(1, 1, 1, 1, 1)).str(4, "Gold Coin!")).
+		message(1, buildAppearance(9, buildSpriteInfo(1, 1, 1, 1, 1), buildSpriteInfo(1, 1, 1, 1, 2))).
+		message(2, buildAppearance(128, buildSpriteInfo(1, 1, 1, 1, 1), buildSpriteInfo(1, 1, 1, 1, 2))).
+		message(2, buildAppearance(129, buildSpriteInfo(1, 1, 1, 1, 2))).
+		message(3, buildAppearance(7, buildSpriteInfo(1, 1, 1, 1, 1)))
+	if err := os.WriteFile(filepath.Join(catalogDir, "appearances.dat"), dat, 0o644); err != nil {
+		t.Fatalf("WriteFile dat: %v", err)
+	}
+	writeSolidTile(t, splitDir, 1, color.NRGBA{R: 255, A: 255}, 32)
+	writeSolidTile(t, splitDir, 2, color.NRGBA{G: 255, A: 255}, 32)
+
+	res, err := GroupSplitSprites(catalogDir, "appearances.dat", splitDir, outputDir, Output{Layout: Layout{AppearanceNames: true}})
+	if err != nil || res.Processed != 7 {
+		t.Fatalf("GroupSplitSprites = %+v, %v, want 7 groups", res, err)
+	}
+	for _, name := range []string{
+		"objects/7_gold_coin.png",
+		"objects/9_0.png",
+		"objects/9_1.png",
+		"outfits/128_0.png",
+		"outfits/128_1.png",
+		"outfits/129_0.png",
+		"effects/7.png",
+	} {
+		if _, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(name))); err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+	}
+
+	tmpl, _ := ParseNameTemplate("{name}-{appearance}")
+	shard, _ := ParseNameTemplate("{category}")
+	custom := t.TempDir()
+	if _, err := GroupSplitSprites(catalogDir, "appearances.dat", splitDir, custom, Output{Layout: Layout{Name: tmpl, Shard: shard}}); err != nil {
+		t.Fatalf("GroupSplitSprites: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(custom, "object", "gold_coin-7.png")); err != nil {
+		t.Fatalf("expected object/gold_coin-7.png: %v", err)
+	}
+}
+
+// tileColor gives every test sprite ID its own color.
+func tileColor(id int) color.NRGBA {
+	return color.NRGBA{R: uint8(id * 20), G: uint8(255 - id*20), B: uint8(id), A: 255}
+}
+
+func writeColorTiles(t *testing.T, dir string, size int, ids ...int) {
+	t.Helper()
+	for _, id := range ids {
+		writeSolidTile(t, dir, id, tileColor(id), size)
+	}
+}
+
+func TestComposeGroupImageLaysOutPatternGrid(t *testing.T) {
+	dir := t.TempDir()
+	writeColorTiles(t, dir, 32, 1, 2, 3, 4, 5, 6, 7, 8)
+	info := sprites.SpriteInfo{
+		PatternWidth:  2,
+		PatternHeight: 1,
+		PatternDepth:  1,
+		Layers:        2,
+		Animation:     &sprites.SpriteAnimation{Phases: make([]sprites.SpritePhase, 2)},
+		SpriteIDs:     []int{1, 2, 3, 4, 5, 6, 7, 8},
+	}
+
+	img, err := composeGroupImage(openSpriteDir(dir), appearanceGroup{SpriteInfo: info}, GroupGrid)
+	if err != nil {
+		t.Fatalf("composeGroupImage error: %v", err)
+	}
+	nrgba := img.(*image.NRGBA)
+	if b := nrgba.Bounds(); b.Dx() != 4*32 || b.Dy() != 2*32 {
+		t.Fatalf("bounds = %v, want 4 columns (x by phase) and 2 rows (layers)", b)
+	}
+	for phase := 0; phase < 2; phase++ {
+		for x := 0; x < 2; x++ {
+			for layer := 0; layer < 2; layer++ {
+				id := info.SpriteIDs[info.SpriteIndex(phase, 0, 0, x, layer)]
+				col, row := x*2+phase, layer
+				if got := nrgba.NRGBAAt(col*32+16, row*32+16); got != tileColor(id) {
+					t.Fatalf("cell (%d,%d) = %#v, want sprite %d", col, row, got, id)
+				}
+			}
+		}
+	}
+}
+
+func TestComposeGroupImageCompositeAssemblesObjects(t *testing.T) {
+	dir := t.TempDir()
+	writeColorTiles(t, dir, 64, 1, 2, 3, 4)
+	g := appearanceGroup{
+		category:   sprites.CategoryObject,
+		SpriteInfo: sprites.SpriteInfo{PatternWidth: 2, PatternHeight: 2, PatternDepth: 1, Layers: 1, SpriteIDs: []int{1, 2, 3, 4}},
+	}
+
+	img, err := composeGroupImage(openSpriteDir(dir), g, GroupComposite)
+	if err != nil {
+		t.Fatalf("composeGroupImage error: %v", err)
+	}
+	nrgba := img.(*image.NRGBA)
+	// Four 64x64 sprites on a 2x2 tile grid, each covering the tiles above
+	// and left of its own.
+	if b := nrgba.Bounds(); b.Dx() != 96 || b.Dy() != 96 {
+		t.Fatalf("bounds = %v, want 96x96", b)
+	}
+	for _, tt := range []struct{ x, y, id int }{{5, 5, 1}, {80, 5, 2}, {5, 80, 3}, {40, 40, 4}, {90, 90, 4}} {
+		if got := nrgba.NRGBAAt(tt.x, tt.y); got != tileColor(tt.id) {
+			t.Fatalf("pixel (%d,%d) = %#v, want sprite %d", tt.x, tt.y, got, tt.id)
+		}
+	}
+}
+
+func TestComposeGroupImageCompositeDropsOutfitTemplate(t *testing.T) {
+	dir := t.TempDir()
+	writeColorTiles(t, dir, 32, 1, 2, 3, 4)
+	g := appearanceGroup{
+		category:   sprites.CategoryOutfit,
+		SpriteInfo: sprites.SpriteInfo{PatternWidth: 2, PatternHeight: 1, PatternDepth: 1, Layers: 2, SpriteIDs: []int{1, 2, 3, 4}},
+	}
+
+	img, err := composeGroupImage(openSpriteDir(dir), g, GroupComposite)
+	if err != nil {
+		t.Fatalf("composeGroupImage error: %v", err)
+	}
+	nrgba := img.(*image.NRGBA)
+	if b := nrgba.Bounds(); b.Dx() != 64 || b.Dy() != 32 {
+		t.Fatalf("bounds = %v, want one cell per direction", b)
+	}
+	if nrgba.NRGBAAt(16, 16) != tileColor(1) || nrgba.NRGBAAt(48, 16) != tileColor(3) {
+		t.Fatalf("outfit cells show the template layer")
+	}
+}
+
+func TestGroupSplitSpritesRejectsUnknownGroupMode(t *testing.T) {
+	_, err := GroupSplitSprites(t.TempDir(), "appearances.dat", t.TempDir(), t.TempDir(), Output{GroupMode: "spiral"})
+	if err == nil || !strings.Contains(err.Error(), "unknown group mode") {
+		t.Fatalf("error = %v, want unknown group mode", err)
+	}
+}
+
+func TestGroupSplitSpritesReportsFinishFailuresAgainstOutputDir(t *testing.T) {
+	_, restore := captureLogs(t)
+	defer restore()
+
+	catalogDir := t.TempDir()
+	outputDir := t.TempDir()
+	dat := protoMessage{}.message(1, buildAppearance(100, buildSpriteInfo(1, 1, 1, 1, 1)))
+	if err := os.WriteFile(filepath.Join(catalogDir, "appearances.dat"), dat, 0o644); err != nil {
+		t.Fatalf("WriteFile dat: %v", err)
+	}
+	// A non-empty directory where the stale layout record would be removed
+	// makes finishing the output fail without any dedup.
+	if err := os.MkdirAll(filepath.Join(outputDir, layoutFileName, "keep"), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	res, err := GroupSplitSprites(catalogDir, "appearances.dat", t.TempDir(), outputDir, Output{})
+	if err != nil {
+		t.Fatalf("GroupSplitSprites error: %v", err)
+	}
+	if res.Failed != 1 || len(res.Errors) != 1 {
+		t.Fatalf("result = %+v, want the finish failure", res)
+	}
+	if got := res.Errors[0].Item; got != outputDir {
+		t.Fatalf("failed item = %q, want the output directory %q", got, outputDir)
+	}
+}
@@ -1,33 +1,60 @@
 package app
 
 import (
-	"image/png"
-	"os"
+	"errors"
+	"fmt"
+	"io/fs"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 	bar "github.com/schollz/progressbar/v3"
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
 )
 
-var spriteFilePattern = regexp.MustCompile(`^Sprites-(\d+)-(\d+)\.png$`)
+var (
+	// ErrNoSprites is returned when a directory holds no extracted sheets.
+	ErrNoSprites = errors.New("no sprite sheets found")
+	// ErrNoAppearances is returned when the catalog lists no appearances file.
+	ErrNoAppearances = errors.New("no appearances file found")
+)
+
+// spriteFilePattern matches sheets written by extract. The sprite size suffix
+// is optional so sheets from older extractions can still be split.
+var spriteFilePattern = regexp.MustCompile(`^Sprites-(?P<first>\d+)-(?P<last>\d+)(?:-(?P<type>\d+x\d+))?` + imageExtPattern + `$`)
+
+// sheetNamePattern matches the built-in sheet name inside a custom layout.
+const sheetNamePattern = `Sprites-(?P<first>\d+)-(?P<last>\d+)-(?P<type>\d+x\d+)`
+
+// SplitSprites splits every extracted sheet in extractedDir into per-sprite
+// images in the format of out. Sheets may be in any format extract writes,
+// under any layout. Per-sheet failures are collected in the Result; the
+// error is set when there is nothing to split.
+func SplitSprites(extractedDir, splitOutputDir string, out Output) (Result, error) {
+	var res Result
+	if err := out.validate(); err != nil {
+		return res, err
+	}
+	if err := out.Layout.check(SpriteNames); err != nil {
+		return res, err
+	}
 
-func SplitSprites(extractedDir, splitOutputDir string) {
-	entries, err := os.ReadDir(extractedDir)
+	sheets, err := scanLayout(extractedDir, spriteFilePattern, sheetNamePattern)
 	if err != nil {
 		log.Err(err).
 			Str("extractedDir", extractedDir).
 			Msg("Failed to read directory. Did you run the extract command?")
-		return
+		return res, fmt.Errorf("read %s: %w", extractedDir, err)
 	}
 
-	total := getTotalToSplit(entries)
+	total := len(sheets)
 	if total == 0 {
 		log.Warn().
 			Str("extractedDir", extractedDir).
 			Msg("No sprites found to split. Did you run the extract command?")
-		return
+		return res, fmt.Errorf("%w in %s", ErrNoSprites, extractedDir)
 	}
 
 	progress := bar.NewOptions(
@@ -40,50 +67,75 @@ func SplitSprites(extractedDir, splitOutputDir string) {
 		bar.OptionClearOnFinish(),
 	)
 
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
-		}
-
-		m := spriteFilePattern.FindStringSubmatch(e.Name())
-		if m == nil {
-			continue
-		}
-
-		first, err1 := strconv.Atoi(m[1])
-		second, err2 := strconv.Atoi(m[2])
+	for _, sheet := range sheets {
+		first, err1 := strconv.Atoi(sheet.vars["first"])
+		second, err2 := strconv.Atoi(sheet.vars["last"])
 		if err1 != nil || err2 != nil {
-			log.Error().Str("file", e.Name()).Msg("invalid numeric part in filename")
+			log.Error().Str("file", sheet.name).Msg("invalid numeric part in filename")
+			res.fail(sheet.name, errors.Join(err1, err2))
 			_ = progress.Add(1)
 			continue
 		}
 
-		path := filepath.Join(extractedDir, e.Name())
-		f, err := os.Open(path)
-		if err != nil {
+		path := filepath.Join(extractedDir, filepath.FromSlash(sheet.name))
+		img, err := loadImage(path)
+		var openErr *fs.PathError
+		if errors.As(err, &openErr) {
 			log.Error().Str("file", path).Err(err).Msg("failed to open")
+			res.fail(sheet.name, err)
 			_ = progress.Add(1)
 			continue
 		}
-		img, err := png.Decode(f)
-		_ = f.Close()
 		if err != nil {
-			log.Error().Str("file", path).Err(err).Msg("failed to decode PNG")
+			log.Error().Str("file", path).Err(err).Msg("failed to decode image")
+			res.fail(sheet.name, err)
 			_ = progress.Add(1)
 			continue
 		}
 
-		log.Debug().Msgf("processing %s (first=%d, second=%d)", e.Name(), first, second)
-		err = SplitSpriteSheet(img, first, second, splitOutputDir)
+		spriteType, ok := sheetSpriteType(sheet.vars["type"])
+		if !ok {
+			spriteType = guessSpriteType(second - first + 1)
+			log.Debug().Str("file", sheet.name).Stringer("spriteType", spriteType).Msg("no sprite type in filename; guessing")
+		}
+
+		log.Debug().Msgf("processing %s (first=%d, second=%d, type=%s)", sheet.name, first, second, spriteType)
+		err = SplitSpriteSheet(img, first, second, spriteType, splitOutputDir, out)
 		if err != nil {
 			log.Error().Err(err).Msg("failed to split")
+			res.fail(sheet.name, err)
+		} else {
+			res.Processed++
 		}
 		_ = progress.Add(1)
 	}
 	_ = progress.Finish()
+
+	if err := out.finish(splitOutputDir); err != nil {
+		log.Err(err).Msg("failed to finish split output")
+		res.fail(splitOutputDir, err)
+	}
+	return res, nil
 }
 
-func GetAppearancesFileNameFromCatalogContent(in string) string {
+// sheetSpriteType reads the sprite size recorded in a sheet name, such as
+// "32x64".
+func sheetSpriteType(size string) (sprites.SpriteType, bool) {
+	ws, hs, ok := strings.Cut(size, "x")
+	if !ok {
+		return sprites.SpriteType32x32, false
+	}
+	w, err1 := strconv.Atoi(ws)
+	h, err2 := strconv.Atoi(hs)
+	if err1 != nil || err2 != nil {
+		return sprites.SpriteType32x32, false
+	}
+	return sprites.SpriteTypeFromSize(w, h)
+}
+
+// GetAppearancesFileNameFromCatalogContent returns the file of the catalog's
+// "appearances" entry.
+func GetAppearancesFileNameFromCatalogContent(in string) (string, error) {
 	elems, errs := StreamCatalogContent(in)
 
 	for {
@@ -95,14 +147,14 @@ func GetAppearancesFileNameFromCatalogContent(in string) string {
 				// Decide what to do per element type here:
 				switch e.Type {
 				case "appearances":
-					return e.File // if you still want this side effect
+					return e.File, nil
 				default:
 					log.Debug().Msgf("skip type=%s file=%s", e.Type, e.File)
 				}
 			}
 		case err, ok := <-errs:
 			if ok && err != nil {
-				log.Err(err).Msg("stream error")
+				return "", fmt.Errorf("read catalog: %w", err)
 			}
 			errs = nil
 		}
@@ -111,20 +163,5 @@ func GetAppearancesFileNameFromCatalogContent(in string) string {
 		}
 	}
 
-	log.Panic().Msg("no appearances file found")
-	return "" // satisfy compiler
-}
-
-func getTotalToSplit(entries []os.DirEntry) int {
-	total := 0
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
-		}
-		if spriteFilePattern.MatchString(e.Name()) {
-			total++
-		}
-	}
-
-	return total
+	return "", ErrNoAppearances
 }
@@ -2,6 +2,7 @@ package app
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -46,12 +47,13 @@ func writeTestPNG(t *testing.T, path string, img image.Image) {
 	}
 }
 
-func TestGetTotalToSplitCountsOnlySpritePNGs(t *testing.T) {
+func TestScanLayoutFindsOnlySheets(t *testing.T) {
 	dir := t.TempDir()
 
 	names := []string{
 		"Sprites-1-2.png",
 		"Sprites-10-11.png",
+		"Sprites-20-21-32x64.png",
 		"ignore.txt",
 	}
 
@@ -66,13 +68,15 @@ func TestGetTotalToSplitCountsOnlySpritePNGs(t *testing.T) {
 		t.Fatalf("Mkdir: %v", err)
 	}
 
-	entries, err := os.ReadDir(dir)
+	sheets, err := scanLayout(dir, spriteFilePattern, sheetNamePattern)
 	if err != nil {
-		t.Fatalf("ReadDir: %v", err)
+		t.Fatalf("scanLayout: %v", err)
 	}
-
-	if got, want := getTotalToSplit(entries), 2; got != want {
-		t.Fatalf("getTotalToSplit = %d, want %d", got, want)
+	if got, want := len(sheets), 3; got != want {
+		t.Fatalf("scanLayout found %d sheets, want %d", got, want)
+	}
+	if sheets[2].vars["type"] != "32x64" || sheets[0].vars["type"] != "" {
+		t.Fatalf("sheet types = %q, %q, want \"\" and 32x64", sheets[0].vars["type"], sheets[2].vars["type"])
 	}
 }
 
@@ -89,13 +93,16 @@ func TestGetAppearancesFileNameFromCatalogContent(t *testing.T) {
 		t.Fatalf("WriteFile: %v", err)
 	}
 
-	got := GetAppearancesFileNameFromCatalogContent(path)
+	got, err := GetAppearancesFileNameFromCatalogContent(path)
+	if err != nil {
+		t.Fatalf("GetAppearancesFileNameFromCatalogContent error: %v", err)
+	}
 	if got != "appearances.dat" {
 		t.Fatalf("GetAppearancesFileNameFromCatalogContent = %q, want %q", got, "appearances.dat")
 	}
 }
 
-func TestGetAppearancesFileNameFromCatalogContentPanicsWhenMissing(t *testing.T) {
+func TestGetAppearancesFileNameFromCatalogContentErrorsWhenMissing(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "catalog.json")
 	contents := `[
@@ -105,13 +112,13 @@ func TestGetAppearancesFileNameFromCatalogContentPanicsWhenMissing(t *testing.T)
 		t.Fatalf("WriteFile: %v", err)
 	}
 
-	defer func() {
-		if r := recover(); r == nil {
-			t.Fatalf("expected panic when appearances entry missing")
-		}
-	}()
+	if _, err := GetAppearancesFileNameFromCatalogContent(path); !errors.Is(err, ErrNoAppearances) {
+		t.Fatalf("error = %v, want ErrNoAppearances", err)
+	}
 
-	_ = GetAppearancesFileNameFromCatalogContent(path)
+	if _, err := GetAppearancesFileNameFromCatalogContent(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatalf("expected error for missing catalog")
+	}
 }
 
 func TestSplitSpritesLogsErrorWhenDirectoryMissing(t *testing.T) {
@@ -119,7 +126,9 @@ func TestSplitSpritesLogsErrorWhenDirectoryMissing(t *testing.T) {
 	defer restore()
 
 	extracted := filepath.Join(t.TempDir(), "missing")
-	SplitSprites(extracted, t.TempDir())
+	if _, err := SplitSprites(extracted, t.TempDir(), Output{}); err == nil {
+		t.Fatalf("expected error for missing directory")
+	}
 
 	out := buf.String()
 	if !strings.Contains(out, "Failed to read directory. Did you run the extract command?") {
@@ -139,7 +148,9 @@ func TestSplitSpritesWarnsWhenNoSprites(t *testing.T) {
 	buf, restore := captureLogs(t)
 	defer restore()
 
-	SplitSprites(dir, t.TempDir())
+	if _, err := SplitSprites(dir, t.TempDir(), Output{}); !errors.Is(err, ErrNoSprites) {
+		t.Fatalf("error = %v, want ErrNoSprites", err)
+	}
 
 	out := buf.String()
 	if !strings.Contains(out, "No sprites found to split. Did you run the extract command?") {
@@ -166,7 +177,13 @@ func TestSplitSpritesProcessesSpriteSheets(t *testing.T) {
 	_, restore := captureLogs(t)
 	defer restore()
 
-	SplitSprites(extracted, split)
+	res, err := SplitSprites(extracted, split, Output{})
+	if err != nil {
+		t.Fatalf("SplitSprites error: %v", err)
+	}
+	if res.Processed != 1 || res.Failed != 0 {
+		t.Fatalf("result = %+v, want 1 processed", res)
+	}
 
 	for id := 100; id <= 101; id++ {
 		path := filepath.Join(split, fmt.Sprintf("%d.png", id))
@@ -186,6 +203,33 @@ func TestSplitSpritesProcessesSpriteSheets(t *testing.T) {
 	}
 }
 
+func TestSplitSpritesUsesSpriteTypeFromFilename(t *testing.T) {
+	extracted := t.TempDir()
+	split := t.TempDir()
+
+	writeTestPNG(t, filepath.Join(extracted, "Sprites-100-101-64x32.png"), image.NewRGBA(image.Rect(0, 0, 128, 64)))
+
+	_, restore := captureLogs(t)
+	defer restore()
+
+	SplitSprites(extracted, split, Output{})
+
+	for id := 100; id <= 101; id++ {
+		f, err := os.Open(filepath.Join(split, fmt.Sprintf("%d.png", id)))
+		if err != nil {
+			t.Fatalf("Open sprite %d: %v", id, err)
+		}
+		decoded, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("Decode sprite %d: %v", id, err)
+		}
+		if bounds := decoded.Bounds(); bounds.Dx() != 64 || bounds.Dy() != 32 {
+			t.Fatalf("sprite %d bounds = %v, want 64x32", id, bounds)
+		}
+	}
+}
+
 func TestSplitSpritesLogsDecodeErrors(t *testing.T) {
 	extracted := t.TempDir()
 	split := t.TempDir()
@@ -197,10 +241,16 @@ func TestSplitSpritesLogsDecodeErrors(t *testing.T) {
 	buf, restore := captureLogs(t)
 	defer restore()
 
-	SplitSprites(extracted, split)
+	res, err := SplitSprites(extracted, split, Output{})
+	if err != nil {
+		t.Fatalf("SplitSprites error: %v", err)
+	}
+	if res.Failed != 1 || len(res.Errors) != 1 || res.Errors[0].Item != "Sprites-200-201.png" {
+		t.Fatalf("result = %+v, want one failure for Sprites-200-201.png", res)
+	}
 
 	out := buf.String()
-	if !strings.Contains(out, "failed to decode PNG") {
+	if !strings.Contains(out, "failed to decode image") {
 		t.Fatalf("log output %q missing decode error", out)
 	}
 }
@@ -217,7 +267,7 @@ func TestSplitSpritesLogsInvalidNumericPart(t *testing.T) {
 	buf, restore := captureLogs(t)
 	defer restore()
 
-	SplitSprites(extracted, split)
+	SplitSprites(extracted, split, Output{})
 
 	out := buf.String()
 	if !strings.Contains(out, "invalid numeric part in filename") {
@@ -242,7 +292,7 @@ func TestSplitSpritesLogsOpenErrors(t *testing.T) {
 	buf, restore := captureLogs(t)
 	defer restore()
 
-	SplitSprites(extracted, split)
+	SplitSprites(extracted, split, Output{})
 
 	out := buf.String()
 	if !strings.Contains(out, "failed to open") {
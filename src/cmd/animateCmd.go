@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/simivar/tibia-sprites-exporter/src/app"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	AnimatedOutputPath string
+	AnimationFormat    string
+	AnimateIDs         string
+	AnimateCacheSize   int
+)
+
+func init() {
+	rootCmd.AddCommand(animateCmd)
+
+	animateCmd.Flags().StringVar(&AnimatedOutputPath, "animatedOutput", defaultAnimatedOutputPath(), "animated appearances output path")
+	animateCmd.Flags().StringVar(&AnimationFormat, "animationFormat", string(app.AnimationGIF), "animation format: gif or apng")
+	animateCmd.Flags().StringVar(&AnimateIDs, "ids", "", "appearance IDs to animate, e.g. 100-200,305 (default all)")
+	animateCmd.Flags().IntVar(&AnimateCacheSize, "cacheSize", 64, "number of decoded sprite sheets kept in memory")
+	bindFlags(animateCmd)
+}
+
+var animateCmd = &cobra.Command{
+	Use:   "animate",
+	Short: "Writes an animated GIF or APNG per animated appearance",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		catalogDir := app.ExpandPath(viper.GetString("catalog"))
+		animatedOutput := app.ExpandPath(viper.GetString(flagKey(cmd, "animatedOutput")))
+
+		ids, err := app.ParseIDRanges(viper.GetString(flagKey(cmd, "ids")))
+		if err != nil {
+			return resultError(app.Result{}, err)
+		}
+
+		log.Info().
+			Str("catalog", catalogDir).
+			Str("animatedOutput", animatedOutput).
+			Msg("Tibia Sprites animate running")
+
+		res, err := app.AnimateAppearances(catalogDir, animatedOutput, app.AnimateOptions{
+			Format:    app.AnimationFormat(viper.GetString(flagKey(cmd, "animationFormat"))),
+			IDs:       ids,
+			CacheSize: viper.GetInt(flagKey(cmd, "cacheSize")),
+		})
+
+		log.Info().Msg("Tibia Sprites animate finished")
+		return resultError(res, err)
+	},
+}
+
+func defaultAnimatedOutputPath() string {
+	return app.ExpandPath(
+		"./output/animated",
+	)
+}
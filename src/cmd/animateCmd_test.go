@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestAnimateCommandRejectsUnknownFormat(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	catalogDir := t.TempDir()
+	catalog := `[{"type":"appearances","file":"appearances.dat"}]`
+	if err := os.WriteFile(filepath.Join(catalogDir, "catalog-content.json"), []byte(catalog), 0o644); err != nil {
+		t.Fatalf("write catalog-content.json: %v", err)
+	}
+	viper.Set("catalog", catalogDir)
+	viper.Set("animatedOutput", filepath.Join(t.TempDir(), "animated"))
+	viper.Set("animationFormat", "webm")
+
+	if err := animateCmd.RunE(animateCmd, nil); exitCode(err) != exitFailure {
+		t.Fatalf("animateCmd.RunE error = %v, want exit code %d", err, exitFailure)
+	}
+}
+
+func TestAnimateCommandSucceedsWithoutAppearances(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	catalogDir := t.TempDir()
+	catalog := `[{"type":"appearances","file":"appearances.dat"}]`
+	if err := os.WriteFile(filepath.Join(catalogDir, "catalog-content.json"), []byte(catalog), 0o644); err != nil {
+		t.Fatalf("write catalog-content.json: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(catalogDir, "appearances.dat"), nil, 0o644); err != nil {
+		t.Fatalf("write appearances.dat: %v", err)
+	}
+	animatedDir := filepath.Join(t.TempDir(), "animated")
+	viper.Set("catalog", catalogDir)
+	viper.Set("animatedOutput", animatedDir)
+	viper.Set("animationFormat", "apng")
+
+	if err := animateCmd.RunE(animateCmd, nil); err != nil {
+		t.Fatalf("animateCmd.RunE error: %v", err)
+	}
+	if _, err := os.Stat(animatedDir); err != nil {
+		t.Fatalf("expected animated output directory: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simivar/tibia-sprites-exporter/src/app"
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	DumpOutputPath      string
+	DumpFormat          string
+	DumpSplitOutputPath string
+)
+
+func init() {
+	rootCmd.AddCommand(appearancesCmd)
+	appearancesCmd.AddCommand(appearancesDumpCmd)
+
+	appearancesDumpCmd.Flags().StringVar(&DumpSplitOutputPath, "splitOutput", defaultSplitOutputPath(), "split sprites output path, used for the sprite file paths")
+	appearancesDumpCmd.Flags().StringVar(&DumpOutputPath, "dumpOutput", defaultDumpOutputPath(), `file to write the appearances to, or "-" for standard output`)
+	appearancesDumpCmd.Flags().StringVar(&DumpFormat, "dumpFormat", "", "json or yaml (default from the --dumpOutput extension, json otherwise)")
+	bindFlags(appearancesDumpCmd)
+}
+
+var appearancesCmd = &cobra.Command{
+	Use:   "appearances",
+	Short: "Works with the appearances database of the client",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var appearancesDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Writes every object, outfit, effect and missile as JSON or YAML",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		catalogDir := app.ExpandPath(viper.GetString("catalog"))
+		catalogFile := filepath.Join(catalogDir, "catalog-content.json")
+		splitOutput := app.ExpandPath(viper.GetString(flagKey(cmd, "splitOutput")))
+		dumpOutput := viper.GetString(flagKey(cmd, "dumpOutput"))
+		if dumpOutput != "-" {
+			dumpOutput = app.ExpandPath(dumpOutput)
+		}
+		format := app.DumpFormat(viper.GetString(flagKey(cmd, "dumpFormat")))
+		if format == "" {
+			format = app.DumpFormatOf(dumpOutput)
+		}
+
+		appearancesFileName, err := app.GetAppearancesFileNameFromCatalogContent(catalogFile)
+		if err != nil {
+			return resultError(app.Result{}, err)
+		}
+		log.Info().
+			Str("appearances", appearancesFileName).
+			Str("dumpOutput", dumpOutput).
+			Msg("Tibia Sprites appearances dump running")
+
+		var res app.Result
+		if dumpOutput == "-" {
+			var apps *sprites.Appearances
+			apps, err = sprites.LoadAppearances(filepath.Join(catalogDir, appearancesFileName))
+			if err == nil {
+				var dump *app.AppearancesDump
+				dump, res = app.NewAppearancesDump(apps, splitOutput)
+				err = dump.Encode(cmd.OutOrStdout(), format)
+			}
+		} else {
+			res, err = app.DumpAppearances(catalogDir, appearancesFileName, splitOutput, dumpOutput, format)
+		}
+
+		log.Info().Msg("Tibia Sprites appearances dump finished")
+		return resultError(res, err)
+	},
+}
+
+func defaultDumpOutputPath() string {
+	return app.ExpandPath(
+		"./output/appearances.json",
+	)
+}
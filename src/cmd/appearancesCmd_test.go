@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func writeEmptyAppearancesCatalog(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	catalogContent := []byte(`[{"type":"appearances","file":"appearances.dat"}]`)
+	if err := os.WriteFile(filepath.Join(dir, "catalog-content.json"), catalogContent, 0o644); err != nil {
+		t.Fatalf("write catalog-content.json: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "appearances.dat"), nil, 0o644); err != nil {
+		t.Fatalf("write appearances.dat: %v", err)
+	}
+	return dir
+}
+
+func TestAppearancesDumpCommand(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	viper.Set("catalog", writeEmptyAppearancesCatalog(t))
+	viper.Set("splitOutput", t.TempDir())
+	dumpFile := filepath.Join(t.TempDir(), "appearances.yml")
+	viper.Set("dumpOutput", dumpFile)
+
+	if err := appearancesDumpCmd.RunE(appearancesDumpCmd, nil); err != nil {
+		t.Fatalf("appearancesDumpCmd.RunE error = %v", err)
+	}
+	data, err := os.ReadFile(dumpFile)
+	if err != nil {
+		t.Fatalf("read dump: %v", err)
+	}
+	if !strings.Contains(string(data), "objects: []") {
+		t.Fatalf("expected a YAML dump, got %q", data)
+	}
+
+	var stdout bytes.Buffer
+	appearancesDumpCmd.SetOut(&stdout)
+	t.Cleanup(func() { appearancesDumpCmd.SetOut(nil) })
+	viper.Set("dumpOutput", "-")
+	if err := appearancesDumpCmd.RunE(appearancesDumpCmd, nil); err != nil {
+		t.Fatalf("appearancesDumpCmd.RunE error = %v", err)
+	}
+	if !strings.Contains(stdout.String(), `"objects": []`) {
+		t.Fatalf("expected a JSON dump on stdout, got %q", stdout.String())
+	}
+
+	viper.Set("dumpFormat", "xml")
+	if err := appearancesDumpCmd.RunE(appearancesDumpCmd, nil); exitCode(err) != exitFailure {
+		t.Fatalf("unknown format error = %v, want exit code %d", err, exitFailure)
+	}
+}
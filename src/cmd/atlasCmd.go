@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/simivar/tibia-sprites-exporter/src/app"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	AtlasOutputPath      string
+	AtlasMaxSize         int
+	AtlasPadding         int
+	AtlasTrim            bool
+	AtlasIDs             string
+	AtlasSplitOutputPath string
+)
+
+func init() {
+	rootCmd.AddCommand(atlasCmd)
+
+	atlasCmd.Flags().StringVar(&AtlasSplitOutputPath, "splitOutput", defaultSplitOutputPath(), "split sprites output path")
+	atlasCmd.Flags().StringVar(&AtlasOutputPath, "atlasOutput", defaultAtlasOutputPath(), "atlas pages output path")
+	atlasCmd.Flags().IntVar(&AtlasMaxSize, "maxSize", 2048, "maximum atlas page width and height, a power of two")
+	atlasCmd.Flags().IntVar(&AtlasPadding, "padding", 1, "transparent pixels around every frame")
+	atlasCmd.Flags().BoolVar(&AtlasTrim, "trim", false, "trim the transparent border of every sprite")
+	atlasCmd.Flags().StringVar(&AtlasIDs, "ids", "", "sprite IDs to include, e.g. 100-200,305 (default all)")
+	bindFlags(atlasCmd)
+}
+
+var atlasCmd = &cobra.Command{
+	Use:   "atlas",
+	Short: "Packs split sprites into texture atlas pages with JSON frame metadata",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		splitOutput := app.ExpandPath(viper.GetString(flagKey(cmd, "splitOutput")))
+		atlasOutput := app.ExpandPath(viper.GetString(flagKey(cmd, "atlasOutput")))
+
+		ids, err := app.ParseIDRanges(viper.GetString(flagKey(cmd, "ids")))
+		if err != nil {
+			return resultError(app.Result{}, err)
+		}
+
+		log.Info().
+			Str("splitOutput", splitOutput).
+			Str("atlasOutput", atlasOutput).
+			Msg("Tibia Sprites atlas running")
+
+		res, err := app.BuildAtlas(splitOutput, atlasOutput, app.AtlasOptions{
+			MaxSize: viper.GetInt(flagKey(cmd, "maxSize")),
+			Padding: viper.GetInt(flagKey(cmd, "padding")),
+			Trim:    viper.GetBool(flagKey(cmd, "trim")),
+			IDs:     ids,
+		})
+
+		log.Info().Msg("Tibia Sprites atlas finished")
+		return resultError(res, err)
+	},
+}
+
+func defaultAtlasOutputPath() string {
+	return app.ExpandPath(
+		"./output/atlas",
+	)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func writeTestSprite(t *testing.T, path string) {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	img.SetNRGBA(1, 1, color.NRGBA{R: 255, A: 255})
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create %s: %v", path, err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("encode %s: %v", path, err)
+	}
+}
+
+func TestAtlasCommandRejectsInvalidIDs(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	viper.Set("splitOutput", t.TempDir())
+	viper.Set("ids", "10-x")
+
+	if err := atlasCmd.RunE(atlasCmd, nil); exitCode(err) != exitFailure {
+		t.Fatalf("atlasCmd.RunE error = %v, want exit code %d", err, exitFailure)
+	}
+}
+
+func TestAtlasCommandWritesPages(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	splitDir := t.TempDir()
+	atlasDir := filepath.Join(t.TempDir(), "atlas")
+	writeTestSprite(t, filepath.Join(splitDir, "7.png"))
+
+	viper.Set("splitOutput", splitDir)
+	viper.Set("atlasOutput", atlasDir)
+	viper.Set("maxSize", 64)
+
+	if err := atlasCmd.RunE(atlasCmd, nil); err != nil {
+		t.Fatalf("atlasCmd.RunE error: %v", err)
+	}
+	for _, name := range []string{"atlas-0.png", "atlas-0.json"} {
+		if _, err := os.Stat(filepath.Join(atlasDir, name)); err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+	}
+}
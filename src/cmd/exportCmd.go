@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simivar/tibia-sprites-exporter/src/app"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	ExportSheets            bool
+	ExportTiles             bool
+	ExportGroups            bool
+	ExportCacheSize         int
+	ExportWorkersCount      int
+	ExportSplitOutputPath   string
+	ExportGroupedOutputPath string
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().BoolVar(&ExportSheets, "sheets", false, "write sprite sheets to the output path")
+	exportCmd.Flags().BoolVar(&ExportTiles, "tiles", false, "write one image per sprite, in --format, to the split output path")
+	exportCmd.Flags().BoolVar(&ExportGroups, "groups", false, "write one image per appearance frame group, in --format, to the grouped output path")
+	exportCmd.Flags().IntVar(&ExportWorkersCount, "workers", defaultWorkersCount(), "number of sprite sheets decoded in parallel")
+	exportCmd.Flags().IntVar(&ExportCacheSize, "cacheSize", 64, "number of decoded sprite sheets kept in memory for composing groups")
+	exportCmd.Flags().StringVar(&ExportSplitOutputPath, "splitOutput", defaultSplitOutputPath(), "split sprites output path")
+	exportCmd.Flags().StringVar(&ExportGroupedOutputPath, "groupedOutput", defaultGroupedOutputPath(), "grouped sprites by appearances.json output path")
+	addGroupFlags(exportCmd)
+	bindFlags(exportCmd)
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports sheets, sprites and groups in one pass, without intermediate files",
+	Long: `Exports sheets, sprites and groups in one pass. Every sheet is decoded once
+and only the outputs selected with --sheets, --tiles and --groups are written.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		catalogDir := app.ExpandPath(viper.GetString("catalog"))
+
+		var opts app.ExportOptions
+		if viper.GetBool(flagKey(cmd, "sheets")) {
+			opts.SheetsDir = app.ExpandPath(viper.GetString("output"))
+		}
+		if viper.GetBool(flagKey(cmd, "tiles")) {
+			opts.TilesDir = app.ExpandPath(viper.GetString(flagKey(cmd, "splitOutput")))
+		}
+		if viper.GetBool(flagKey(cmd, "groups")) {
+			opts.GroupsDir = app.ExpandPath(viper.GetString(flagKey(cmd, "groupedOutput")))
+		}
+		if opts.SheetsDir == "" && opts.TilesDir == "" && opts.GroupsDir == "" {
+			return resultError(app.Result{}, errors.New("nothing to export: pass --sheets, --tiles and/or --groups"))
+		}
+		opts.Workers = viper.GetInt(flagKey(cmd, "workers"))
+		if opts.Workers < 1 {
+			opts.Workers = defaultWorkersCount()
+		}
+		opts.CacheSize = viper.GetInt(flagKey(cmd, "cacheSize"))
+		out, err := outputFromViper()
+		if err != nil {
+			return resultError(app.Result{}, err)
+		}
+		opts.Output = withGroupOptions(cmd, out)
+
+		log.Info().
+			Str("catalog", catalogDir).
+			Str("sheets", opts.SheetsDir).
+			Str("tiles", opts.TilesDir).
+			Str("groups", opts.GroupsDir).
+			Msg("Tibia Sprites export running")
+
+		res, err := app.Export(catalogDir, opts)
+
+		logOutputSummary(out)
+		log.Info().Msg("Tibia Sprites export finished")
+		return resultError(res, err)
+	},
+}
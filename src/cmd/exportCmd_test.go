@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestExportCommandRequiresAnOutput(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	viper.Set("catalog", t.TempDir())
+
+	err := exportCmd.RunE(exportCmd, nil)
+	if err == nil || exitCode(err) != exitFailure {
+		t.Fatalf("exportCmd.RunE error = %v, want exit code %d", err, exitFailure)
+	}
+	if !strings.Contains(err.Error(), "nothing to export") {
+		t.Fatalf("error = %q, want hint about outputs", err)
+	}
+}
+
+func TestExportCommandWritesSelectedOutputs(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	buf := captureLogs(t)
+
+	tempDir := t.TempDir()
+	catalogDir := filepath.Join(tempDir, "catalog")
+	if err := os.MkdirAll(catalogDir, 0o755); err != nil {
+		t.Fatalf("mkdir catalogDir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(catalogDir, "catalog-content.json"), []byte("[]"), 0o644); err != nil {
+		t.Fatalf("write catalog-content.json: %v", err)
+	}
+	sheetsDir := filepath.Join(tempDir, "sheets")
+	splitDir := filepath.Join(tempDir, "split")
+
+	viper.Set("catalog", catalogDir)
+	viper.Set("output", sheetsDir)
+	viper.Set("splitOutput", splitDir)
+	viper.Set("groupedOutput", filepath.Join(tempDir, "grouped"))
+	viper.Set("tiles", true)
+
+	if err := exportCmd.RunE(exportCmd, nil); err != nil {
+		t.Fatalf("exportCmd.RunE error: %v", err)
+	}
+	if _, err := os.Stat(splitDir); err != nil {
+		t.Fatalf("expected split output directory: %v", err)
+	}
+	for _, dir := range []string{sheetsDir, filepath.Join(tempDir, "grouped")} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Fatalf("unselected output %s was created", dir)
+		}
+	}
+	if !strings.Contains(buf.String(), "Tibia Sprites export finished") {
+		t.Fatalf("expected finish log, got %q", buf.String())
+	}
+}
+
+func TestBindFlagsKeepsSharedFlagNamesApart(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+
+	// group and split share --splitOutput; each must read its own flag.
+	bindFlags(splitCmd)
+	bindFlags(groupCmd)
+	if err := splitCmd.Flags().Set("splitOutput", "/tmp/from-split"); err != nil {
+		t.Fatalf("set splitOutput flag: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = splitCmd.Flags().Set("splitOutput", defaultSplitOutputPath())
+		splitCmd.Flags().Lookup("splitOutput").Changed = false
+	})
+
+	if got := viper.GetString(flagKey(splitCmd, "splitOutput")); got != "/tmp/from-split" {
+		t.Fatalf("split splitOutput = %q, want the split command's flag", got)
+	}
+	if got := viper.GetString(flagKey(groupCmd, "splitOutput")); got != defaultSplitOutputPath() {
+		t.Fatalf("group splitOutput = %q, want its own default", got)
+	}
+
+	// A bare key applies to every command that did not get the flag.
+	viper.Set("splitOutput", "/tmp/shared")
+	if got := viper.GetString(flagKey(groupCmd, "splitOutput")); got != "/tmp/shared" {
+		t.Fatalf("group splitOutput = %q, want the shared key", got)
+	}
+	if got := viper.GetString(flagKey(splitCmd, "splitOutput")); got != "/tmp/from-split" {
+		t.Fatalf("split splitOutput = %q, want the flag over the shared key", got)
+	}
+	viper.Set("group.splitOutput", "/tmp/group-only")
+	if got := viper.GetString(flagKey(groupCmd, "splitOutput")); got != "/tmp/group-only" {
+		t.Fatalf("group splitOutput = %q, want the command key over the shared key", got)
+	}
+	if got := commandKey(appearancesDumpCmd); got != "appearances.dump" {
+		t.Fatalf("commandKey(appearances dump) = %q", got)
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"path/filepath"
+	"runtime"
 
 	"github.com/rs/zerolog/log"
 	"github.com/simivar/tibia-sprites-exporter/src/app"
@@ -9,14 +10,26 @@ import (
 	"github.com/spf13/viper"
 )
 
+var (
+	WorkersCount      int
+	ForceExtract      bool
+	SheetNameTemplate string
+)
+
 func init() {
 	rootCmd.AddCommand(extractCmd)
+
+	extractCmd.Flags().IntVar(&WorkersCount, "workers", defaultWorkersCount(), "number of sprite sheets converted in parallel")
+	extractCmd.Flags().BoolVar(&ForceExtract, "force", false, "re-extract every sheet, ignoring the manifest of the previous run")
+	addArchiveFlag(extractCmd)
+	addLayoutFlags(extractCmd, &SheetNameTemplate, "sheetName", `sheet file name template without extension, e.g. "{first}-{last}"`)
+	bindFlags(extractCmd)
 }
 
 var extractCmd = &cobra.Command{
 	Use:   "extract",
 	Short: "Extracts sprites from the Tibia client",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		log.Info().Msg("Tibia Sprites extract running")
 
 		catalogDir := app.ExpandPath(viper.GetString("catalog"))
@@ -28,8 +41,33 @@ var extractCmd = &cobra.Command{
 		CatalogContentJsonPathWithFilename = catalogFile
 		OutputPath = outputDir
 
-		app.ConvertAssetsFromCatalogContent(catalogDir, catalogFile, outputDir)
+		workers := viper.GetInt(flagKey(cmd, "workers"))
+		if workers < 1 {
+			workers = defaultWorkersCount()
+		}
+
+		out, err := outputFromViper()
+		if err == nil {
+			out.Layout, err = layoutFromViper(cmd, "sheetName")
+		}
+		if err != nil {
+			return resultError(app.Result{}, err)
+		}
 
+		res, err := withArchive(cmd, out, func(out app.Output) (app.Result, error) {
+			return app.ConvertAssetsFromCatalogContent(catalogDir, catalogFile, outputDir, app.ExtractOptions{
+				Workers: workers,
+				Force:   viper.GetBool(flagKey(cmd, "force")),
+				Output:  out,
+			})
+		})
+
+		logOutputSummary(out)
 		log.Info().Msg("Tibia Sprites extract finished")
+		return resultError(res, err)
 	},
 }
+
+func defaultWorkersCount() int {
+	return runtime.NumCPU()
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -38,7 +39,9 @@ func TestExtractCommandUpdatesGlobalsFromExpandedPaths(t *testing.T) {
 	viper.Set("catalog", catalogRel)
 	viper.Set("output", outputRel)
 
-	extractCmd.Run(extractCmd, nil)
+	if err := extractCmd.RunE(extractCmd, nil); err != nil {
+		t.Fatalf("extractCmd.RunE error: %v", err)
+	}
 
 	wantCatalog := app.ExpandPath(catalogRel)
 	if CatalogContentJsonPath != wantCatalog {
@@ -61,3 +64,26 @@ func TestExtractCommandUpdatesGlobalsFromExpandedPaths(t *testing.T) {
 		t.Fatalf("expected finish log, got %q", logs)
 	}
 }
+
+func TestWorkersFlagUpdatesGlobalAndViper(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+
+	if err := viper.BindPFlag("workers", extractCmd.Flags().Lookup("workers")); err != nil {
+		t.Fatalf("bind workers flag: %v", err)
+	}
+
+	if err := extractCmd.Flags().Set("workers", "3"); err != nil {
+		t.Fatalf("set workers flag: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = extractCmd.Flags().Set("workers", strconv.Itoa(defaultWorkersCount()))
+	})
+
+	if WorkersCount != 3 {
+		t.Fatalf("WorkersCount = %d, want 3", WorkersCount)
+	}
+	if got := viper.GetInt("workers"); got != 3 {
+		t.Fatalf("viper workers = %d, want 3", got)
+	}
+}
@@ -10,35 +10,62 @@ import (
 )
 
 var (
-	GroupedOutputPath string
+	GroupedOutputPath    string
+	GroupNameTemplate    string
+	GroupSplitOutputPath string
 )
 
 func init() {
 	rootCmd.AddCommand(groupCmd)
 
-	groupCmd.Flags().StringVar(&SplitOutputPath, "splitOutput", defaultSplitOutputPath(), "split sprites output path")
+	groupCmd.Flags().StringVar(&GroupSplitOutputPath, "splitOutput", defaultSplitOutputPath(), "split sprites output path")
 	groupCmd.Flags().StringVar(&GroupedOutputPath, "groupedOutput", defaultGroupedOutputPath(), "grouped sprites by appearances.json output path")
-	_ = viper.BindPFlag("splitOutput", groupCmd.Flags().Lookup("splitOutput"))
-	_ = viper.BindPFlag("groupedOutput", groupCmd.Flags().Lookup("groupedOutput"))
+	addArchiveFlag(groupCmd)
+	addDedupFlag(groupCmd)
+	addLayoutFlags(groupCmd, &GroupNameTemplate, "groupName", `group file name template without extension, e.g. "{category}_{appearance}_{frameGroup}"`)
+	addScaleFlags(groupCmd)
+	addGroupFlags(groupCmd)
+	bindFlags(groupCmd)
 }
 
 var groupCmd = &cobra.Command{
 	Use:   "group",
 	Short: "Groups sprites from the Tibia client based on the appearances file",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		log.Info().Msg("Tibia Sprites group running")
 
 		catalogDir := app.ExpandPath(viper.GetString("catalog"))
 		catalogFile := filepath.Join(catalogDir, "catalog-content.json")
-		splitOutput := app.ExpandPath(viper.GetString("splitOutput"))
-		groupedOutput := app.ExpandPath(viper.GetString("groupedOutput"))
+		splitOutput := app.ExpandPath(viper.GetString(flagKey(cmd, "splitOutput")))
+		groupedOutput := app.ExpandPath(viper.GetString(flagKey(cmd, "groupedOutput")))
+		out, err := outputFromViper()
+		if err == nil {
+			out, err = withDedup(cmd, out)
+		}
+		if err == nil {
+			out.Layout, err = layoutFromViper(cmd, "groupName")
+			out = withGroupOptions(cmd, out)
+		}
+		if err == nil {
+			out.Scaler, err = scalerFromViper(cmd)
+		}
+		if err != nil {
+			return resultError(app.Result{}, err)
+		}
 
-		appearancesFileName := app.GetAppearancesFileNameFromCatalogContent(catalogFile)
+		appearancesFileName, err := app.GetAppearancesFileNameFromCatalogContent(catalogFile)
+		if err != nil {
+			return resultError(app.Result{}, err)
+		}
 		log.Info().Msgf("Appearances file name: %s", appearancesFileName)
 
-		app.GroupSplitSprites(catalogDir, appearancesFileName, splitOutput, groupedOutput)
+		res, err := withArchive(cmd, out, func(out app.Output) (app.Result, error) {
+			return app.GroupSplitSprites(catalogDir, appearancesFileName, splitOutput, groupedOutput, out)
+		})
 
+		logOutputSummary(out)
 		log.Info().Msg("Tibia Sprites group finished")
+		return resultError(res, err)
 	},
 }
 
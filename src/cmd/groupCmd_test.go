@@ -44,7 +44,9 @@ func TestGroupCommandExpandsPathsAndLogs(t *testing.T) {
 	viper.Set("splitOutput", splitRel)
 	viper.Set("groupedOutput", groupedRel)
 
-	groupCmd.Run(groupCmd, nil)
+	if err := groupCmd.RunE(groupCmd, nil); err != nil {
+		t.Fatalf("groupCmd.RunE error: %v", err)
+	}
 
 	groupedDir := app.ExpandPath(groupedRel)
 	info, err := os.Stat(groupedDir)
@@ -115,10 +117,61 @@ func TestGroupSplitOutputFlagUpdatesGlobalAndViper(t *testing.T) {
 		_ = groupCmd.Flags().Set("splitOutput", defaultSplitOutputPath())
 	})
 
-	if SplitOutputPath != override {
-		t.Fatalf("SplitOutputPath = %q, want %q", SplitOutputPath, override)
+	if GroupSplitOutputPath != override {
+		t.Fatalf("GroupSplitOutputPath = %q, want %q", GroupSplitOutputPath, override)
 	}
 	if got := viper.GetString("splitOutput"); got != override {
 		t.Fatalf("viper splitOutput = %q, want %q", got, override)
 	}
 }
+
+// protoField encodes a length-delimited protobuf field. Tests keep fields
+// and lengths below 128, so every varint is one byte.
+func protoField(field int, payload ...byte) []byte {
+	return append([]byte{byte(field<<3 | 2), byte(len(payload))}, payload...)
+}
+
+func TestGroupCommandAppearanceNames(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	catalogDir := writeEmptyAppearancesCatalog(t)
+	// One object, 7 "Gold Coin", with a frame group of sprite 1.
+	spriteInfo := []byte{1 << 3, 1, 2 << 3, 1, 3 << 3, 1, 4 << 3, 1, 5 << 3, 1}
+	frameGroup := append([]byte{1 << 3, 2, 2 << 3, 0}, protoField(3, spriteInfo...)...)
+	object := append([]byte{1 << 3, 7}, protoField(2, frameGroup...)...)
+	object = append(object, protoField(4, []byte("Gold Coin")...)...)
+	if err := os.WriteFile(filepath.Join(catalogDir, "appearances.dat"), protoField(1, object...), 0o644); err != nil {
+		t.Fatalf("write appearances.dat: %v", err)
+	}
+	splitDir := t.TempDir()
+	writeTestSprite(t, filepath.Join(splitDir, "1.png"))
+	groupedDir := t.TempDir()
+	viper.Set("catalog", catalogDir)
+	viper.Set("splitOutput", splitDir)
+	viper.Set("groupedOutput", groupedDir)
+	viper.Set("appearanceNames", true)
+
+	if err := groupCmd.RunE(groupCmd, nil); err != nil {
+		t.Fatalf("groupCmd.RunE error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(groupedDir, "objects", "7_gold_coin.png")); err != nil {
+		t.Fatalf("expected objects/7_gold_coin.png: %v", err)
+	}
+}
+
+func TestGroupCommandRejectsUnknownGroupMode(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	viper.Set("catalog", writeEmptyAppearancesCatalog(t))
+	viper.Set("splitOutput", t.TempDir())
+	viper.Set("groupedOutput", t.TempDir())
+	viper.Set("groupMode", "spiral")
+
+	if err := groupCmd.RunE(groupCmd, nil); exitCode(err) != exitFailure {
+		t.Fatalf("unknown group mode error = %v, want exit code %d", err, exitFailure)
+	}
+}
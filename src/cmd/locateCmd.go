@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simivar/tibia-sprites-exporter/src/app"
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(locateCmd)
+}
+
+var locateCmd = &cobra.Command{
+	Use:   "locate <spriteID>...",
+	Short: "Shows which asset and sheet position holds each sprite",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ids := make([]int, 0, len(args))
+		for _, arg := range args {
+			id, err := strconv.Atoi(arg)
+			if err != nil {
+				return &exitError{code: exitFailure, err: fmt.Errorf("invalid sprite ID %q", arg)}
+			}
+			ids = append(ids, id)
+		}
+
+		catalogDir := app.ExpandPath(viper.GetString("catalog"))
+		client, err := sprites.OpenClient(catalogDir)
+		if err != nil {
+			return &exitError{code: exitFailure, err: err}
+		}
+
+		// References are a bonus: locating works without the appearances.
+		var refs map[int][]sprites.SpriteReference
+		if client.AppearancesFile() != "" {
+			apps, err := client.Appearances()
+			if err != nil {
+				log.Warn().Err(err).Msg("failed to read appearances; not listing references")
+			} else {
+				refs = sprites.SpriteReferences(apps)
+			}
+		}
+
+		out := cmd.OutOrStdout()
+		notFound := 0
+		for _, id := range ids {
+			loc, err := client.Locate(id)
+			if err != nil {
+				notFound++
+				fmt.Fprintln(out, err)
+				continue
+			}
+			printLocation(out, loc, refs)
+		}
+
+		if notFound > 0 {
+			return &exitError{code: exitPartial, err: fmt.Errorf("%d of %d sprites not located", notFound, len(ids))}
+		}
+		return nil
+	},
+}
+
+func printLocation(out io.Writer, loc sprites.SpriteLocation, refs map[int][]sprites.SpriteReference) {
+	w, h := loc.Sheet.SpriteType.Size()
+	fmt.Fprintf(out, "%d:\n", loc.SpriteID)
+	fmt.Fprintf(out, "  file:  %s (sprites %d-%d)\n", loc.Sheet.File, loc.Sheet.FirstSpriteId, loc.Sheet.LastSpriteId)
+	fmt.Fprintf(out, "  type:  %d (%dx%d px)\n", int(loc.Sheet.SpriteType), w, h)
+	fmt.Fprintf(out, "  tile:  row %d, column %d (index %d)\n", loc.Row, loc.Column, loc.Index)
+	fmt.Fprintf(out, "  rect:  x=%d y=%d w=%d h=%d\n", loc.Rect.Min.X, loc.Rect.Min.Y, loc.Rect.Dx(), loc.Rect.Dy())
+	if refs == nil {
+		return
+	}
+	if len(refs[loc.SpriteID]) == 0 {
+		fmt.Fprintf(out, "  used by: no appearance\n")
+		return
+	}
+	fmt.Fprintf(out, "  used by:\n")
+	for _, r := range refs[loc.SpriteID] {
+		name := ""
+		if r.Name != "" {
+			name = fmt.Sprintf(" %q", r.Name)
+		}
+		fmt.Fprintf(out, "    %s %d%s, frame group %d (%s)\n", r.Category, r.AppearanceID, name, r.FrameGroup, r.FixedFrameGroup)
+	}
+}
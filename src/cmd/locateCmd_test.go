@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestLocateCommandPrintsSheetPosition(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	catalogDir := t.TempDir()
+	catalog := `[{"type":"sprite","file":"sprites-1-144.bmp.lzma","spritetype":0,"firstspriteid":1,"lastspriteid":144}]`
+	if err := os.WriteFile(filepath.Join(catalogDir, "catalog-content.json"), []byte(catalog), 0o644); err != nil {
+		t.Fatalf("write catalog-content.json: %v", err)
+	}
+	viper.Set("catalog", catalogDir)
+
+	var out bytes.Buffer
+	locateCmd.SetOut(&out)
+	t.Cleanup(func() { locateCmd.SetOut(nil) })
+
+	err := locateCmd.RunE(locateCmd, []string{"14", "500"})
+	if exitCode(err) != exitPartial {
+		t.Fatalf("locateCmd.RunE error = %v, want exit code %d", err, exitPartial)
+	}
+
+	report := out.String()
+	for _, want := range []string{
+		"  file:  sprites-1-144.bmp.lzma (sprites 1-144)\n",
+		"  type:  0 (32x32 px)\n",
+		"  tile:  row 1, column 1 (index 13)\n",
+		"  rect:  x=32 y=32 w=32 h=32\n",
+		"sprite 500: sprite not found\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Fatalf("report missing %q: %q", want, report)
+		}
+	}
+
+	if err := locateCmd.RunE(locateCmd, []string{"abc"}); exitCode(err) != exitFailure {
+		t.Fatalf("locateCmd.RunE error = %v, want exit code %d for invalid ID", err, exitFailure)
+	}
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simivar/tibia-sprites-exporter/src/app"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	OutfitOutputPath string
+	OutfitHead       int
+	OutfitBody       int
+	OutfitLegs       int
+	OutfitFeet       int
+	OutfitDirection  string
+	OutfitAddons     int
+	OutfitMount      int
+	OutfitStrip      bool
+)
+
+func init() {
+	rootCmd.AddCommand(outfitCmd)
+
+	palette := fmt.Sprintf("color, an outfit palette index from 0 to %d", app.OutfitPaletteSize-1)
+	outfitCmd.Flags().StringVar(&OutfitOutputPath, "outfitOutput", defaultOutfitOutputPath(), "dyed outfits output path")
+	outfitCmd.Flags().IntVar(&OutfitHead, "head", 0, "head "+palette)
+	outfitCmd.Flags().IntVar(&OutfitBody, "body", 0, "body "+palette)
+	outfitCmd.Flags().IntVar(&OutfitLegs, "legs", 0, "legs "+palette)
+	outfitCmd.Flags().IntVar(&OutfitFeet, "feet", 0, "feet "+palette)
+	outfitCmd.Flags().StringVar(&OutfitDirection, "direction", app.DirectionSouth.String(), "direction the outfit faces: north, east, south, west, or all for one image each")
+	outfitCmd.Flags().IntVar(&OutfitAddons, "addons", 0, "addons to draw: 1 for the first, 2 for the second, 3 for both")
+	outfitCmd.Flags().IntVar(&OutfitMount, "mount", 0, "outfit ID of the mount to draw underneath (default none)")
+	outfitCmd.Flags().BoolVar(&OutfitStrip, "strip", false, "write the four directions side by side in one image")
+	addScaleFlags(outfitCmd)
+	bindFlags(outfitCmd)
+}
+
+var outfitCmd = &cobra.Command{
+	Use:   "outfit <outfitID>",
+	Short: "Writes an outfit dyed with head, body, legs and feet colors, with addons and a mount",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return &exitError{code: exitFailure, err: fmt.Errorf("invalid outfit ID %q", args[0])}
+		}
+
+		catalogDir := app.ExpandPath(viper.GetString("catalog"))
+		outfitOutput := app.ExpandPath(viper.GetString(flagKey(cmd, "outfitOutput")))
+		opts := app.OutfitOptions{
+			Colors: app.OutfitColors{
+				Head: viper.GetInt(flagKey(cmd, "head")),
+				Body: viper.GetInt(flagKey(cmd, "body")),
+				Legs: viper.GetInt(flagKey(cmd, "legs")),
+				Feet: viper.GetInt(flagKey(cmd, "feet")),
+			},
+			Addons: viper.GetInt(flagKey(cmd, "addons")),
+			Mount:  viper.GetInt(flagKey(cmd, "mount")),
+			Strip:  viper.GetBool(flagKey(cmd, "strip")),
+		}
+		directions := app.Directions
+		if direction := viper.GetString(flagKey(cmd, "direction")); direction != "all" {
+			opts.Direction, err = app.ParseDirection(direction)
+			if err != nil {
+				return resultError(app.Result{}, err)
+			}
+			directions = []app.Direction{opts.Direction}
+		}
+		out, err := outputFromViper()
+		if err == nil {
+			out.Scaler, err = scalerFromViper(cmd)
+		}
+		if err != nil {
+			return resultError(app.Result{}, err)
+		}
+
+		names, err := app.WriteOutfits(catalogDir, outfitOutput, id, opts, directions, out)
+		if err != nil {
+			return resultError(app.Result{}, err)
+		}
+		for _, name := range names {
+			log.Info().
+				Int("outfit", id).
+				Str("file", filepath.Join(outfitOutput, filepath.FromSlash(name))).
+				Msg("Outfit written")
+		}
+		return nil
+	},
+}
+
+func defaultOutfitOutputPath() string {
+	return app.ExpandPath(
+		"./output/outfits",
+	)
+}
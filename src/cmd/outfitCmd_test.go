@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestOutfitCommandRejectsBadInput(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	outputDir := filepath.Join(t.TempDir(), "outfits")
+	viper.Set("catalog", writeEmptyAppearancesCatalog(t))
+	viper.Set("outfitOutput", outputDir)
+
+	if err := outfitCmd.RunE(outfitCmd, []string{"abc"}); exitCode(err) != exitFailure {
+		t.Fatalf("invalid ID error = %v, want exit code %d", err, exitFailure)
+	}
+	viper.Set("direction", "up")
+	if err := outfitCmd.RunE(outfitCmd, []string{"128"}); exitCode(err) != exitFailure {
+		t.Fatalf("unknown direction error = %v, want exit code %d", err, exitFailure)
+	}
+	viper.Set("direction", "all")
+	viper.Set("addons", 4)
+	if err := outfitCmd.RunE(outfitCmd, []string{"128"}); exitCode(err) != exitFailure {
+		t.Fatalf("bad addons error = %v, want exit code %d", err, exitFailure)
+	}
+	viper.Set("addons", 3)
+	if err := outfitCmd.RunE(outfitCmd, []string{"128"}); exitCode(err) != exitFailure {
+		t.Fatalf("unknown outfit error = %v, want exit code %d", err, exitFailure)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Fatalf("output directory created for a failed outfit: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/simivar/tibia-sprites-exporter/src/app"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	PackedOutputPath string
+)
+
+func init() {
+	rootCmd.AddCommand(packCmd)
+
+	packCmd.Flags().StringVar(&PackedOutputPath, "packedOutput", defaultPackedOutputPath(), "packed client assets output path")
+	bindFlags(packCmd)
+}
+
+var packCmd = &cobra.Command{
+	Use:   "pack",
+	Short: "Packs extracted sprite sheets back into client assets",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputDir := app.ExpandPath(viper.GetString("output"))
+		packedOutputDir := app.ExpandPath(viper.GetString(flagKey(cmd, "packedOutput")))
+
+		log.Info().
+			Str("output", outputDir).
+			Str("packedOutput", packedOutputDir).
+			Msg("Tibia Sprites pack running")
+
+		res, err := app.PackSprites(outputDir, packedOutputDir)
+
+		log.Info().Msg("Tibia Sprites pack finished")
+		return resultError(res, err)
+	},
+}
+
+func defaultPackedOutputPath() string {
+	return app.ExpandPath(
+		"./output/packed",
+	)
+}
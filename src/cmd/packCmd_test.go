@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestPackCommandPacksSheetsIntoFlagDirectory(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	sheetsDir := t.TempDir()
+	writeTestSprite(t, filepath.Join(sheetsDir, "Sprites-1-1-32x32.png"))
+	packedDir := filepath.Join(t.TempDir(), "packed")
+
+	viper.Set("output", sheetsDir)
+	bindFlags(packCmd)
+	if err := packCmd.Flags().Set("packedOutput", packedDir); err != nil {
+		t.Fatalf("set packedOutput flag: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = packCmd.Flags().Set("packedOutput", defaultPackedOutputPath())
+		packCmd.Flags().Lookup("packedOutput").Changed = false
+	})
+	if PackedOutputPath != packedDir {
+		t.Fatalf("PackedOutputPath = %q, want %q", PackedOutputPath, packedDir)
+	}
+
+	if err := packCmd.RunE(packCmd, nil); err != nil {
+		t.Fatalf("packCmd.RunE error: %v", err)
+	}
+	for _, name := range []string{"catalog-content.json", "sprites-1-1.bmp.lzma"} {
+		if _, err := os.Stat(filepath.Join(packedDir, name)); err != nil {
+			t.Fatalf("expected %s in the packed output: %v", name, err)
+		}
+	}
+}
+
+func TestPackCommandReportsFailedSheets(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	sheetsDir := t.TempDir()
+	writeTestSprite(t, filepath.Join(sheetsDir, "Sprites-1-1-32x32.png"))
+	if err := os.WriteFile(filepath.Join(sheetsDir, "Sprites-2-2-32x32.png"), []byte("not a png"), 0o644); err != nil {
+		t.Fatalf("write broken sheet: %v", err)
+	}
+
+	viper.Set("output", sheetsDir)
+	viper.Set("packedOutput", t.TempDir())
+
+	if err := packCmd.RunE(packCmd, nil); exitCode(err) != exitPartial {
+		t.Fatalf("packCmd.RunE error = %v, want exit code %d", err, exitPartial)
+	}
+}
+
+func TestPackCommandFailsWithoutSheets(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	viper.Set("output", t.TempDir())
+	viper.Set("packedOutput", t.TempDir())
+
+	if err := packCmd.RunE(packCmd, nil); exitCode(err) != exitFailure {
+		t.Fatalf("packCmd.RunE error = %v, want exit code %d", err, exitFailure)
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/simivar/tibia-sprites-exporter/src/app"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	PackFilePath             string
+	PackIndexIDs             string
+	PackIndexSplitOutputPath string
+)
+
+func init() {
+	rootCmd.AddCommand(packIndexCmd)
+
+	packIndexCmd.Flags().StringVar(&PackIndexSplitOutputPath, "splitOutput", defaultSplitOutputPath(), "split sprites output path")
+	packIndexCmd.Flags().StringVar(&PackFilePath, "packFile", defaultPackFilePath(), "sprite pack file to write")
+	packIndexCmd.Flags().StringVar(&PackIndexIDs, "ids", "", "sprite IDs to include, e.g. 100-200,305 (default all)")
+	bindFlags(packIndexCmd)
+}
+
+var packIndexCmd = &cobra.Command{
+	Use:   "pack-index",
+	Short: "Packs split sprites into one sprites.pack file indexed by sprite ID",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		splitOutput := app.ExpandPath(viper.GetString(flagKey(cmd, "splitOutput")))
+		packFile := app.ExpandPath(viper.GetString(flagKey(cmd, "packFile")))
+
+		ids, err := app.ParseIDRanges(viper.GetString(flagKey(cmd, "ids")))
+		if err != nil {
+			return resultError(app.Result{}, err)
+		}
+
+		log.Info().
+			Str("splitOutput", splitOutput).
+			Str("packFile", packFile).
+			Msg("Tibia Sprites pack-index running")
+
+		res, err := app.WriteSpritePack(splitOutput, packFile, ids)
+
+		log.Info().Msg("Tibia Sprites pack-index finished")
+		return resultError(res, err)
+	},
+}
+
+func defaultPackFilePath() string {
+	return app.ExpandPath(
+		"./output/sprites.pack",
+	)
+}
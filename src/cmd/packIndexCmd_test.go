@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/simivar/tibia-sprites-exporter/src/sprites"
+	"github.com/spf13/viper"
+)
+
+func TestPackIndexCommandWritesPack(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	splitDir := t.TempDir()
+	writeTestSprite(t, filepath.Join(splitDir, "7.png"))
+	writeTestSprite(t, filepath.Join(splitDir, "9.png"))
+	packFile := filepath.Join(t.TempDir(), "out", "sprites.pack")
+
+	viper.Set("splitOutput", splitDir)
+	viper.Set("packFile", packFile)
+	viper.Set("ids", "8-9")
+
+	if err := packIndexCmd.RunE(packIndexCmd, nil); err != nil {
+		t.Fatalf("packIndexCmd.RunE error: %v", err)
+	}
+
+	pack, err := sprites.OpenSpritePack(packFile)
+	if err != nil {
+		t.Fatalf("OpenSpritePack: %v", err)
+	}
+	defer pack.Close()
+	if first, last := pack.IDRange(); first != 9 || last != 9 {
+		t.Fatalf("IDRange = %d-%d, want 9-9", first, last)
+	}
+	if _, err := pack.Sprite(9); err != nil {
+		t.Fatalf("Sprite(9): %v", err)
+	}
+}
+
+func TestPackIndexCommandFailsWithoutSprites(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	viper.Set("splitOutput", t.TempDir())
+	viper.Set("packFile", filepath.Join(t.TempDir(), "sprites.pack"))
+
+	if err := packIndexCmd.RunE(packIndexCmd, nil); exitCode(err) != exitFailure {
+		t.Fatalf("packIndexCmd.RunE error = %v, want exit code %d", err, exitFailure)
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,6 +13,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/simivar/tibia-sprites-exporter/src/app"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -19,6 +21,9 @@ var (
 	CatalogContentJsonPath             string
 	CatalogContentJsonPathWithFilename string
 	OutputPath                         string
+	ImageFormat                        string
+	PNGCompression                     string
+	OptimizePNG                        bool
 
 	cfgFile           string
 	debugMode         bool
@@ -27,6 +32,9 @@ var (
 
 var rootCmd = &cobra.Command{
 	Short: "Tibia Sprites Exporter is set of tools for exporting Tibia sprites from the client",
+	// Execute prints command errors itself; usage would only bury them.
+	SilenceErrors: true,
+	SilenceUsage:  true,
 	Long: `Tibia Sprites Exporter is set of tools for exporting Tibia sprites from the client.
 			It is small, fast and cross-platform.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -47,12 +55,18 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&humanReadableLogs, "human", false, "enable human readable mode")
 	rootCmd.PersistentFlags().StringVarP(&CatalogContentJsonPath, "catalog", "c", defaultCatalogContentPath(), "path to the catalog.json file")
 	rootCmd.PersistentFlags().StringVarP(&OutputPath, "output", "o", defaultOutputPath(), "path where to save the extracted sprites")
+	rootCmd.PersistentFlags().StringVar(&ImageFormat, "format", "png", "image format of written sprites: png, bmp or tiff")
+	rootCmd.PersistentFlags().StringVar(&PNGCompression, "pngCompression", "default", "PNG compression level: default, none, fast or best")
+	rootCmd.PersistentFlags().BoolVar(&OptimizePNG, "optimize", false, "write indexed PNGs where lossless, best-compression RGBA otherwise")
 
 	// Bind persistent flags to Viper keys
 	_ = viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
 	_ = viper.BindPFlag("human", rootCmd.PersistentFlags().Lookup("human"))
 	_ = viper.BindPFlag("catalog", rootCmd.PersistentFlags().Lookup("catalog"))
 	_ = viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+	_ = viper.BindPFlag("format", rootCmd.PersistentFlags().Lookup("format"))
+	_ = viper.BindPFlag("pngCompression", rootCmd.PersistentFlags().Lookup("pngCompression"))
+	_ = viper.BindPFlag("optimize", rootCmd.PersistentFlags().Lookup("optimize"))
 }
 
 func initConfig() {
@@ -113,10 +127,230 @@ func initCatalogContentJsonPathWithFilename() {
 	}
 }
 
+// outputFromViper builds the image output selected by --format,
+// --pngCompression and --optimize.
+func outputFromViper() (app.Output, error) {
+	enc, err := app.NewImageEncoder(viper.GetString("format"), viper.GetString("pngCompression"))
+	if err != nil {
+		return app.Output{}, err
+	}
+	if viper.GetBool("optimize") {
+		if enc.Extension() != ".png" {
+			return app.Output{}, fmt.Errorf("--optimize only applies to PNG, not %s", viper.GetString("format"))
+		}
+		// Savings are measured against what --pngCompression alone writes,
+		// and the output is never larger than that.
+		baseline := enc.(app.PNGEncoder)
+		enc = &app.OptimizedPNGEncoder{Baseline: &baseline}
+	}
+	return app.Output{Encoder: enc}, nil
+}
+
+// logOutputSummary reports what --optimize saved, if it was used.
+func logOutputSummary(out app.Output) {
+	opt, ok := out.Encoder.(*app.OptimizedPNGEncoder)
+	if !ok {
+		return
+	}
+	stats := opt.Stats()
+	log.Info().
+		Int("images", stats.Images).
+		Int("paletted", stats.Paletted).
+		Int64("originalBytes", stats.OriginalBytes).
+		Int64("writtenBytes", stats.WrittenBytes).
+		Int64("bytesSaved", stats.Saved()).
+		Msgf("PNG optimization saved %d bytes", stats.Saved())
+}
+
+// withArchive runs write with out, or, when --archive is set, with out
+// streaming into that archive instead of the output directory. The archive is
+// removed again when write could not run at all.
+func withArchive(cmd *cobra.Command, out app.Output, write func(app.Output) (app.Result, error)) (app.Result, error) {
+	path := viper.GetString(flagKey(cmd, "archive"))
+	if path == "" {
+		return write(out)
+	}
+	path = app.ExpandPath(path)
+	archive, err := app.CreateArchive(path)
+	if err != nil {
+		return app.Result{}, err
+	}
+	log.Info().Str("archive", path).Msg("Writing output into archive")
+
+	out.Sink = archive
+	res, err := write(out)
+	if cerr := archive.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("close archive %s: %w", path, cerr)
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return res, err
+}
+
+// bindFlags binds every flag of cmd to the viper key "<command>.<flag>",
+// e.g. "export.workers", so commands sharing a flag name never read each
+// other's flags. Call it once all flags of cmd are defined.
+func bindFlags(cmd *cobra.Command) {
+	prefix := commandKey(cmd)
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		_ = viper.BindPFlag(prefix+"."+f.Name, f)
+	})
+}
+
+// commandKey is the path of cmd below the root joined by dots, e.g.
+// "appearances.dump".
+func commandKey(cmd *cobra.Command) string {
+	var names []string
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		names = append([]string{c.Name()}, names...)
+	}
+	return strings.Join(names, ".")
+}
+
+// flagKey is the viper key cmd reads its flag name from. A flag given on the
+// command line, or a "<command>.<flag>" setting, applies to cmd only; when
+// only the bare name is set, by the config file, a TSE_ variable or
+// viper.Set, that value applies to every command with the flag.
+func flagKey(cmd *cobra.Command, name string) string {
+	key := commandKey(cmd) + "." + name
+	if !viper.IsSet(key) && viper.IsSet(name) {
+		return name
+	}
+	return key
+}
+
+// addDedupFlag adds --dedup to a command that reads it through withDedup.
+func addDedupFlag(cmd *cobra.Command) {
+	cmd.Flags().String("dedup", "", "store identical sprites once: hardlink duplicates, or list them in duplicates.json with json")
+}
+
+// withDedup adds the deduplication selected by --dedup to out.
+func withDedup(cmd *cobra.Command, out app.Output) (app.Output, error) {
+	mode := viper.GetString(flagKey(cmd, "dedup"))
+	if mode == "" || mode == "off" {
+		return out, nil
+	}
+	d, err := app.NewDeduper(app.DedupMode(mode))
+	if err != nil {
+		return out, err
+	}
+	out.Dedup = d
+	return out, nil
+}
+
+// addLayoutFlags adds the name template flag nameFlag and --shard to a
+// command that reads them through layoutFromViper.
+func addLayoutFlags(cmd *cobra.Command, name *string, nameFlag, usage string) {
+	cmd.Flags().StringVar(name, nameFlag, "", usage)
+	cmd.Flags().String("shard", "", `directory template the output files are spread over, e.g. "{id/1000}"`)
+}
+
+// layoutFromViper parses the name template of nameKey and the --shard
+// template. Unset templates keep the built-in names.
+func layoutFromViper(cmd *cobra.Command, nameKey string) (app.Layout, error) {
+	var layout app.Layout
+	var err error
+	if s := viper.GetString(flagKey(cmd, nameKey)); s != "" {
+		if layout.Name, err = app.ParseNameTemplate(s); err != nil {
+			return layout, err
+		}
+	}
+	if s := viper.GetString(flagKey(cmd, "shard")); s != "" {
+		if layout.Shard, err = app.ParseNameTemplate(s); err != nil {
+			return layout, err
+		}
+	}
+	return layout, nil
+}
+
+// addGroupFlags adds --appearanceNames and --groupMode to a command that
+// writes groups through withGroupOptions.
+func addGroupFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("appearanceNames", false, "add appearance names to the group file names")
+	cmd.Flags().String("groupMode", string(app.GroupGrid), "group image arrangement: grid (one cell per sprite) or composite (patterns and layers assembled)")
+}
+
+// withGroupOptions adds the group naming and arrangement selected by
+// --appearanceNames and --groupMode to out.
+func withGroupOptions(cmd *cobra.Command, out app.Output) app.Output {
+	out.Layout.AppearanceNames = viper.GetBool(flagKey(cmd, "appearanceNames"))
+	out.GroupMode = app.GroupMode(viper.GetString(flagKey(cmd, "groupMode")))
+	return out
+}
+
+// addScaleFlags adds --scale and --scaleFilter to a command that reads them
+// through scalerFromViper.
+func addScaleFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("scale", 1, "enlarge sprites by this integer factor before encoding")
+	cmd.Flags().String("scaleFilter", "nearest", "upscaling filter: "+strings.Join(app.ScaleFilters, ", "))
+}
+
+// scalerFromViper returns the upscaling selected by --scale and
+// --scaleFilter, or nil when sprites keep their size.
+func scalerFromViper(cmd *cobra.Command) (*app.Scaler, error) {
+	factor := viper.GetInt(flagKey(cmd, "scale"))
+	if factor == 0 || factor == 1 {
+		return nil, nil
+	}
+	filter := viper.GetString(flagKey(cmd, "scaleFilter"))
+	if filter == "" {
+		filter = "nearest"
+	}
+	return app.NewScaler(filter, factor)
+}
+
+// addArchiveFlag adds --archive to a command that writes through withArchive.
+func addArchiveFlag(cmd *cobra.Command) {
+	cmd.Flags().String("archive", "", "write the output into this .zip or .tar.gz archive instead of the output directory")
+}
+
+// Exit codes returned by Execute.
+const (
+	exitFailure = 1 // the command could not run at all
+	exitPartial = 2 // the command ran but some items failed
+)
+
+// exitError carries a specific exit code out of a command.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// resultError maps the outcome of an app entry point to the command error:
+// a returned error means the command could not run, per-item failures mean it
+// only partially succeeded. Missing inputs alone are not an error.
+func resultError(res app.Result, err error) error {
+	if err != nil {
+		return &exitError{code: exitFailure, err: err}
+	}
+	if res.Failed > 0 {
+		err := fmt.Errorf("%d of %d items failed", res.Failed, res.Processed+res.Failed)
+		if first, ok := res.FirstFailure(); ok {
+			err = fmt.Errorf("%w: %w", err, first)
+		}
+		return &exitError{code: exitPartial, err: err}
+	}
+	return nil
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		code := exitFailure
+		var ee *exitError
+		if errors.As(err, &ee) {
+			code = ee.code
+		}
+		os.Exit(code)
 	}
 }
 
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
+	"image/png"
 	"io"
 	"os"
 	"path/filepath"
@@ -25,6 +27,26 @@ func preserveGlobals(t *testing.T) {
 	origHuman := humanReadableLogs
 	origSplit := SplitOutputPath
 	origGrouped := GroupedOutputPath
+	origWorkers := WorkersCount
+	origForce := ForceExtract
+	origPacked := PackedOutputPath
+	origSheets, origTiles, origGroups := ExportSheets, ExportTiles, ExportGroups
+	origAnimateCache, origExportCache := AnimateCacheSize, ExportCacheSize
+	origExportSplit, origExportGrouped, origExportWorkers := ExportSplitOutputPath, ExportGroupedOutputPath, ExportWorkersCount
+	origGroupSplit, origAtlasSplit := GroupSplitOutputPath, AtlasSplitOutputPath
+	origPackIndexSplit, origDumpSplit := PackIndexSplitOutputPath, DumpSplitOutputPath
+	origAtlasOutput, origAtlasMaxSize, origAtlasPadding := AtlasOutputPath, AtlasMaxSize, AtlasPadding
+	origAtlasTrim, origAtlasIDs := AtlasTrim, AtlasIDs
+	origAnimated, origFormat, origAnimateIDs := AnimatedOutputPath, AnimationFormat, AnimateIDs
+	origFormatName, origPNGCompression := ImageFormat, PNGCompression
+	origOptimize, origEmptyTiles := OptimizePNG, EmptyTilesPolicy
+	origPackFile, origPackIndexIDs := PackFilePath, PackIndexIDs
+	origSheetName := SheetNameTemplate
+	origSplitName, origGroupName := SplitNameTemplate, GroupNameTemplate
+	origDumpOutput, origDumpFormat := DumpOutputPath, DumpFormat
+	origOutfitOutput, origOutfitDirection := OutfitOutputPath, OutfitDirection
+	origOutfitColors := [4]int{OutfitHead, OutfitBody, OutfitLegs, OutfitFeet}
+	origOutfitAddons, origOutfitMount, origOutfitStrip := OutfitAddons, OutfitMount, OutfitStrip
 	origLogger := log.Logger
 	origLevel := zerolog.GlobalLevel()
 
@@ -37,6 +59,26 @@ func preserveGlobals(t *testing.T) {
 		humanReadableLogs = origHuman
 		SplitOutputPath = origSplit
 		GroupedOutputPath = origGrouped
+		WorkersCount = origWorkers
+		ForceExtract = origForce
+		PackedOutputPath = origPacked
+		ExportSheets, ExportTiles, ExportGroups = origSheets, origTiles, origGroups
+		AnimateCacheSize, ExportCacheSize = origAnimateCache, origExportCache
+		ExportSplitOutputPath, ExportGroupedOutputPath, ExportWorkersCount = origExportSplit, origExportGrouped, origExportWorkers
+		GroupSplitOutputPath, AtlasSplitOutputPath = origGroupSplit, origAtlasSplit
+		PackIndexSplitOutputPath, DumpSplitOutputPath = origPackIndexSplit, origDumpSplit
+		AtlasOutputPath, AtlasMaxSize, AtlasPadding = origAtlasOutput, origAtlasMaxSize, origAtlasPadding
+		AtlasTrim, AtlasIDs = origAtlasTrim, origAtlasIDs
+		AnimatedOutputPath, AnimationFormat, AnimateIDs = origAnimated, origFormat, origAnimateIDs
+		ImageFormat, PNGCompression = origFormatName, origPNGCompression
+		OptimizePNG, EmptyTilesPolicy = origOptimize, origEmptyTiles
+		PackFilePath, PackIndexIDs = origPackFile, origPackIndexIDs
+		SheetNameTemplate = origSheetName
+		SplitNameTemplate, GroupNameTemplate = origSplitName, origGroupName
+		DumpOutputPath, DumpFormat = origDumpOutput, origDumpFormat
+		OutfitOutputPath, OutfitDirection = origOutfitOutput, origOutfitDirection
+		OutfitHead, OutfitBody, OutfitLegs, OutfitFeet = origOutfitColors[0], origOutfitColors[1], origOutfitColors[2], origOutfitColors[3]
+		OutfitAddons, OutfitMount, OutfitStrip = origOutfitAddons, origOutfitMount, origOutfitStrip
 		log.Logger = origLogger
 		zerolog.SetGlobalLevel(origLevel)
 	})
@@ -237,7 +279,10 @@ func TestSplitCommandRun(t *testing.T) {
 	viper.Set("output", extractDir)
 	viper.Set("splitOutput", splitDir)
 
-	splitCmd.Run(splitCmd, nil)
+	err := splitCmd.RunE(splitCmd, nil)
+	if !errors.Is(err, app.ErrNoSprites) || exitCode(err) != exitFailure {
+		t.Fatalf("splitCmd.RunE error = %v, want ErrNoSprites with exit code %d", err, exitFailure)
+	}
 
 	logs := buf.String()
 	if !strings.Contains(logs, "Tibia Sprites Split running") {
@@ -270,7 +315,9 @@ func TestExtractCommandRun(t *testing.T) {
 	viper.Set("catalog", catalogDir)
 	viper.Set("output", outputDir)
 
-	extractCmd.Run(extractCmd, nil)
+	if err := extractCmd.RunE(extractCmd, nil); err != nil {
+		t.Fatalf("extractCmd.RunE error: %v", err)
+	}
 
 	if CatalogContentJsonPath != app.ExpandPath(catalogDir) {
 		t.Fatalf("CatalogContentJsonPath = %q, want %q", CatalogContentJsonPath, app.ExpandPath(catalogDir))
@@ -319,7 +366,9 @@ func TestGroupCommandRun(t *testing.T) {
 	viper.Set("splitOutput", splitDir)
 	viper.Set("groupedOutput", groupedDir)
 
-	groupCmd.Run(groupCmd, nil)
+	if err := groupCmd.RunE(groupCmd, nil); err != nil {
+		t.Fatalf("groupCmd.RunE error: %v", err)
+	}
 
 	if _, err := os.Stat(groupedDir); err != nil {
 		t.Fatalf("expected grouped output directory: %v", err)
@@ -336,3 +385,142 @@ func TestGroupCommandRun(t *testing.T) {
 		t.Fatalf("expected finish log, got %q", logs)
 	}
 }
+
+func TestPackCommandRun(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	buf := captureLogs(t)
+
+	tempDir := t.TempDir()
+	extractDir := filepath.Join(tempDir, "extract")
+	packedDir := filepath.Join(tempDir, "packed")
+	if err := os.MkdirAll(extractDir, 0o755); err != nil {
+		t.Fatalf("mkdir extractDir: %v", err)
+	}
+
+	viper.Set("output", extractDir)
+	viper.Set("packedOutput", packedDir)
+
+	err := packCmd.RunE(packCmd, nil)
+	if !errors.Is(err, app.ErrNoSprites) || exitCode(err) != exitFailure {
+		t.Fatalf("packCmd.RunE error = %v, want ErrNoSprites with exit code %d", err, exitFailure)
+	}
+
+	logs := buf.String()
+	if !strings.Contains(logs, "\"packedOutput\":\""+packedDir+"\"") {
+		t.Fatalf("expected packedOutput in log, got %q", logs)
+	}
+	if !strings.Contains(logs, "Tibia Sprites pack finished") {
+		t.Fatalf("expected finish log, got %q", logs)
+	}
+}
+
+func exitCode(err error) int {
+	var ee *exitError
+	if errors.As(err, &ee) {
+		return ee.code
+	}
+	return 0
+}
+
+func TestResultErrorExitCodes(t *testing.T) {
+	boom := errors.New("boom")
+	failed := app.Result{Processed: 3, Failed: 1, Errors: []app.ItemError{{Item: "a.png", Err: boom}}}
+
+	tests := []struct {
+		name string
+		res  app.Result
+		err  error
+		want int
+	}{
+		{name: "success", res: app.Result{Processed: 3}, want: 0},
+		{name: "missing only", res: app.Result{Processed: 3, Missing: 2}, want: 0},
+		{name: "item failures", res: failed, want: exitPartial},
+		{name: "run error", err: boom, want: exitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := resultError(tt.res, tt.err)
+			if got := exitCode(err); got != tt.want {
+				t.Fatalf("exit code = %d, want %d (err %v)", got, tt.want, err)
+			}
+			if tt.want != 0 && !errors.Is(err, boom) {
+				t.Fatalf("error %v does not wrap the cause", err)
+			}
+		})
+	}
+}
+
+func TestResultErrorNamesTheFirstFailedItem(t *testing.T) {
+	boom := errors.New("boom")
+	gone := errors.New("gone")
+	res := app.Result{Processed: 3, Failed: 1, Missing: 1, Errors: []app.ItemError{
+		{Item: "missing.png", Err: gone, Missing: true},
+		{Item: "broken.png", Err: boom},
+	}}
+
+	err := resultError(res, nil)
+	if exitCode(err) != exitPartial || !errors.Is(err, boom) || errors.Is(err, gone) {
+		t.Fatalf("resultError = %v, want exit %d naming broken.png", err, exitPartial)
+	}
+	if !strings.Contains(err.Error(), "broken.png") || strings.Contains(err.Error(), "missing.png") {
+		t.Fatalf("message = %q, want only the failed item", err)
+	}
+}
+
+func TestOutputFromViper(t *testing.T) {
+	resetViper(t)
+
+	out, err := outputFromViper()
+	if err != nil {
+		t.Fatalf("outputFromViper: %v", err)
+	}
+	if out.Ext() != ".png" {
+		t.Fatalf("default Ext() = %q, want .png", out.Ext())
+	}
+
+	viper.Set("format", "tiff")
+	if out, err = outputFromViper(); err != nil || out.Ext() != ".tiff" {
+		t.Fatalf("outputFromViper tiff = %q, %v", out.Ext(), err)
+	}
+
+	viper.Set("optimize", true)
+	if _, err := outputFromViper(); err == nil {
+		t.Fatalf("expected --optimize to be rejected for tiff")
+	}
+
+	viper.Set("format", "png")
+	viper.Set("pngCompression", "fast")
+	out, err = outputFromViper()
+	if err != nil {
+		t.Fatalf("outputFromViper optimize: %v", err)
+	}
+	opt, ok := out.Encoder.(*app.OptimizedPNGEncoder)
+	if !ok {
+		t.Fatalf("encoder = %T, want *app.OptimizedPNGEncoder", out.Encoder)
+	}
+	if opt.Baseline == nil || opt.Baseline.CompressionLevel != png.BestSpeed {
+		t.Fatalf("baseline = %+v, want the --pngCompression fast encoder", opt.Baseline)
+	}
+}
+
+func TestUnknownFormatFailsCommands(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	viper.Set("output", t.TempDir())
+	viper.Set("splitOutput", t.TempDir())
+	viper.Set("format", "jpeg")
+
+	err := splitCmd.RunE(splitCmd, nil)
+	if exitCode(err) != exitFailure || !strings.Contains(err.Error(), "jpeg") {
+		t.Fatalf("splitCmd.RunE error = %v, want unknown format failure", err)
+	}
+
+	viper.Set("format", "png")
+	viper.Set("pngCompression", "ultra")
+	if err := splitCmd.RunE(splitCmd, nil); exitCode(err) != exitFailure {
+		t.Fatalf("splitCmd.RunE error = %v, want unknown compression failure", err)
+	}
+}
@@ -8,31 +8,58 @@ import (
 )
 
 var (
-	SplitOutputPath string
+	SplitOutputPath   string
+	EmptyTilesPolicy  string
+	SplitNameTemplate string
 )
 
 func init() {
 	rootCmd.AddCommand(splitCmd)
 
 	splitCmd.Flags().StringVar(&SplitOutputPath, "splitOutput", defaultSplitOutputPath(), "split sprites output path")
-	_ = viper.BindPFlag("splitOutput", splitCmd.Flags().Lookup("splitOutput"))
+	splitCmd.Flags().StringVar(&EmptyTilesPolicy, "emptyTiles", string(app.EmptyTilesWrite), "fully transparent sprites: write, skip, or report (write and list them in empty.json)")
+	addArchiveFlag(splitCmd)
+	addDedupFlag(splitCmd)
+	addLayoutFlags(splitCmd, &SplitNameTemplate, "splitName", `sprite file name template without extension, e.g. "{id:06}"`)
+	addScaleFlags(splitCmd)
+	bindFlags(splitCmd)
 }
 
 var splitCmd = &cobra.Command{
 	Use:   "split",
 	Short: "Splits extracted sprites into separate files",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		outputDir := app.ExpandPath(viper.GetString("output"))
-		splitOutputDir := app.ExpandPath(viper.GetString("splitOutput"))
+		splitOutputDir := app.ExpandPath(viper.GetString(flagKey(cmd, "splitOutput")))
+		out, err := outputFromViper()
+		if err == nil {
+			out, err = withDedup(cmd, out)
+		}
+		if err == nil {
+			out.EmptyTiles, err = emptyTilesFromViper(cmd)
+		}
+		if err == nil {
+			out.Layout, err = layoutFromViper(cmd, "splitName")
+		}
+		if err == nil {
+			out.Scaler, err = scalerFromViper(cmd)
+		}
+		if err != nil {
+			return resultError(app.Result{}, err)
+		}
 
 		log.Info().
 			Str("output", outputDir).
 			Str("splitOutput", splitOutputDir).
 			Msg("Tibia Sprites Split running")
 
-		app.SplitSprites(outputDir, splitOutputDir)
+		res, err := withArchive(cmd, out, func(out app.Output) (app.Result, error) {
+			return app.SplitSprites(outputDir, splitOutputDir, out)
+		})
 
+		logOutputSummary(out)
 		log.Info().Msg("Tibia Sprites Split finished")
+		return resultError(res, err)
 	},
 }
 
@@ -41,3 +68,13 @@ func defaultSplitOutputPath() string {
 		"./output/split",
 	)
 }
+
+// emptyTilesFromViper returns the empty tile handling selected by
+// --emptyTiles; unset means write.
+func emptyTilesFromViper(cmd *cobra.Command) (*app.EmptyTiles, error) {
+	policy := app.EmptyTilePolicy(viper.GetString(flagKey(cmd, "emptyTiles")))
+	if policy == "" {
+		policy = app.EmptyTilesWrite
+	}
+	return app.NewEmptyTiles(policy)
+}
@@ -1,6 +1,10 @@
 package cmd
 
 import (
+	"archive/zip"
+	"errors"
+	"image"
+	"image/png"
 	"os"
 	"path/filepath"
 	"strings"
@@ -34,7 +38,9 @@ func TestSplitCommandLogsExpandedPaths(t *testing.T) {
 	viper.Set("output", extractedRel)
 	viper.Set("splitOutput", splitRel)
 
-	splitCmd.Run(splitCmd, nil)
+	if err := splitCmd.RunE(splitCmd, nil); !errors.Is(err, app.ErrNoSprites) {
+		t.Fatalf("splitCmd.RunE error = %v, want ErrNoSprites", err)
+	}
 
 	logs := buf.String()
 	wantOutput := app.ExpandPath(extractedRel)
@@ -78,6 +84,184 @@ func TestSplitOutputFlagUpdatesGlobalAndViper(t *testing.T) {
 	}
 }
 
+func TestSplitCommandWritesArchive(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	extracted := t.TempDir()
+	writeTestSprite(t, filepath.Join(extracted, "Sprites-7-7-32x32.png"))
+	split := filepath.Join(t.TempDir(), "split")
+	archive := filepath.Join(t.TempDir(), "split.zip")
+
+	viper.Set("output", extracted)
+	viper.Set("splitOutput", split)
+	viper.Set("archive", archive)
+
+	if err := splitCmd.RunE(splitCmd, nil); err != nil {
+		t.Fatalf("splitCmd.RunE error = %v", err)
+	}
+
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer zr.Close()
+	if len(zr.File) != 1 || zr.File[0].Name != "7.png" {
+		t.Fatalf("archive holds %d files, want only 7.png", len(zr.File))
+	}
+	if _, err := os.Stat(split); !os.IsNotExist(err) {
+		t.Fatalf("split directory should not be created, stat error = %v", err)
+	}
+}
+
+func TestSplitCommandRemovesArchiveWhenItCannotRun(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	archive := filepath.Join(t.TempDir(), "split.tar.gz")
+	viper.Set("output", t.TempDir())
+	viper.Set("splitOutput", t.TempDir())
+	viper.Set("archive", archive)
+
+	if err := splitCmd.RunE(splitCmd, nil); !errors.Is(err, app.ErrNoSprites) {
+		t.Fatalf("splitCmd.RunE error = %v, want ErrNoSprites", err)
+	}
+	if _, err := os.Stat(archive); !os.IsNotExist(err) {
+		t.Fatalf("archive should be removed, stat error = %v", err)
+	}
+}
+
+func TestSplitCommandDedup(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	extracted := t.TempDir()
+	writeTestSprite(t, filepath.Join(extracted, "Sprites-7-7-32x32.png"))
+	split := t.TempDir()
+	viper.Set("output", extracted)
+	viper.Set("splitOutput", split)
+
+	viper.Set("dedup", "json")
+	if err := splitCmd.RunE(splitCmd, nil); err != nil {
+		t.Fatalf("splitCmd.RunE error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(split, "duplicates.json")); err != nil {
+		t.Fatalf("expected duplicates.json: %v", err)
+	}
+
+	viper.Set("dedup", "copy")
+	if err := splitCmd.RunE(splitCmd, nil); exitCode(err) != exitFailure {
+		t.Fatalf("unknown dedup mode error = %v, want exit code %d", err, exitFailure)
+	}
+
+	viper.Set("dedup", "hardlink")
+	viper.Set("archive", filepath.Join(t.TempDir(), "split.zip"))
+	if err := splitCmd.RunE(splitCmd, nil); exitCode(err) != exitFailure {
+		t.Fatalf("hardlink into archive error = %v, want exit code %d", err, exitFailure)
+	}
+}
+
+func TestSplitCommandEmptyTiles(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	extracted := t.TempDir()
+	writeTestSprite(t, filepath.Join(extracted, "Sprites-7-7-32x32.png"))
+	blank, err := os.Create(filepath.Join(extracted, "Sprites-8-8-32x32.png"))
+	if err != nil {
+		t.Fatalf("create blank sheet: %v", err)
+	}
+	if err := png.Encode(blank, image.NewNRGBA(image.Rect(0, 0, 32, 32))); err != nil {
+		t.Fatalf("encode blank sheet: %v", err)
+	}
+	blank.Close()
+	split := t.TempDir()
+	viper.Set("output", extracted)
+	viper.Set("splitOutput", split)
+
+	viper.Set("emptyTiles", "skip")
+	if err := splitCmd.RunE(splitCmd, nil); err != nil {
+		t.Fatalf("splitCmd.RunE error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(split, "8.png")); !os.IsNotExist(err) {
+		t.Fatalf("empty sprite 8 should be skipped, stat error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(split, "7.png")); err != nil {
+		t.Fatalf("sprite 7 should be written: %v", err)
+	}
+
+	viper.Set("emptyTiles", "drop")
+	if err := splitCmd.RunE(splitCmd, nil); exitCode(err) != exitFailure {
+		t.Fatalf("unknown policy error = %v, want exit code %d", err, exitFailure)
+	}
+}
+
+func TestSplitCommandNameTemplates(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	extracted := t.TempDir()
+	writeTestSprite(t, filepath.Join(extracted, "Sprites-1234-1234-32x32.png"))
+	split := t.TempDir()
+	viper.Set("output", extracted)
+	viper.Set("splitOutput", split)
+	viper.Set("splitName", "{id:06}")
+	viper.Set("shard", "{id/1000}")
+
+	if err := splitCmd.RunE(splitCmd, nil); err != nil {
+		t.Fatalf("splitCmd.RunE error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(split, "1", "001234.png")); err != nil {
+		t.Fatalf("expected sharded sprite: %v", err)
+	}
+
+	viper.Set("shard", "{appearance}")
+	if err := splitCmd.RunE(splitCmd, nil); exitCode(err) != exitFailure {
+		t.Fatalf("unknown placeholder error = %v, want exit code %d", err, exitFailure)
+	}
+	viper.Set("shard", "{id")
+	if err := splitCmd.RunE(splitCmd, nil); exitCode(err) != exitFailure {
+		t.Fatalf("invalid template error = %v, want exit code %d", err, exitFailure)
+	}
+}
+
 func ensureDir(path string) error {
 	return os.MkdirAll(path, 0o755)
 }
+
+func TestSplitCommandScale(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	extracted := t.TempDir()
+	writeTestSprite(t, filepath.Join(extracted, "Sprites-1-1-32x32.png"))
+	split := t.TempDir()
+	viper.Set("output", extracted)
+	viper.Set("splitOutput", split)
+	viper.Set("scale", 2)
+	viper.Set("scaleFilter", "epx")
+
+	if err := splitCmd.RunE(splitCmd, nil); err != nil {
+		t.Fatalf("splitCmd.RunE error = %v", err)
+	}
+	f, err := os.Open(filepath.Join(split, "1.png"))
+	if err != nil {
+		t.Fatalf("open sprite: %v", err)
+	}
+	defer f.Close()
+	cfg, err := png.DecodeConfig(f)
+	if err != nil || cfg.Width != 64 || cfg.Height != 64 {
+		t.Fatalf("sprite is %dx%d (%v), want 64x64", cfg.Width, cfg.Height, err)
+	}
+
+	viper.Set("scaleFilter", "bicubic")
+	if err := splitCmd.RunE(splitCmd, nil); exitCode(err) != exitFailure {
+		t.Fatalf("unknown filter error = %v, want exit code %d", err, exitFailure)
+	}
+}
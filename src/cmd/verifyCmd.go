@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/simivar/tibia-sprites-exporter/src/app"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verifies every sprite asset referenced by the catalog",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.Info().Msg("Tibia Sprites verify running")
+
+		catalogDir := app.ExpandPath(viper.GetString("catalog"))
+		catalogFile := filepath.Join(catalogDir, "catalog-content.json")
+
+		reports, err := app.VerifyAssets(catalogDir, catalogFile)
+		if err != nil {
+			return &exitError{code: exitFailure, err: err}
+		}
+
+		out := cmd.OutOrStdout()
+		failed := 0
+		for _, r := range reports {
+			if r.OK() {
+				fmt.Fprintf(out, "OK    %s (%d-%d, %s)\n", r.Elem.File, r.Elem.FirstSpriteId, r.Elem.LastSpriteId, r.Elem.SpriteType)
+				continue
+			}
+			failed++
+			fmt.Fprintf(out, "FAIL  %s (%d-%d, %s): %s\n", r.Elem.File, r.Elem.FirstSpriteId, r.Elem.LastSpriteId, r.Elem.SpriteType, strings.Join(r.Problems, "; "))
+		}
+		fmt.Fprintf(out, "%d assets checked, %d with problems\n", len(reports), failed)
+
+		log.Info().Msg("Tibia Sprites verify finished")
+
+		if failed > 0 {
+			return &exitError{code: exitPartial, err: fmt.Errorf("%d of %d assets failed verification", failed, len(reports))}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestVerifyCommandFailsWhenAssetsAreMissing(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	catalogDir := t.TempDir()
+	catalog := `[{"type":"sprite","file":"missing.bin","spritetype":0,"firstspriteid":1,"lastspriteid":2}]`
+	if err := os.WriteFile(filepath.Join(catalogDir, "catalog-content.json"), []byte(catalog), 0o644); err != nil {
+		t.Fatalf("write catalog-content.json: %v", err)
+	}
+	viper.Set("catalog", catalogDir)
+
+	var out bytes.Buffer
+	verifyCmd.SetOut(&out)
+	t.Cleanup(func() { verifyCmd.SetOut(nil) })
+
+	err := verifyCmd.RunE(verifyCmd, nil)
+	if err == nil {
+		t.Fatalf("expected error when assets are missing")
+	}
+
+	report := out.String()
+	if !strings.Contains(report, "FAIL  missing.bin (1-2, 32x32): file does not exist") {
+		t.Fatalf("report missing failure line: %q", report)
+	}
+	if !strings.Contains(report, "1 assets checked, 1 with problems") {
+		t.Fatalf("report missing summary: %q", report)
+	}
+}
+
+func TestVerifyCommandSucceedsForEmptyCatalog(t *testing.T) {
+	preserveGlobals(t)
+	resetViper(t)
+	captureLogs(t)
+
+	catalogDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(catalogDir, "catalog-content.json"), []byte("[]"), 0o644); err != nil {
+		t.Fatalf("write catalog-content.json: %v", err)
+	}
+	viper.Set("catalog", catalogDir)
+
+	var out bytes.Buffer
+	verifyCmd.SetOut(&out)
+	t.Cleanup(func() { verifyCmd.SetOut(nil) })
+
+	if err := verifyCmd.RunE(verifyCmd, nil); err != nil {
+		t.Fatalf("verify returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "0 assets checked, 0 with problems") {
+		t.Fatalf("unexpected report: %q", out.String())
+	}
+}
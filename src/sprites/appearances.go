@@ -0,0 +1,794 @@
+package sprites
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Appearances mirrors the client's appearances.proto "Appearances" message.
+type Appearances struct {
+	Objects  []Appearance `json:"objects,omitempty" yaml:"objects,omitempty"`
+	Outfits  []Appearance `json:"outfits,omitempty" yaml:"outfits,omitempty"`
+	Effects  []Appearance `json:"effects,omitempty" yaml:"effects,omitempty"`
+	Missiles []Appearance `json:"missiles,omitempty" yaml:"missiles,omitempty"`
+}
+
+// AppearanceCategory is one of the four appearance lists of the client.
+type AppearanceCategory int
+
+const (
+	CategoryObject AppearanceCategory = iota
+	CategoryOutfit
+	CategoryEffect
+	CategoryMissile
+)
+
+// AppearanceCategories lists the categories in file order.
+var AppearanceCategories = []AppearanceCategory{CategoryObject, CategoryOutfit, CategoryEffect, CategoryMissile}
+
+func (c AppearanceCategory) String() string {
+	switch c {
+	case CategoryObject:
+		return "object"
+	case CategoryOutfit:
+		return "outfit"
+	case CategoryEffect:
+		return "effect"
+	case CategoryMissile:
+		return "missile"
+	}
+	return fmt.Sprintf("category(%d)", int(c))
+}
+
+// List returns the appearances of category c.
+func (a *Appearances) List(c AppearanceCategory) []Appearance {
+	switch c {
+	case CategoryObject:
+		return a.Objects
+	case CategoryOutfit:
+		return a.Outfits
+	case CategoryEffect:
+		return a.Effects
+	case CategoryMissile:
+		return a.Missiles
+	}
+	return nil
+}
+
+type Appearance struct {
+	ID          int             `json:"id" yaml:"id"`
+	FrameGroups []FrameGroup    `json:"frameGroups,omitempty" yaml:"frameGroups,omitempty"`
+	Flags       AppearanceFlags `json:"flags" yaml:"flags"`
+	Name        string          `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string          `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// FixedFrameGroup tells which state of an appearance a frame group renders.
+type FixedFrameGroup int
+
+const (
+	FrameGroupOutfitIdle FixedFrameGroup = iota
+	FrameGroupOutfitMoving
+	FrameGroupObjectInitial
+)
+
+func (g FixedFrameGroup) String() string {
+	switch g {
+	case FrameGroupOutfitIdle:
+		return "idle"
+	case FrameGroupOutfitMoving:
+		return "moving"
+	case FrameGroupObjectInitial:
+		return "initial"
+	}
+	return fmt.Sprintf("framegroup(%d)", int(g))
+}
+
+type FrameGroup struct {
+	FixedFrameGroup FixedFrameGroup `json:"fixedFrameGroup" yaml:"fixedFrameGroup"`
+	ID              int             `json:"id" yaml:"id"`
+	SpriteInfo      SpriteInfo      `json:"spriteInfo" yaml:"spriteInfo"`
+}
+
+type SpriteInfo struct {
+	PatternWidth   int              `json:"patternWidth" yaml:"patternWidth"`
+	PatternHeight  int              `json:"patternHeight" yaml:"patternHeight"`
+	PatternDepth   int              `json:"patternDepth" yaml:"patternDepth"`
+	Layers         int              `json:"layers" yaml:"layers"`
+	SpriteIDs      []int            `json:"spriteIds,omitempty" yaml:"spriteIds,omitempty,flow"`
+	Animation      *SpriteAnimation `json:"animation,omitempty" yaml:"animation,omitempty"`
+	BoundingSquare int              `json:"boundingSquare" yaml:"boundingSquare"`
+	IsOpaque       bool             `json:"isOpaque,omitempty" yaml:"isOpaque,omitempty"`
+	BoundingBoxes  []Box            `json:"boundingBoxes,omitempty" yaml:"boundingBoxes,omitempty"`
+}
+
+// Phases is the number of animation phases; static sprites have one.
+func (s SpriteInfo) Phases() int {
+	if s.Animation == nil || len(s.Animation.Phases) == 0 {
+		return 1
+	}
+	return len(s.Animation.Phases)
+}
+
+// LayerCount is the number of layers, at least 1 whatever the file says.
+func (s SpriteInfo) LayerCount() int { return max(s.Layers, 1) }
+
+// PatternsX, PatternsY and PatternsZ are the pattern counts, at least 1
+// whatever the file says.
+func (s SpriteInfo) PatternsX() int { return max(s.PatternWidth, 1) }
+func (s SpriteInfo) PatternsY() int { return max(s.PatternHeight, 1) }
+func (s SpriteInfo) PatternsZ() int { return max(s.PatternDepth, 1) }
+
+// FrameSprites is the number of sprites making up one animation phase.
+func (s SpriteInfo) FrameSprites() int {
+	return s.PatternsZ() * s.PatternsY() * s.PatternsX() * s.LayerCount()
+}
+
+// SpriteCount is the number of sprites the sprite info should list.
+func (s SpriteInfo) SpriteCount() int {
+	return s.Phases() * s.FrameSprites()
+}
+
+// SpriteIndex is the position in SpriteIDs of one sprite, following the
+// client's ordering: layers vary fastest, then x, y and z patterns, then
+// animation phases.
+func (s SpriteInfo) SpriteIndex(phase, z, y, x, layer int) int {
+	return (((phase*s.PatternsZ()+z)*s.PatternsY()+y)*s.PatternsX()+x)*s.LayerCount() + layer
+}
+
+// AnimationLoopType follows the client enum: -1 ping-pong, 0 infinite,
+// 1 counted (see SpriteAnimation.LoopCount).
+type AnimationLoopType int
+
+const (
+	AnimationLoopPingPong AnimationLoopType = -1
+	AnimationLoopInfinite AnimationLoopType = 0
+	AnimationLoopCounted  AnimationLoopType = 1
+)
+
+type SpriteAnimation struct {
+	DefaultStartPhase int               `json:"defaultStartPhase" yaml:"defaultStartPhase"`
+	Synchronized      bool              `json:"synchronized,omitempty" yaml:"synchronized,omitempty"`
+	RandomStartPhase  bool              `json:"randomStartPhase,omitempty" yaml:"randomStartPhase,omitempty"`
+	LoopType          AnimationLoopType `json:"loopType" yaml:"loopType"`
+	LoopCount         int               `json:"loopCount" yaml:"loopCount"`
+	Phases            []SpritePhase     `json:"phases,omitempty" yaml:"phases,omitempty"`
+}
+
+// SpritePhase durations are in milliseconds.
+type SpritePhase struct {
+	DurationMin int `json:"durationMin" yaml:"durationMin"`
+	DurationMax int `json:"durationMax" yaml:"durationMax"`
+}
+
+type Box struct {
+	X      int `json:"x" yaml:"x"`
+	Y      int `json:"y" yaml:"y"`
+	Width  int `json:"width" yaml:"width"`
+	Height int `json:"height" yaml:"height"`
+}
+
+type AppearanceFlags struct {
+	Bank                  *FlagBank                  `json:"bank,omitempty" yaml:"bank,omitempty"`
+	Clip                  bool                       `json:"clip,omitempty" yaml:"clip,omitempty"`
+	Bottom                bool                       `json:"bottom,omitempty" yaml:"bottom,omitempty"`
+	Top                   bool                       `json:"top,omitempty" yaml:"top,omitempty"`
+	Container             bool                       `json:"container,omitempty" yaml:"container,omitempty"`
+	Cumulative            bool                       `json:"cumulative,omitempty" yaml:"cumulative,omitempty"`
+	Usable                bool                       `json:"usable,omitempty" yaml:"usable,omitempty"`
+	ForceUse              bool                       `json:"forceUse,omitempty" yaml:"forceUse,omitempty"`
+	MultiUse              bool                       `json:"multiUse,omitempty" yaml:"multiUse,omitempty"`
+	Write                 *FlagWrite                 `json:"write,omitempty" yaml:"write,omitempty"`
+	WriteOnce             *FlagWriteOnce             `json:"writeOnce,omitempty" yaml:"writeOnce,omitempty"`
+	LiquidPool            bool                       `json:"liquidPool,omitempty" yaml:"liquidPool,omitempty"`
+	Unpass                bool                       `json:"unpass,omitempty" yaml:"unpass,omitempty"`
+	Unmove                bool                       `json:"unmove,omitempty" yaml:"unmove,omitempty"`
+	Unsight               bool                       `json:"unsight,omitempty" yaml:"unsight,omitempty"`
+	Avoid                 bool                       `json:"avoid,omitempty" yaml:"avoid,omitempty"`
+	NoMovementAnimation   bool                       `json:"noMovementAnimation,omitempty" yaml:"noMovementAnimation,omitempty"`
+	Take                  bool                       `json:"take,omitempty" yaml:"take,omitempty"`
+	LiquidContainer       bool                       `json:"liquidContainer,omitempty" yaml:"liquidContainer,omitempty"`
+	Hang                  bool                       `json:"hang,omitempty" yaml:"hang,omitempty"`
+	Hook                  *FlagHook                  `json:"hook,omitempty" yaml:"hook,omitempty"`
+	Rotate                bool                       `json:"rotate,omitempty" yaml:"rotate,omitempty"`
+	Light                 *FlagLight                 `json:"light,omitempty" yaml:"light,omitempty"`
+	DontHide              bool                       `json:"dontHide,omitempty" yaml:"dontHide,omitempty"`
+	Translucent           bool                       `json:"translucent,omitempty" yaml:"translucent,omitempty"`
+	Shift                 *FlagShift                 `json:"shift,omitempty" yaml:"shift,omitempty"`
+	Height                *FlagHeight                `json:"height,omitempty" yaml:"height,omitempty"`
+	LyingObject           bool                       `json:"lyingObject,omitempty" yaml:"lyingObject,omitempty"`
+	AnimateAlways         bool                       `json:"animateAlways,omitempty" yaml:"animateAlways,omitempty"`
+	Automap               *FlagAutomap               `json:"automap,omitempty" yaml:"automap,omitempty"`
+	LensHelp              *FlagLensHelp              `json:"lensHelp,omitempty" yaml:"lensHelp,omitempty"`
+	FullBank              bool                       `json:"fullBank,omitempty" yaml:"fullBank,omitempty"`
+	IgnoreLook            bool                       `json:"ignoreLook,omitempty" yaml:"ignoreLook,omitempty"`
+	Clothes               *FlagClothes               `json:"clothes,omitempty" yaml:"clothes,omitempty"`
+	DefaultAction         *FlagDefaultAction         `json:"defaultAction,omitempty" yaml:"defaultAction,omitempty"`
+	Market                *FlagMarket                `json:"market,omitempty" yaml:"market,omitempty"`
+	Wrap                  bool                       `json:"wrap,omitempty" yaml:"wrap,omitempty"`
+	Unwrap                bool                       `json:"unwrap,omitempty" yaml:"unwrap,omitempty"`
+	TopEffect             bool                       `json:"topEffect,omitempty" yaml:"topEffect,omitempty"`
+	NPCSaleData           []FlagNPC                  `json:"npcSaleData,omitempty" yaml:"npcSaleData,omitempty"`
+	ChangedToExpire       *FlagChangedToExpire       `json:"changedToExpire,omitempty" yaml:"changedToExpire,omitempty"`
+	Corpse                bool                       `json:"corpse,omitempty" yaml:"corpse,omitempty"`
+	PlayerCorpse          bool                       `json:"playerCorpse,omitempty" yaml:"playerCorpse,omitempty"`
+	Cyclopedia            *FlagCyclopedia            `json:"cyclopedia,omitempty" yaml:"cyclopedia,omitempty"`
+	Ammo                  bool                       `json:"ammo,omitempty" yaml:"ammo,omitempty"`
+	ShowOffSocket         bool                       `json:"showOffSocket,omitempty" yaml:"showOffSocket,omitempty"`
+	Reportable            bool                       `json:"reportable,omitempty" yaml:"reportable,omitempty"`
+	UpgradeClassification *FlagUpgradeClassification `json:"upgradeClassification,omitempty" yaml:"upgradeClassification,omitempty"`
+	ReverseAddonsEast     bool                       `json:"reverseAddonsEast,omitempty" yaml:"reverseAddonsEast,omitempty"`
+	ReverseAddonsWest     bool                       `json:"reverseAddonsWest,omitempty" yaml:"reverseAddonsWest,omitempty"`
+	ReverseAddonsSouth    bool                       `json:"reverseAddonsSouth,omitempty" yaml:"reverseAddonsSouth,omitempty"`
+	ReverseAddonsNorth    bool                       `json:"reverseAddonsNorth,omitempty" yaml:"reverseAddonsNorth,omitempty"`
+	Wearout               bool                       `json:"wearout,omitempty" yaml:"wearout,omitempty"`
+	ClockExpire           bool                       `json:"clockExpire,omitempty" yaml:"clockExpire,omitempty"`
+	Expire                bool                       `json:"expire,omitempty" yaml:"expire,omitempty"`
+	ExpireStop            bool                       `json:"expireStop,omitempty" yaml:"expireStop,omitempty"`
+}
+
+type FlagBank struct {
+	Waypoints int `json:"waypoints" yaml:"waypoints"`
+}
+
+type FlagWrite struct {
+	MaxTextLength int `json:"maxTextLength" yaml:"maxTextLength"`
+}
+
+type FlagWriteOnce struct {
+	MaxTextLengthOnce int `json:"maxTextLengthOnce" yaml:"maxTextLengthOnce"`
+}
+
+type FlagHook struct {
+	Direction int `json:"direction" yaml:"direction"`
+}
+
+type FlagLight struct {
+	Brightness int `json:"brightness" yaml:"brightness"`
+	Color      int `json:"color" yaml:"color"`
+}
+
+type FlagShift struct {
+	X int `json:"x" yaml:"x"`
+	Y int `json:"y" yaml:"y"`
+}
+
+type FlagHeight struct {
+	Elevation int `json:"elevation" yaml:"elevation"`
+}
+
+type FlagAutomap struct {
+	Color int `json:"color" yaml:"color"`
+}
+
+type FlagLensHelp struct {
+	ID int `json:"id" yaml:"id"`
+}
+
+type FlagClothes struct {
+	Slot int `json:"slot" yaml:"slot"`
+}
+
+type FlagDefaultAction struct {
+	Action int `json:"action" yaml:"action"`
+}
+
+type FlagMarket struct {
+	Category             int   `json:"category" yaml:"category"`
+	TradeAsObjectID      int   `json:"tradeAsObjectId" yaml:"tradeAsObjectId"`
+	ShowAsObjectID       int   `json:"showAsObjectId" yaml:"showAsObjectId"`
+	RestrictToProfession []int `json:"restrictToProfession,omitempty" yaml:"restrictToProfession,omitempty,flow"`
+	MinimumLevel         int   `json:"minimumLevel" yaml:"minimumLevel"`
+}
+
+type FlagNPC struct {
+	Name                         string `json:"name,omitempty" yaml:"name,omitempty"`
+	Location                     string `json:"location,omitempty" yaml:"location,omitempty"`
+	SalePrice                    int    `json:"salePrice" yaml:"salePrice"`
+	BuyPrice                     int    `json:"buyPrice" yaml:"buyPrice"`
+	CurrencyObjectTypeID         int    `json:"currencyObjectTypeId" yaml:"currencyObjectTypeId"`
+	CurrencyQuestFlagDisplayName string `json:"currencyQuestFlagDisplayName,omitempty" yaml:"currencyQuestFlagDisplayName,omitempty"`
+}
+
+type FlagChangedToExpire struct {
+	FormerObjectTypeID int `json:"formerObjectTypeId" yaml:"formerObjectTypeId"`
+}
+
+type FlagCyclopedia struct {
+	CyclopediaType int `json:"cyclopediaType" yaml:"cyclopediaType"`
+}
+
+type FlagUpgradeClassification struct {
+	UpgradeClassification int `json:"upgradeClassification" yaml:"upgradeClassification"`
+}
+
+// LoadAppearances reads and decodes an appearances file from disk.
+func LoadAppearances(path string) (*Appearances, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeAppearances(data)
+}
+
+// DecodeAppearances decodes the protobuf "Appearances" message stored in the
+// client's appearances-*.dat file. Unknown fields are skipped.
+func DecodeAppearances(data []byte) (*Appearances, error) {
+	out := &Appearances{}
+	err := walkMessage(data, func(field int, f protoField) error {
+		var list *[]Appearance
+		switch field {
+		case 1:
+			list = &out.Objects
+		case 2:
+			list = &out.Outfits
+		case 3:
+			list = &out.Effects
+		case 4:
+			list = &out.Missiles
+		default:
+			return nil
+		}
+		a, err := decodeAppearance(f.data)
+		if err != nil {
+			return fmt.Errorf("appearance #%d: %w", len(*list), err)
+		}
+		*list = append(*list, a)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func decodeAppearance(buf []byte) (Appearance, error) {
+	var a Appearance
+	err := walkMessage(buf, func(field int, f protoField) error {
+		switch field {
+		case 1:
+			a.ID = f.int()
+		case 2:
+			g, err := decodeFrameGroup(f.data)
+			if err != nil {
+				return fmt.Errorf("frame group: %w", err)
+			}
+			a.FrameGroups = append(a.FrameGroups, g)
+		case 3:
+			flags, err := decodeAppearanceFlags(f.data)
+			if err != nil {
+				return fmt.Errorf("flags: %w", err)
+			}
+			a.Flags = flags
+		case 4:
+			a.Name = string(f.data)
+		case 5:
+			a.Description = string(f.data)
+		}
+		return nil
+	})
+	return a, err
+}
+
+func decodeFrameGroup(buf []byte) (FrameGroup, error) {
+	var g FrameGroup
+	err := walkMessage(buf, func(field int, f protoField) error {
+		switch field {
+		case 1:
+			g.FixedFrameGroup = FixedFrameGroup(f.int())
+		case 2:
+			g.ID = f.int()
+		case 3:
+			info, err := decodeSpriteInfo(f.data)
+			if err != nil {
+				return fmt.Errorf("sprite info: %w", err)
+			}
+			g.SpriteInfo = info
+		}
+		return nil
+	})
+	return g, err
+}
+
+func decodeSpriteInfo(buf []byte) (SpriteInfo, error) {
+	var info SpriteInfo
+	err := walkMessage(buf, func(field int, f protoField) error {
+		var err error
+		switch field {
+		case 1:
+			info.PatternWidth = f.int()
+		case 2:
+			info.PatternHeight = f.int()
+		case 3:
+			info.PatternDepth = f.int()
+		case 4:
+			info.Layers = f.int()
+		case 5:
+			info.SpriteIDs, err = f.appendInts(info.SpriteIDs)
+		case 6:
+			var anim SpriteAnimation
+			anim, err = decodeSpriteAnimation(f.data)
+			info.Animation = &anim
+		case 7:
+			info.BoundingSquare = f.int()
+		case 8:
+			info.IsOpaque = f.bool()
+		case 9:
+			var box Box
+			box, err = decodeBox(f.data)
+			info.BoundingBoxes = append(info.BoundingBoxes, box)
+		}
+		return err
+	})
+	return info, err
+}
+
+func decodeSpriteAnimation(buf []byte) (SpriteAnimation, error) {
+	var anim SpriteAnimation
+	err := walkMessage(buf, func(field int, f protoField) error {
+		switch field {
+		case 1:
+			anim.DefaultStartPhase = f.int()
+		case 2:
+			anim.Synchronized = f.bool()
+		case 3:
+			anim.RandomStartPhase = f.bool()
+		case 4:
+			anim.LoopType = AnimationLoopType(f.int32())
+		case 5:
+			anim.LoopCount = f.int()
+		case 6:
+			var phase SpritePhase
+			err := walkMessage(f.data, func(field int, f protoField) error {
+				switch field {
+				case 1:
+					phase.DurationMin = f.int()
+				case 2:
+					phase.DurationMax = f.int()
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("sprite phase: %w", err)
+			}
+			anim.Phases = append(anim.Phases, phase)
+		}
+		return nil
+	})
+	return anim, err
+}
+
+func decodeBox(buf []byte) (Box, error) {
+	var box Box
+	err := walkMessage(buf, func(field int, f protoField) error {
+		switch field {
+		case 1:
+			box.X = f.int()
+		case 2:
+			box.Y = f.int()
+		case 3:
+			box.Width = f.int()
+		case 4:
+			box.Height = f.int()
+		}
+		return nil
+	})
+	return box, err
+}
+
+func decodeAppearanceFlags(buf []byte) (AppearanceFlags, error) {
+	var fl AppearanceFlags
+	err := walkMessage(buf, func(field int, f protoField) error {
+		var err error
+		switch field {
+		case 1:
+			fl.Bank = &FlagBank{}
+			err = decodeIntFields(f.data, &fl.Bank.Waypoints)
+		case 2:
+			fl.Clip = f.bool()
+		case 3:
+			fl.Bottom = f.bool()
+		case 4:
+			fl.Top = f.bool()
+		case 5:
+			fl.Container = f.bool()
+		case 6:
+			fl.Cumulative = f.bool()
+		case 7:
+			fl.Usable = f.bool()
+		case 8:
+			fl.ForceUse = f.bool()
+		case 9:
+			fl.MultiUse = f.bool()
+		case 10:
+			fl.Write = &FlagWrite{}
+			err = decodeIntFields(f.data, &fl.Write.MaxTextLength)
+		case 11:
+			fl.WriteOnce = &FlagWriteOnce{}
+			err = decodeIntFields(f.data, &fl.WriteOnce.MaxTextLengthOnce)
+		case 12:
+			fl.LiquidPool = f.bool()
+		case 13:
+			fl.Unpass = f.bool()
+		case 14:
+			fl.Unmove = f.bool()
+		case 15:
+			fl.Unsight = f.bool()
+		case 16:
+			fl.Avoid = f.bool()
+		case 17:
+			fl.NoMovementAnimation = f.bool()
+		case 18:
+			fl.Take = f.bool()
+		case 19:
+			fl.LiquidContainer = f.bool()
+		case 20:
+			fl.Hang = f.bool()
+		case 21:
+			fl.Hook = &FlagHook{}
+			err = decodeIntFields(f.data, &fl.Hook.Direction)
+		case 22:
+			fl.Rotate = f.bool()
+		case 23:
+			fl.Light = &FlagLight{}
+			err = decodeIntFields(f.data, &fl.Light.Brightness, &fl.Light.Color)
+		case 24:
+			fl.DontHide = f.bool()
+		case 25:
+			fl.Translucent = f.bool()
+		case 26:
+			fl.Shift = &FlagShift{}
+			err = decodeIntFields(f.data, &fl.Shift.X, &fl.Shift.Y)
+		case 27:
+			fl.Height = &FlagHeight{}
+			err = decodeIntFields(f.data, &fl.Height.Elevation)
+		case 28:
+			fl.LyingObject = f.bool()
+		case 29:
+			fl.AnimateAlways = f.bool()
+		case 30:
+			fl.Automap = &FlagAutomap{}
+			err = decodeIntFields(f.data, &fl.Automap.Color)
+		case 31:
+			fl.LensHelp = &FlagLensHelp{}
+			err = decodeIntFields(f.data, &fl.LensHelp.ID)
+		case 32:
+			fl.FullBank = f.bool()
+		case 33:
+			fl.IgnoreLook = f.bool()
+		case 34:
+			fl.Clothes = &FlagClothes{}
+			err = decodeIntFields(f.data, &fl.Clothes.Slot)
+		case 35:
+			fl.DefaultAction = &FlagDefaultAction{}
+			err = decodeIntFields(f.data, &fl.DefaultAction.Action)
+		case 36:
+			var market FlagMarket
+			market, err = decodeFlagMarket(f.data)
+			fl.Market = &market
+		case 37:
+			fl.Wrap = f.bool()
+		case 38:
+			fl.Unwrap = f.bool()
+		case 39:
+			fl.TopEffect = f.bool()
+		case 40:
+			var npc FlagNPC
+			npc, err = decodeFlagNPC(f.data)
+			fl.NPCSaleData = append(fl.NPCSaleData, npc)
+		case 41:
+			fl.ChangedToExpire = &FlagChangedToExpire{}
+			err = decodeIntFields(f.data, &fl.ChangedToExpire.FormerObjectTypeID)
+		case 42:
+			fl.Corpse = f.bool()
+		case 43:
+			fl.PlayerCorpse = f.bool()
+		case 44:
+			fl.Cyclopedia = &FlagCyclopedia{}
+			err = decodeIntFields(f.data, &fl.Cyclopedia.CyclopediaType)
+		case 45:
+			fl.Ammo = f.bool()
+		case 46:
+			fl.ShowOffSocket = f.bool()
+		case 47:
+			fl.Reportable = f.bool()
+		case 48:
+			fl.UpgradeClassification = &FlagUpgradeClassification{}
+			err = decodeIntFields(f.data, &fl.UpgradeClassification.UpgradeClassification)
+		case 49:
+			fl.ReverseAddonsEast = f.bool()
+		case 50:
+			fl.ReverseAddonsWest = f.bool()
+		case 51:
+			fl.ReverseAddonsSouth = f.bool()
+		case 52:
+			fl.ReverseAddonsNorth = f.bool()
+		case 53:
+			fl.Wearout = f.bool()
+		case 54:
+			fl.ClockExpire = f.bool()
+		case 55:
+			fl.Expire = f.bool()
+		case 56:
+			fl.ExpireStop = f.bool()
+		}
+		return err
+	})
+	return fl, err
+}
+
+func decodeFlagMarket(buf []byte) (FlagMarket, error) {
+	var m FlagMarket
+	err := walkMessage(buf, func(field int, f protoField) error {
+		var err error
+		switch field {
+		case 1:
+			m.Category = f.int()
+		case 2:
+			m.TradeAsObjectID = f.int()
+		case 3:
+			m.ShowAsObjectID = f.int()
+		case 5:
+			m.RestrictToProfession, err = f.appendInts(m.RestrictToProfession)
+		case 6:
+			m.MinimumLevel = f.int()
+		}
+		return err
+	})
+	return m, err
+}
+
+func decodeFlagNPC(buf []byte) (FlagNPC, error) {
+	var npc FlagNPC
+	err := walkMessage(buf, func(field int, f protoField) error {
+		switch field {
+		case 1:
+			npc.Name = string(f.data)
+		case 2:
+			npc.Location = string(f.data)
+		case 3:
+			npc.SalePrice = f.int()
+		case 4:
+			npc.BuyPrice = f.int()
+		case 5:
+			npc.CurrencyObjectTypeID = f.int()
+		case 6:
+			npc.CurrencyQuestFlagDisplayName = string(f.data)
+		}
+		return nil
+	})
+	return npc, err
+}
+
+// decodeIntFields decodes a message whose fields 1..len(dst) are all varints,
+// which covers most of the single-purpose flag messages.
+func decodeIntFields(buf []byte, dst ...*int) error {
+	return walkMessage(buf, func(field int, f protoField) error {
+		if field >= 1 && field <= len(dst) {
+			*dst[field-1] = f.int()
+		}
+		return nil
+	})
+}
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// protoField is a single decoded field. value holds varint and fixed-width
+// payloads, data holds length-delimited ones.
+type protoField struct {
+	wireType int
+	value    uint64
+	data     []byte
+}
+
+func (f protoField) int() int { return int(f.value) }
+
+// int32 decodes proto int32 values, which are sign-extended to 64 bits on the
+// wire when negative.
+func (f protoField) int32() int { return int(int32(f.value)) }
+
+func (f protoField) bool() bool { return f.value != 0 }
+
+// appendInts handles repeated varint fields in both packed and unpacked form.
+func (f protoField) appendInts(dst []int) ([]int, error) {
+	if f.wireType != wireBytes {
+		return append(dst, f.int()), nil
+	}
+	for i := 0; i < len(f.data); {
+		v, next, ok := readVarint(f.data, i)
+		if !ok {
+			return dst, errTruncated
+		}
+		dst = append(dst, v)
+		i = next
+	}
+	return dst, nil
+}
+
+// walkMessage calls fn for every field of a protobuf message in wire order.
+func walkMessage(buf []byte, fn func(field int, f protoField) error) error {
+	for i := 0; i < len(buf); {
+		tag, next, ok := readVarint(buf, i)
+		if !ok {
+			return errTruncated
+		}
+		i = next
+
+		f := protoField{wireType: tag & 0x7}
+		switch f.wireType {
+		case wireVarint:
+			v, next, ok := readVarint(buf, i)
+			if !ok {
+				return errTruncated
+			}
+			f.value = uint64(v)
+			i = next
+		case wireFixed64:
+			if i+8 > len(buf) {
+				return errTruncated
+			}
+			for k := 7; k >= 0; k-- {
+				f.value = f.value<<8 | uint64(buf[i+k])
+			}
+			i += 8
+		case wireBytes:
+			n, next, ok := readVarint(buf, i)
+			if !ok || n < 0 || n > len(buf)-next {
+				return errTruncated
+			}
+			f.data = buf[next : next+n]
+			i = next + n
+		case wireFixed32:
+			if i+4 > len(buf) {
+				return errTruncated
+			}
+			for k := 3; k >= 0; k-- {
+				f.value = f.value<<8 | uint64(buf[i+k])
+			}
+			i += 4
+		default:
+			return fmt.Errorf("unsupported wire type %d at offset %d", f.wireType, i)
+		}
+
+		if err := fn(tag>>3, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readVarint decodes a base-128 varint starting at buf[i]. It returns the
+// value, the index just past it and whether decoding succeeded.
+func readVarint(buf []byte, i int) (int, int, bool) {
+	var x uint64
+	var s uint
+	start := i
+	for {
+		if i >= len(buf) {
+			return 0, start, false
+		}
+		b := buf[i]
+		if b < 0x80 {
+			if s >= 64 {
+				return 0, start, false
+			}
+			x |= uint64(b) << s
+			i++
+			return int(x), i, true
+		}
+		x |= uint64(b&0x7F) << s
+		s += 7
+		i++
+		if s > 70 {
+			return 0, start, false
+		}
+	}
+}
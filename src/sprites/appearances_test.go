@@ -0,0 +1,219 @@
+package sprites
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// protoMessage builds protobuf wire bytes for tests.
+type protoMessage []byte
+
+func (m protoMessage) varint(field, v int) protoMessage {
+	m = append(m, encodeVarint(field<<3|wireVarint)...)
+	return append(m, encodeVarint(v)...)
+}
+
+func (m protoMessage) message(field int, sub protoMessage) protoMessage {
+	m = append(m, encodeVarint(field<<3|wireBytes)...)
+	m = append(m, encodeVarint(len(sub))...)
+	return append(m, sub...)
+}
+
+func (m protoMessage) str(field int, s string) protoMessage {
+	return m.message(field, protoMessage(s))
+}
+
+func buildSpriteInfo(pw, ph, pd, layers int, ids ...int) protoMessage {
+	info := protoMessage{}.varint(1, pw).varint(2, ph).varint(3, pd).varint(4, layers)
+	for _, id := range ids {
+		info = info.varint(5, id)
+	}
+	return info
+}
+
+func buildAppearance(id int, infos ...protoMessage) protoMessage {
+	a := protoMessage{}.varint(1, id)
+	for i, info := range infos {
+		a = a.message(2, protoMessage{}.varint(1, int(FrameGroupObjectInitial)).varint(2, i).message(3, info))
+	}
+	return a
+}
+
+func encodeVarint(v int) []byte {
+	var buf [10]byte
+	n := binary.PutUvarint(buf[:], uint64(v))
+	return buf[:n]
+}
+
+func TestReadVarintDecodesValues(t *testing.T) {
+	var buf [10]byte
+	n := binary.PutUvarint(buf[:], 300)
+
+	got, next, ok := readVarint(buf[:n], 0)
+	if !ok {
+		t.Fatalf("readVarint reported failure")
+	}
+	if got != 300 {
+		t.Fatalf("readVarint decoded %d, want 300", got)
+	}
+	if next != n {
+		t.Fatalf("readVarint next index = %d, want %d", next, n)
+	}
+}
+
+func TestReadVarintFailsOnTruncatedInput(t *testing.T) {
+	buf := []byte{0x80}
+
+	if _, _, ok := readVarint(buf, 0); ok {
+		t.Fatalf("readVarint succeeded on truncated input")
+	}
+}
+
+func TestDecodeAppearancesReadsAllCategories(t *testing.T) {
+	anim := protoMessage{}.
+		varint(1, 0).
+		varint(2, 1).
+		varint(4, -1).
+		message(6, protoMessage{}.varint(1, 100).varint(2, 200)).
+		message(6, protoMessage{}.varint(1, 300).varint(2, 300))
+	outfitInfo := buildSpriteInfo(4, 1, 1, 2, 10, 11, 12, 13, 14, 15, 16, 17).
+		message(6, anim).
+		varint(7, 64).
+		message(9, protoMessage{}.varint(1, 1).varint(2, 2).varint(3, 30).varint(4, 31))
+
+	flags := protoMessage{}.
+		message(1, protoMessage{}.varint(1, 3)).
+		varint(6, 1).
+		message(23, protoMessage{}.varint(1, 5).varint(2, 215)).
+		message(26, protoMessage{}.varint(1, 8).varint(2, 9)).
+		message(36, protoMessage{}.varint(1, 17).varint(2, 3031).varint(3, 3031).varint(5, 1).varint(5, 2).varint(6, 20)).
+		message(40, protoMessage{}.str(1, "Rashid").str(2, "Svargrond").varint(3, 50).varint(4, 100))
+
+	object := buildAppearance(3031, buildSpriteInfo(1, 1, 1, 1, 5)).
+		message(3, flags).
+		str(4, "gold coin").
+		str(5, "shiny")
+
+	outfit := protoMessage{}.
+		varint(1, 128).
+		message(2, protoMessage{}.varint(1, int(FrameGroupOutfitIdle)).varint(2, 0).message(3, outfitInfo))
+
+	// Unknown top-level field must be skipped.
+	data := protoMessage{}.
+		message(1, object).
+		message(2, outfit).
+		message(3, buildAppearance(7, buildSpriteInfo(1, 1, 1, 1, 20))).
+		message(4, buildAppearance(8, buildSpriteInfo(3, 3, 1, 1, 30))).
+		message(5, protoMessage{}.varint(1, 1))
+
+	apps, err := DecodeAppearances(data)
+	if err != nil {
+		t.Fatalf("DecodeAppearances error: %v", err)
+	}
+
+	if len(apps.Objects) != 1 || len(apps.Outfits) != 1 || len(apps.Effects) != 1 || len(apps.Missiles) != 1 {
+		t.Fatalf("unexpected category sizes: %d/%d/%d/%d", len(apps.Objects), len(apps.Outfits), len(apps.Effects), len(apps.Missiles))
+	}
+
+	obj := apps.Objects[0]
+	if obj.ID != 3031 || obj.Name != "gold coin" || obj.Description != "shiny" {
+		t.Fatalf("object = %+v", obj)
+	}
+	if !obj.Flags.Cumulative || obj.Flags.Bank == nil || obj.Flags.Bank.Waypoints != 3 {
+		t.Fatalf("object flags = %+v", obj.Flags)
+	}
+	if *obj.Flags.Light != (FlagLight{Brightness: 5, Color: 215}) || *obj.Flags.Shift != (FlagShift{X: 8, Y: 9}) {
+		t.Fatalf("light/shift = %+v %+v", obj.Flags.Light, obj.Flags.Shift)
+	}
+	wantMarket := FlagMarket{Category: 17, TradeAsObjectID: 3031, ShowAsObjectID: 3031, RestrictToProfession: []int{1, 2}, MinimumLevel: 20}
+	if !reflect.DeepEqual(*obj.Flags.Market, wantMarket) {
+		t.Fatalf("market = %+v, want %+v", *obj.Flags.Market, wantMarket)
+	}
+	wantNPC := []FlagNPC{{Name: "Rashid", Location: "Svargrond", SalePrice: 50, BuyPrice: 100}}
+	if !reflect.DeepEqual(obj.Flags.NPCSaleData, wantNPC) {
+		t.Fatalf("npc = %+v, want %+v", obj.Flags.NPCSaleData, wantNPC)
+	}
+
+	out := apps.Outfits[0]
+	if out.ID != 128 || len(out.FrameGroups) != 1 {
+		t.Fatalf("outfit = %+v", out)
+	}
+	info := out.FrameGroups[0].SpriteInfo
+	if info.PatternWidth != 4 || info.PatternHeight != 1 || info.PatternDepth != 1 || info.Layers != 2 {
+		t.Fatalf("sprite info patterns = %+v", info)
+	}
+	if !reflect.DeepEqual(info.SpriteIDs, []int{10, 11, 12, 13, 14, 15, 16, 17}) {
+		t.Fatalf("sprite ids = %v", info.SpriteIDs)
+	}
+	if info.BoundingSquare != 64 || !reflect.DeepEqual(info.BoundingBoxes, []Box{{X: 1, Y: 2, Width: 30, Height: 31}}) {
+		t.Fatalf("bounding = %d %+v", info.BoundingSquare, info.BoundingBoxes)
+	}
+	wantAnim := &SpriteAnimation{
+		Synchronized: true,
+		LoopType:     AnimationLoopPingPong,
+		Phases:       []SpritePhase{{DurationMin: 100, DurationMax: 200}, {DurationMin: 300, DurationMax: 300}},
+	}
+	if !reflect.DeepEqual(info.Animation, wantAnim) {
+		t.Fatalf("animation = %+v, want %+v", info.Animation, wantAnim)
+	}
+
+	if apps.Effects[0].ID != 7 || apps.Missiles[0].ID != 8 {
+		t.Fatalf("effect/missile ids = %d/%d", apps.Effects[0].ID, apps.Missiles[0].ID)
+	}
+}
+
+func TestDecodeAppearancesAcceptsPackedSpriteIDs(t *testing.T) {
+	packed := protoMessage{}
+	for _, id := range []int{1, 300, 70000} {
+		packed = append(packed, encodeVarint(id)...)
+	}
+	info := protoMessage{}.varint(4, 1).message(5, packed)
+
+	apps, err := DecodeAppearances(protoMessage{}.message(1, buildAppearance(1, info)))
+	if err != nil {
+		t.Fatalf("DecodeAppearances error: %v", err)
+	}
+	got := apps.Objects[0].FrameGroups[0].SpriteInfo.SpriteIDs
+	if !reflect.DeepEqual(got, []int{1, 300, 70000}) {
+		t.Fatalf("sprite ids = %v", got)
+	}
+}
+
+func TestDecodeAppearancesRejectsTruncatedData(t *testing.T) {
+	data := protoMessage{}.message(1, buildAppearance(1, buildSpriteInfo(1, 1, 1, 1, 5)))
+	if _, err := DecodeAppearances(data[:len(data)-2]); err == nil {
+		t.Fatalf("expected error for truncated data")
+	}
+}
+
+func TestDecodeAppearancesRejectsOversizedLength(t *testing.T) {
+	// A length just below MaxInt makes offset+length wrap around.
+	data := append(encodeVarint(1<<3|wireBytes), encodeVarint(math.MaxInt-1)...)
+	data = append(data, 0x08, 0x01)
+	if _, err := DecodeAppearances(data); err == nil {
+		t.Fatalf("expected error for oversized length")
+	}
+}
+
+func TestLoadAppearancesReadsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appearances.dat")
+	if err := os.WriteFile(path, protoMessage{}.message(3, buildAppearance(9)), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	apps, err := LoadAppearances(path)
+	if err != nil {
+		t.Fatalf("LoadAppearances error: %v", err)
+	}
+	if len(apps.Effects) != 1 || apps.Effects[0].ID != 9 {
+		t.Fatalf("effects = %+v", apps.Effects)
+	}
+
+	if _, err := LoadAppearances(filepath.Join(t.TempDir(), "missing.dat")); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
@@ -0,0 +1,144 @@
+package sprites
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/ulikunitz/xz/lzma"
+	"golang.org/x/image/bmp"
+)
+
+// SheetSize is the width and height of every sheet the client ships.
+const SheetSize = 384
+
+// Stages of decoding a client asset, as named by AssetDecodeError.
+const (
+	StageCIPHeader  = "CIP header"
+	StageLZMAHeader = "LZMA header"
+	StageLZMAStream = "LZMA stream"
+	StageBMP        = "BMP"
+)
+
+// AssetDecodeError is a failure to decode a client asset, labelled with the
+// stage of the pipeline that failed.
+type AssetDecodeError struct {
+	Stage string
+	Err   error
+}
+
+func (e *AssetDecodeError) Error() string {
+	return e.Stage + ": " + e.Err.Error()
+}
+
+func (e *AssetDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeAsset turns a compressed client asset into the sheet image it holds:
+// skip the CIP header, repair the LZMA header, decompress and decode the BMP.
+// Errors are *AssetDecodeError.
+func DecodeAsset(r io.Reader) (image.Image, error) {
+	br := bufio.NewReaderSize(r, 1<<20) // 1MB buffer for fewer syscalls
+
+	// 1) Skip CIP header
+	if err := skipCIPHeader(br); err != nil {
+		return nil, &AssetDecodeError{Stage: StageCIPHeader, Err: err}
+	}
+
+	// 2) Build an LZMA reader from the remaining stream (repair header)
+	lzReader, err := newLZMAReader(br)
+	if err != nil {
+		return nil, &AssetDecodeError{Stage: StageLZMAHeader, Err: err}
+	}
+
+	// 3) LZMA→BMP bytes
+	var bmpBuf bytes.Buffer
+	if _, err := io.Copy(&bmpBuf, lzReader); err != nil {
+		return nil, &AssetDecodeError{Stage: StageLZMAStream, Err: err}
+	}
+
+	// 4) BMP→image.Image
+	img, err := bmp.Decode(bytes.NewReader(bmpBuf.Bytes()))
+	if err != nil {
+		return nil, &AssetDecodeError{Stage: StageBMP, Err: err}
+	}
+	return img, nil
+}
+
+// skipCIPHeader consumes:
+//   - all leading 0x00 bytes
+//   - then 4 bytes (constant marker)
+//   - then a 7-bit length (continue while MSB=1)
+func skipCIPHeader(r *bufio.Reader) error {
+	// Skip leading zeros; consume first non-zero byte.
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != 0x00 {
+			break // consumed first non-zero byte (part of constant)
+		}
+	}
+	// Skip remaining 4 bytes of the constant marker.
+	if _, err := io.CopyN(io.Discard, r, 4); err != nil {
+		return err
+	}
+	// Skip 7-bit length (bytes with MSB=1 mean "more").
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if (b & 0x80) == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// newLZMAReader reads the 5-byte props + bogus 8-byte size,
+// replaces size with 0xFF..FF (unknown), and returns a decoder for the rest.
+func newLZMAReader(r *bufio.Reader) (io.Reader, error) {
+	props := make([]byte, 5)
+	if _, err := io.ReadFull(r, props); err != nil {
+		return nil, fmt.Errorf("read props: %w", err)
+	}
+	// Discard bogus size (CIP writes compressed size).
+	if _, err := io.CopyN(io.Discard, r, 8); err != nil {
+		return nil, fmt.Errorf("discard bogus size: %w", err)
+	}
+
+	// Build corrected "LZMA alone" header: props + unknown size (all 0xFF).
+	var header bytes.Buffer
+	header.Write(props)
+	header.Write(bytes.Repeat([]byte{0xFF}, 8))
+
+	stream := io.MultiReader(&header, r)
+	rd, err := lzma.NewReader(stream)
+	if err != nil {
+		return nil, err
+	}
+	return rd, nil
+}
+
+// SheetCapacity is the number of sprites of spriteType that fit in a sheet.
+func SheetCapacity(sheet image.Rectangle, spriteType SpriteType) int {
+	tileW, tileH := spriteType.Size()
+	return (sheet.Dx() / tileW) * (sheet.Dy() / tileH)
+}
+
+// SpriteRect locates the index-th sprite of a sheet. Sprites are laid out
+// row by row, left to right.
+func SpriteRect(sheet image.Rectangle, spriteType SpriteType, index int) image.Rectangle {
+	tileW, tileH := spriteType.Size()
+	cols := sheet.Dx() / tileW
+	if cols == 0 {
+		return image.Rectangle{}
+	}
+	c, r := index%cols, index/cols
+	return image.Rect(sheet.Min.X+c*tileW, sheet.Min.Y+r*tileH, sheet.Min.X+(c+1)*tileW, sheet.Min.Y+(r+1)*tileH)
+}
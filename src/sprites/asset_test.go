@@ -0,0 +1,173 @@
+package sprites
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ulikunitz/xz/lzma"
+	"golang.org/x/image/bmp"
+)
+
+func newTestImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x % 256), G: uint8(y % 256), B: uint8((x + y) % 256), A: 255})
+		}
+	}
+	return img
+}
+
+func encodeVarint7bit(v int) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7F)
+		v >>= 7
+		if v != 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if v == 0 {
+			break
+		}
+	}
+	return out
+}
+
+func encodeLZMA(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := lzma.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("new lzma writer: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("write lzma data: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close lzma writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func makeCIPAsset(t *testing.T, lzmaStream []byte) []byte {
+	t.Helper()
+
+	header := []byte{0x00, 0x00, 0xC1, 0xA7, 0xC0, 0xDE, 0x01}
+	header = append(header, encodeVarint7bit(len(lzmaStream))...)
+	return append(header, lzmaStream...)
+}
+
+func makeCIPAssetFromImage(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var bmpBuf bytes.Buffer
+	if err := bmp.Encode(&bmpBuf, img); err != nil {
+		t.Fatalf("encode bmp: %v", err)
+	}
+	return makeCIPAsset(t, encodeLZMA(t, bmpBuf.Bytes()))
+}
+
+func makeCIPAssetFromBytes(t *testing.T, payload []byte) []byte {
+	t.Helper()
+	return makeCIPAsset(t, encodeLZMA(t, payload))
+}
+
+func compareImages(t *testing.T, got image.Image, want image.Image) {
+	t.Helper()
+
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("image bounds mismatch: got %v want %v", got.Bounds(), want.Bounds())
+	}
+	b := want.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			wantPixel := color.NRGBAModel.Convert(want.At(x, y)).(color.NRGBA)
+			gotPixel := color.NRGBAModel.Convert(got.At(x, y)).(color.NRGBA)
+			if wantPixel != gotPixel {
+				t.Fatalf("pixel mismatch at (%d,%d): got %#v want %#v", x, y, gotPixel, wantPixel)
+			}
+		}
+	}
+}
+
+func writeCIPFile(t *testing.T, dir, name string, data []byte) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write asset %s: %v", name, err)
+	}
+}
+
+func TestDecodeAssetLabelsFailedStage(t *testing.T) {
+	truncated := makeCIPAssetFromImage(t, newTestImage(384, 384))
+	for _, tt := range []struct {
+		data  []byte
+		stage string
+	}{
+		{[]byte{0x00, 0x00}, StageCIPHeader},
+		{makeCIPAsset(t, []byte{0x5D, 0, 0}), StageLZMAHeader},
+		{truncated[:len(truncated)/2], StageLZMAStream},
+		{makeCIPAssetFromBytes(t, []byte("not a bmp")), StageBMP},
+	} {
+		_, err := DecodeAsset(bytes.NewReader(tt.data))
+		var decodeErr *AssetDecodeError
+		if !errors.As(err, &decodeErr) || decodeErr.Stage != tt.stage {
+			t.Fatalf("DecodeAsset error = %v, want stage %s", err, tt.stage)
+		}
+	}
+}
+
+func TestSkipCIPHeaderSkipsZerosConstantAndVarint(t *testing.T) {
+	payload := []byte{0x00, 0x00, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE}
+	payload = append(payload, 0x81, 0x01) // two-byte varint
+	payload = append(payload, 0xFF)
+
+	r := bufio.NewReader(bytes.NewReader(payload))
+	if err := skipCIPHeader(r); err != nil {
+		t.Fatalf("skipCIPHeader returned error: %v", err)
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		t.Fatalf("expected remaining byte: %v", err)
+	}
+	if b != 0xFF {
+		t.Fatalf("unexpected byte after header: got 0x%X want 0xFF", b)
+	}
+}
+
+func TestSkipCIPHeaderErrorsOnUnexpectedEOF(t *testing.T) {
+	r := bufio.NewReader(bytes.NewReader([]byte{0x00, 0x00}))
+	if err := skipCIPHeader(r); err == nil {
+		t.Fatalf("expected error for truncated header")
+	}
+}
+
+func TestNewLZMAReaderDecodesStream(t *testing.T) {
+	stream := encodeLZMA(t, []byte("hello world"))
+	r, err := newLZMAReader(bufio.NewReader(bytes.NewReader(stream)))
+	if err != nil {
+		t.Fatalf("newLZMAReader error: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll error: %v", err)
+	}
+	if string(data) != "hello world" {
+		t.Fatalf("unexpected decompressed data: %q", string(data))
+	}
+}
+
+func TestNewLZMAReaderErrorsOnShortHeader(t *testing.T) {
+	if _, err := newLZMAReader(bufio.NewReader(bytes.NewReader([]byte{0x01, 0x02, 0x03}))); err == nil {
+		t.Fatalf("expected error for short header")
+	}
+}
@@ -0,0 +1,56 @@
+// Package sprites reads the sprites and appearances of a Tibia client in
+// memory. It never writes files and never logs.
+package sprites
+
+import "fmt"
+
+// CatalogElem is one entry of the client's "catalog-content.json".
+type CatalogElem struct {
+	Type          string     `json:"type"`
+	File          string     `json:"file"`
+	SpriteType    SpriteType `json:"spritetype"`
+	FirstSpriteId int        `json:"firstspriteid"`
+	LastSpriteId  int        `json:"lastspriteid"`
+	Area          int        `json:"area"`
+}
+
+// SpriteType is the client's "spritetype" catalog value. It describes the
+// dimensions of every sprite stored in a sheet.
+type SpriteType int
+
+const (
+	SpriteType32x32 SpriteType = iota
+	SpriteType32x64
+	SpriteType64x32
+	SpriteType64x64
+)
+
+// Size returns the sprite width and height in pixels. Unknown types are
+// treated as 32x32.
+func (t SpriteType) Size() (width, height int) {
+	switch t {
+	case SpriteType32x64:
+		return 32, 64
+	case SpriteType64x32:
+		return 64, 32
+	case SpriteType64x64:
+		return 64, 64
+	default:
+		return 32, 32
+	}
+}
+
+func (t SpriteType) String() string {
+	w, h := t.Size()
+	return fmt.Sprintf("%dx%d", w, h)
+}
+
+// SpriteTypeFromSize is the inverse of SpriteType.Size.
+func SpriteTypeFromSize(width, height int) (SpriteType, bool) {
+	for _, t := range []SpriteType{SpriteType32x32, SpriteType32x64, SpriteType64x32, SpriteType64x64} {
+		if w, h := t.Size(); w == width && h == height {
+			return t, true
+		}
+	}
+	return SpriteType32x32, false
+}
@@ -0,0 +1,26 @@
+package sprites
+
+import "testing"
+
+func TestSpriteTypeSize(t *testing.T) {
+	cases := map[SpriteType][2]int{
+		SpriteType32x32: {32, 32},
+		SpriteType32x64: {32, 64},
+		SpriteType64x32: {64, 32},
+		SpriteType64x64: {64, 64},
+		SpriteType(42):  {32, 32},
+	}
+	for spriteType, want := range cases {
+		w, h := spriteType.Size()
+		if w != want[0] || h != want[1] {
+			t.Fatalf("SpriteType(%d).Size() = %dx%d, want %dx%d", spriteType, w, h, want[0], want[1])
+		}
+	}
+
+	if got, ok := SpriteTypeFromSize(64, 32); !ok || got != SpriteType64x32 {
+		t.Fatalf("SpriteTypeFromSize(64, 32) = %v, %v", got, ok)
+	}
+	if _, ok := SpriteTypeFromSize(16, 16); ok {
+		t.Fatalf("SpriteTypeFromSize(16, 16) reported a known type")
+	}
+}
@@ -0,0 +1,184 @@
+package sprites
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ErrSpriteNotFound is returned when no catalog sheet covers a sprite ID.
+var ErrSpriteNotFound = errors.New("sprite not found")
+
+const defaultSheetCacheSize = 16
+
+// Client gives random access to the sprites of a client installation. It only
+// reads the catalog and the compressed assets it references; it never writes
+// files and never logs, so it is safe to embed in other tools.
+type Client struct {
+	fsys            fs.FS
+	sheets          []CatalogElem // "sprite" entries sorted by FirstSpriteId
+	appearancesFile string
+
+	mu        sync.Mutex
+	cacheSize int
+	cache     map[string]*list.Element
+	lru       *list.List // of *cachedSheet, most recently used first
+}
+
+type cachedSheet struct {
+	file string
+	img  image.Image
+}
+
+// OpenClient loads "catalog-content.json" from the client assets directory.
+func OpenClient(dir string) (*Client, error) {
+	return OpenClientFS(os.DirFS(dir))
+}
+
+// OpenClientFS is OpenClient for an arbitrary file system rooted at the
+// client assets directory.
+func OpenClientFS(fsys fs.FS) (*Client, error) {
+	data, err := fs.ReadFile(fsys, "catalog-content.json")
+	if err != nil {
+		return nil, fmt.Errorf("read catalog: %w", err)
+	}
+	var elems []CatalogElem
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return nil, fmt.Errorf("decode catalog: %w", err)
+	}
+
+	c := &Client{
+		fsys:      fsys,
+		cacheSize: defaultSheetCacheSize,
+		cache:     make(map[string]*list.Element),
+		lru:       list.New(),
+	}
+	for _, e := range elems {
+		switch e.Type {
+		case "sprite":
+			c.sheets = append(c.sheets, e)
+		case "appearances":
+			c.appearancesFile = e.File
+		}
+	}
+	sort.Slice(c.sheets, func(i, j int) bool {
+		return c.sheets[i].FirstSpriteId < c.sheets[j].FirstSpriteId
+	})
+	return c, nil
+}
+
+// SetCacheSize sets how many decoded sheets are kept in memory. Values lower
+// than 1 keep a single sheet.
+func (c *Client) SetCacheSize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cacheSize = n
+	c.evict()
+}
+
+// Sheets returns the catalog sprite sheets ordered by their first sprite ID.
+func (c *Client) Sheets() []CatalogElem {
+	return append([]CatalogElem(nil), c.sheets...)
+}
+
+// SheetOf returns the catalog sheet whose sprite range contains id.
+func (c *Client) SheetOf(id int) (CatalogElem, bool) {
+	i := sort.Search(len(c.sheets), func(i int) bool {
+		return c.sheets[i].LastSpriteId >= id
+	})
+	if i < len(c.sheets) && c.sheets[i].FirstSpriteId <= id {
+		return c.sheets[i], true
+	}
+	return CatalogElem{}, false
+}
+
+// AppearancesFile is the catalog's appearances entry, or "" when missing.
+func (c *Client) AppearancesFile() string {
+	return c.appearancesFile
+}
+
+// Appearances decodes the appearances file referenced by the catalog.
+func (c *Client) Appearances() (*Appearances, error) {
+	if c.appearancesFile == "" {
+		return nil, errors.New("no appearances file in catalog")
+	}
+	data, err := fs.ReadFile(c.fsys, c.appearancesFile)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeAppearances(data)
+}
+
+// Sprite cuts a single sprite out of its sheet. Decoded sheets are kept in a
+// small LRU cache, so reading neighbouring sprites is cheap.
+func (c *Client) Sprite(id int) (image.Image, error) {
+	sheet, ok := c.SheetOf(id)
+	if !ok {
+		return nil, fmt.Errorf("sprite %d: %w", id, ErrSpriteNotFound)
+	}
+	img, err := c.SheetImage(sheet.File)
+	if err != nil {
+		return nil, fmt.Errorf("sprite %d: %w", id, err)
+	}
+
+	index := id - sheet.FirstSpriteId
+	if index >= SheetCapacity(img.Bounds(), sheet.SpriteType) {
+		return nil, fmt.Errorf("sprite %d: index %d exceeds %s sheet capacity", id, index, sheet.File)
+	}
+
+	w, h := sheet.SpriteType.Size()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), img, SpriteRect(img.Bounds(), sheet.SpriteType, index).Min, draw.Src)
+	return dst, nil
+}
+
+// SheetImage returns the decoded sheet of a compressed asset file listed in
+// the catalog. It shares the cache of Sprite.
+func (c *Client) SheetImage(file string) (image.Image, error) {
+	c.mu.Lock()
+	if el, ok := c.cache[file]; ok {
+		c.lru.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*cachedSheet).img, nil
+	}
+	c.mu.Unlock()
+
+	f, err := c.fsys.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := DecodeAsset(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", file, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.cache[file]; !ok {
+		c.cache[file] = c.lru.PushFront(&cachedSheet{file: file, img: img})
+		c.evict()
+	}
+	return img, nil
+}
+
+// evict drops the least recently used sheets beyond the cache size. The
+// caller must hold c.mu.
+func (c *Client) evict() {
+	for c.lru.Len() > c.cacheSize {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.cache, oldest.Value.(*cachedSheet).file)
+	}
+}
@@ -0,0 +1,122 @@
+package sprites
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func writeTestClient(t *testing.T, sheets map[string]image.Image, catalog string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, img := range sheets {
+		writeCIPFile(t, dir, name, makeCIPAssetFromImage(t, img))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "catalog-content.json"), []byte(catalog), 0o644); err != nil {
+		t.Fatalf("write catalog: %v", err)
+	}
+	return dir
+}
+
+func TestClientSpriteCutsTileFromSheet(t *testing.T) {
+	sheetA := newTestImage(384, 384)
+	sheetB := newTestImage(384, 384)
+	dir := writeTestClient(t, map[string]image.Image{"a.bin": sheetA, "b.bin": sheetB}, `[
+                {"type":"appearances","file":"appearances.dat"},
+                {"type":"sprite","file":"b.bin","spritetype":1,"firstspriteid":200,"lastspriteid":271,"area":0},
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":144,"area":0}
+        ]`)
+
+	client, err := OpenClient(dir)
+	if err != nil {
+		t.Fatalf("OpenClient error: %v", err)
+	}
+	if got := client.AppearancesFile(); got != "appearances.dat" {
+		t.Fatalf("AppearancesFile = %q", got)
+	}
+	if sheets := client.Sheets(); len(sheets) != 2 || sheets[0].File != "a.bin" {
+		t.Fatalf("Sheets = %+v, want sorted by first sprite id", sheets)
+	}
+
+	// Sprite 14 is the second tile of the second row of a 32x32 sheet.
+	got, err := client.Sprite(14)
+	if err != nil {
+		t.Fatalf("Sprite(14) error: %v", err)
+	}
+	want := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	draw.Draw(want, want.Bounds(), sheetA, image.Pt(32, 32), draw.Src)
+	compareImages(t, got, want)
+
+	// Sprite 213 is the second tile of the second row of a 32x64 sheet.
+	got, err = client.Sprite(213)
+	if err != nil {
+		t.Fatalf("Sprite(213) error: %v", err)
+	}
+	want = image.NewRGBA(image.Rect(0, 0, 32, 64))
+	draw.Draw(want, want.Bounds(), sheetB, image.Pt(32, 64), draw.Src)
+	compareImages(t, got, want)
+}
+
+func TestClientSpriteReportsMissingSprites(t *testing.T) {
+	dir := writeTestClient(t, map[string]image.Image{"a.bin": newTestImage(64, 64)}, `[
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":10,"lastspriteid":20,"area":0},
+                {"type":"sprite","file":"gone.bin","spritetype":0,"firstspriteid":30,"lastspriteid":40,"area":0}
+        ]`)
+
+	client, err := OpenClient(dir)
+	if err != nil {
+		t.Fatalf("OpenClient error: %v", err)
+	}
+
+	if _, err := client.Sprite(25); !errors.Is(err, ErrSpriteNotFound) {
+		t.Fatalf("Sprite(25) error = %v, want ErrSpriteNotFound", err)
+	}
+	if _, err := client.Sprite(15); err == nil {
+		t.Fatalf("expected error for sprite beyond sheet capacity")
+	}
+	if _, err := client.Sprite(30); err == nil {
+		t.Fatalf("expected error for missing asset file")
+	}
+}
+
+func TestClientSheetCacheIsBounded(t *testing.T) {
+	fsys := fstest.MapFS{
+		"catalog-content.json": {Data: []byte(`[
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":4,"area":0},
+                {"type":"sprite","file":"b.bin","spritetype":0,"firstspriteid":5,"lastspriteid":8,"area":0},
+                {"type":"sprite","file":"c.bin","spritetype":0,"firstspriteid":9,"lastspriteid":12,"area":0}
+        ]`)},
+		"a.bin": {Data: makeCIPAssetFromImage(t, newTestImage(64, 64))},
+		"b.bin": {Data: makeCIPAssetFromImage(t, newTestImage(64, 64))},
+		"c.bin": {Data: makeCIPAssetFromImage(t, newTestImage(64, 64))},
+	}
+
+	client, err := OpenClientFS(fsys)
+	if err != nil {
+		t.Fatalf("OpenClientFS error: %v", err)
+	}
+	client.cacheSize = 2
+
+	for _, id := range []int{1, 5, 9, 2} {
+		if _, err := client.Sprite(id); err != nil {
+			t.Fatalf("Sprite(%d) error: %v", id, err)
+		}
+	}
+	if client.lru.Len() != 2 {
+		t.Fatalf("cache holds %d sheets, want 2", client.lru.Len())
+	}
+	if _, ok := client.cache["b.bin"]; ok {
+		t.Fatalf("least recently used sheet was not evicted")
+	}
+}
+
+func TestOpenClientErrorsWithoutCatalog(t *testing.T) {
+	if _, err := OpenClient(t.TempDir()); err == nil {
+		t.Fatalf("expected error when catalog-content.json is missing")
+	}
+}
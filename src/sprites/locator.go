@@ -0,0 +1,83 @@
+package sprites
+
+import (
+	"fmt"
+	"image"
+)
+
+// SpriteLocation tells where a sprite is stored in the client assets.
+type SpriteLocation struct {
+	SpriteID int
+	Sheet    CatalogElem
+	// Index is the position of the sprite in its sheet, row by row.
+	Index  int
+	Row    int
+	Column int
+	// Rect is the sprite's pixel rectangle in the sheet.
+	Rect image.Rectangle
+}
+
+// Locate finds the sheet and position of a sprite from the catalog alone,
+// without decoding the sheet. Sheets are assumed to have the size the client
+// ships (384x384).
+func (c *Client) Locate(id int) (SpriteLocation, error) {
+	sheet, ok := c.SheetOf(id)
+	if !ok {
+		return SpriteLocation{}, fmt.Errorf("sprite %d: %w", id, ErrSpriteNotFound)
+	}
+
+	bounds := image.Rect(0, 0, SheetSize, SheetSize)
+	index := id - sheet.FirstSpriteId
+	if index >= SheetCapacity(bounds, sheet.SpriteType) {
+		return SpriteLocation{}, fmt.Errorf("sprite %d: index %d exceeds %s sheet capacity", id, index, sheet.File)
+	}
+
+	w, _ := sheet.SpriteType.Size()
+	cols := SheetSize / w
+	return SpriteLocation{
+		SpriteID: id,
+		Sheet:    sheet,
+		Index:    index,
+		Row:      index / cols,
+		Column:   index % cols,
+		Rect:     SpriteRect(bounds, sheet.SpriteType, index),
+	}, nil
+}
+
+// SpriteReference is an appearance frame group that uses a sprite.
+type SpriteReference struct {
+	Category     AppearanceCategory
+	AppearanceID int
+	Name         string
+	// FrameGroup is the index of the frame group within the appearance.
+	FrameGroup      int
+	FixedFrameGroup FixedFrameGroup
+}
+
+// SpriteReferences indexes every appearance frame group by the sprites it
+// uses. A frame group using a sprite several times is listed once.
+func SpriteReferences(apps *Appearances) map[int][]SpriteReference {
+	refs := make(map[int][]SpriteReference)
+	for _, c := range AppearanceCategories {
+		for _, a := range apps.List(c) {
+			for i, g := range a.FrameGroups {
+				ref := SpriteReference{
+					Category:        c,
+					AppearanceID:    a.ID,
+					Name:            a.Name,
+					FrameGroup:      i,
+					FixedFrameGroup: g.FixedFrameGroup,
+				}
+				seen := make(map[int]bool, len(g.SpriteInfo.SpriteIDs))
+				for _, id := range g.SpriteInfo.SpriteIDs {
+					if seen[id] {
+						continue
+					}
+					seen[id] = true
+					refs[id] = append(refs[id], ref)
+				}
+			}
+		}
+	}
+	return refs
+}
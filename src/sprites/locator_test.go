@@ -0,0 +1,75 @@
+package sprites
+
+import (
+	"errors"
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestClientLocateFindsSheetPosition(t *testing.T) {
+	dir := writeTestClient(t, nil, `[
+                {"type":"sprite","file":"a.bin","spritetype":0,"firstspriteid":1,"lastspriteid":144,"area":0},
+                {"type":"sprite","file":"b.bin","spritetype":1,"firstspriteid":200,"lastspriteid":271,"area":0}
+        ]`)
+	client, err := OpenClient(dir)
+	if err != nil {
+		t.Fatalf("OpenClient error: %v", err)
+	}
+
+	// 32x32 sheets hold 12 sprites per row.
+	loc, err := client.Locate(27)
+	if err != nil {
+		t.Fatalf("Locate(27) error: %v", err)
+	}
+	if loc.Sheet.File != "a.bin" || loc.Index != 26 || loc.Row != 2 || loc.Column != 2 {
+		t.Fatalf("Locate(27) = %+v, want a.bin index 26 at row 2 column 2", loc)
+	}
+	if want := image.Rect(64, 64, 96, 96); loc.Rect != want {
+		t.Fatalf("Locate(27) rect = %v, want %v", loc.Rect, want)
+	}
+
+	// 32x64 sheets hold 12 sprites per row of 64 pixels.
+	loc, err = client.Locate(213)
+	if err != nil {
+		t.Fatalf("Locate(213) error: %v", err)
+	}
+	if loc.Row != 1 || loc.Column != 1 || loc.Rect != image.Rect(32, 64, 64, 128) {
+		t.Fatalf("Locate(213) = %+v, want row 1 column 1", loc)
+	}
+
+	if _, err := client.Locate(150); !errors.Is(err, ErrSpriteNotFound) {
+		t.Fatalf("Locate(150) error = %v, want ErrSpriteNotFound", err)
+	}
+}
+
+func TestSpriteReferencesIndexesFrameGroups(t *testing.T) {
+	apps := &Appearances{
+		Objects: []Appearance{{
+			ID:   100,
+			Name: "torch",
+			FrameGroups: []FrameGroup{
+				{FixedFrameGroup: FrameGroupObjectInitial, SpriteInfo: SpriteInfo{SpriteIDs: []int{1, 2, 1}}},
+			},
+		}},
+		Outfits: []Appearance{{
+			ID: 7,
+			FrameGroups: []FrameGroup{
+				{FixedFrameGroup: FrameGroupOutfitIdle, SpriteInfo: SpriteInfo{SpriteIDs: []int{3}}},
+				{FixedFrameGroup: FrameGroupOutfitMoving, SpriteInfo: SpriteInfo{SpriteIDs: []int{1}}},
+			},
+		}},
+	}
+
+	refs := SpriteReferences(apps)
+	want := []SpriteReference{
+		{Category: CategoryObject, AppearanceID: 100, Name: "torch", FrameGroup: 0, FixedFrameGroup: FrameGroupObjectInitial},
+		{Category: CategoryOutfit, AppearanceID: 7, FrameGroup: 1, FixedFrameGroup: FrameGroupOutfitMoving},
+	}
+	if !reflect.DeepEqual(refs[1], want) {
+		t.Fatalf("refs[1] = %+v, want %+v", refs[1], want)
+	}
+	if len(refs[2]) != 1 || len(refs[3]) != 1 || len(refs[4]) != 0 {
+		t.Fatalf("refs = %+v", refs)
+	}
+}
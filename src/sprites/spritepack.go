@@ -0,0 +1,151 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+)
+
+// A sprite pack is one file holding many PNG sprites, indexed by sprite ID.
+// All integers are little-endian:
+//
+//	offset  size        field
+//	0       4           magic "TSPK"
+//	4       4           version, 1
+//	8       4           first sprite ID
+//	12      4           number of index slots, last ID - first ID + 1
+//	16      12 * slots  index: uint64 blob offset, uint32 blob length
+//	...                 PNG blobs
+//
+// Slot i describes sprite first ID + i; a zero length means the sprite is
+// not in the pack. Offsets are from the start of the file.
+const (
+	SpritePackMagic     = "TSPK"
+	SpritePackVersion   = 1
+	SpritePackHeaderLen = 16
+	SpritePackSlotLen   = 12
+)
+
+// ErrInvalidSpritePack is returned when a file is not a sprite pack.
+var ErrInvalidSpritePack = errors.New("invalid sprite pack")
+
+// SpritePack reads sprites from a sprite pack. Only the header is read when
+// opening; every lookup reads one index slot and one blob, so it takes the
+// same time wherever the sprite sits in the file.
+type SpritePack struct {
+	r       io.ReaderAt
+	closer  io.Closer
+	size    int64
+	firstID int
+	slots   int
+}
+
+// OpenSpritePack opens the sprite pack at path. Close releases the file.
+func OpenSpritePack(path string) (*SpritePack, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	p, err := NewSpritePack(f, fi.Size())
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p.closer = f
+	return p, nil
+}
+
+// NewSpritePack reads a sprite pack of size bytes from r, e.g. a
+// memory-mapped file.
+func NewSpritePack(r io.ReaderAt, size int64) (*SpritePack, error) {
+	var h [SpritePackHeaderLen]byte
+	if _, err := r.ReadAt(h[:], 0); err != nil {
+		return nil, fmt.Errorf("%w: read header: %v", ErrInvalidSpritePack, err)
+	}
+	if string(h[:4]) != SpritePackMagic {
+		return nil, fmt.Errorf("%w: bad magic %q", ErrInvalidSpritePack, h[:4])
+	}
+	if v := binary.LittleEndian.Uint32(h[4:]); v != SpritePackVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidSpritePack, v)
+	}
+	p := &SpritePack{
+		r:       r,
+		size:    size,
+		firstID: int(binary.LittleEndian.Uint32(h[8:])),
+		slots:   int(binary.LittleEndian.Uint32(h[12:])),
+	}
+	if p.dataStart() > size {
+		return nil, fmt.Errorf("%w: index of %d slots exceeds the %d byte file", ErrInvalidSpritePack, p.slots, size)
+	}
+	return p, nil
+}
+
+// dataStart is the offset of the first blob, right after the index.
+func (p *SpritePack) dataStart() int64 {
+	return SpritePackHeaderLen + int64(p.slots)*SpritePackSlotLen
+}
+
+// IDRange is the range of sprite IDs the pack has index slots for. Sprites
+// inside the range may still be missing.
+func (p *SpritePack) IDRange() (first, last int) {
+	return p.firstID, p.firstID + p.slots - 1
+}
+
+// SpriteData returns the PNG bytes of a sprite. It returns an error wrapping
+// ErrSpriteNotFound when the pack does not hold the sprite, and one wrapping
+// ErrInvalidSpritePack when its slot points outside the blobs.
+func (p *SpritePack) SpriteData(id int) ([]byte, error) {
+	slot := id - p.firstID
+	if slot < 0 || slot >= p.slots {
+		return nil, fmt.Errorf("sprite %d: %w", id, ErrSpriteNotFound)
+	}
+	var e [SpritePackSlotLen]byte
+	if _, err := p.r.ReadAt(e[:], int64(SpritePackHeaderLen+slot*SpritePackSlotLen)); err != nil {
+		return nil, fmt.Errorf("sprite %d: read index: %w", id, err)
+	}
+	offset := binary.LittleEndian.Uint64(e[0:])
+	length := binary.LittleEndian.Uint32(e[8:])
+	if length == 0 {
+		return nil, fmt.Errorf("sprite %d: %w", id, ErrSpriteNotFound)
+	}
+	if offset < uint64(p.dataStart()) || offset > uint64(p.size) || int64(length) > p.size-int64(offset) {
+		return nil, fmt.Errorf("sprite %d: %w: blob of %d bytes at %d is outside the file", id, ErrInvalidSpritePack, length, offset)
+	}
+	data := make([]byte, length)
+	if _, err := p.r.ReadAt(data, int64(offset)); err != nil {
+		return nil, fmt.Errorf("sprite %d: read data: %w", id, err)
+	}
+	return data, nil
+}
+
+// Sprite decodes a sprite from the pack.
+func (p *SpritePack) Sprite(id int) (image.Image, error) {
+	data, err := p.SpriteData(id)
+	if err != nil {
+		return nil, err
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("sprite %d: %w", id, err)
+	}
+	return img, nil
+}
+
+// Close closes the file opened by OpenSpritePack. It does nothing for packs
+// made with NewSpritePack.
+func (p *SpritePack) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
@@ -0,0 +1,71 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// spritePackData builds a pack of one slot for sprite 1, pointing at
+// length bytes at offset, followed by blob.
+func spritePackData(offset uint64, length uint32, blob []byte) []byte {
+	data := make([]byte, SpritePackHeaderLen+SpritePackSlotLen)
+	copy(data, SpritePackMagic)
+	binary.LittleEndian.PutUint32(data[4:], SpritePackVersion)
+	binary.LittleEndian.PutUint32(data[8:], 1)
+	binary.LittleEndian.PutUint32(data[12:], 1)
+	binary.LittleEndian.PutUint64(data[16:], offset)
+	binary.LittleEndian.PutUint32(data[24:], length)
+	return append(data, blob...)
+}
+
+func TestNewSpritePackRejectsOtherFiles(t *testing.T) {
+	if _, err := NewSpritePack(bytes.NewReader([]byte("PK\x03\x04 not a sprite pack")), 24); !errors.Is(err, ErrInvalidSpritePack) {
+		t.Fatalf("error = %v, want ErrInvalidSpritePack", err)
+	}
+	if _, err := NewSpritePack(bytes.NewReader(nil), 0); !errors.Is(err, ErrInvalidSpritePack) {
+		t.Fatalf("error = %v, want ErrInvalidSpritePack", err)
+	}
+}
+
+func TestNewSpritePackRejectsIndexBeyondTheFile(t *testing.T) {
+	data := spritePackData(0, 0, nil)
+	binary.LittleEndian.PutUint32(data[12:], 1<<30)
+	if _, err := NewSpritePack(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrInvalidSpritePack) {
+		t.Fatalf("error = %v, want ErrInvalidSpritePack", err)
+	}
+}
+
+func TestSpritePackSpriteDataRejectsSlotsOutsideTheBlobs(t *testing.T) {
+	blob := []byte("blob")
+	start := uint64(SpritePackHeaderLen + SpritePackSlotLen)
+	for _, tt := range []struct {
+		name   string
+		offset uint64
+		length uint32
+	}{
+		{"huge length", start, 1<<32 - 1},
+		{"past the end", start + 2, 4},
+		{"huge offset", 1<<64 - 1, 4},
+		{"inside the index", 0, 4},
+	} {
+		data := spritePackData(tt.offset, tt.length, blob)
+		pack, err := NewSpritePack(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("%s: NewSpritePack: %v", tt.name, err)
+		}
+		if _, err := pack.SpriteData(1); !errors.Is(err, ErrInvalidSpritePack) {
+			t.Fatalf("%s: SpriteData error = %v, want ErrInvalidSpritePack", tt.name, err)
+		}
+	}
+
+	data := spritePackData(start, uint32(len(blob)), blob)
+	pack, err := NewSpritePack(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewSpritePack: %v", err)
+	}
+	if got, err := pack.SpriteData(1); err != nil || !bytes.Equal(got, blob) {
+		t.Fatalf("SpriteData = %q, %v, want %q", got, err, blob)
+	}
+}